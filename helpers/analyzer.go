@@ -0,0 +1,436 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// FilterType is how a property can be filtered on
+type FilterType string
+
+const (
+	// FilterNone properties are reported but cannot be filtered on
+	FilterNone FilterType = ""
+	// FilterNumber properties are integers, usable in comparisons,
+	// BETWEEN and IS classes
+	FilterNumber FilterType = "number"
+	// FilterFlag properties are booleans, usable on their own
+	FilterFlag FilterType = "flag"
+)
+
+// Analyzer computes one property of a string. The built-in analyzers fill
+// the fields of PropertiesMap, each through the binding to its field;
+// RegisterAnalyzer adds more, whose results go into PropertiesMap.Extra.
+// PropertiesMap.Set and Value reach both by name, and registered properties
+// are filterable like the built-ins: by name in q, as GET /strings
+// parameters and, for analyzers that implement QueryNouns, in
+// natural-language queries.
+type Analyzer interface {
+	// Name is the property name, a lowercase identifier such as
+	// "vowel_count"
+	Name() string
+	// Version changes whenever Compute gives different results
+	Version() int
+	// Compute analyzes h.String. Number properties return an int and flag
+	// properties a bool.
+	Compute(h *StringApiHandler) any
+	FilterType() FilterType
+}
+
+// QueryNouns is implemented by analyzers whose property can be named in
+// natural-language queries. A number property gives its unit, as in "more
+// than 3 vowels"; a flag property gives what a string with it is called,
+// as in "pangrams".
+type QueryNouns interface {
+	Nouns() (singular, plural string)
+}
+
+// builtinAnalyzer is an analyzer whose result has its own PropertiesMap
+// field. The lexicon names the built-in properties in queries.
+type builtinAnalyzer struct {
+	name       string
+	version    int
+	filterType FilterType
+	lazy       bool
+	// block is the property whose value includes this one, which is
+	// computed and stored with it
+	block   string
+	compute func(h *StringApiHandler) any
+	field   binding
+}
+
+// binding connects a built-in property to the PropertiesMap field that
+// holds it
+type binding struct {
+	get func(p *PropertiesMap) (any, bool)
+	set func(p *PropertiesMap, value any)
+	// addr points at the field, or returns nil for a property read from
+	// its block
+	addr func(p *PropertiesMap) any
+}
+
+// fieldOf binds a property to a PropertiesMap field. A property is
+// missing while its field is a nil map or pointer, as lazy ones are until
+// they are computed.
+func fieldOf[T any](field func(p *PropertiesMap) *T) binding {
+	return binding{
+		get: func(p *PropertiesMap) (any, bool) {
+			value := any(*field(p))
+			if v := reflect.ValueOf(value); (v.Kind() == reflect.Map || v.Kind() == reflect.Pointer) && v.IsNil() {
+				return value, false
+			}
+			return value, true
+		},
+		set:  func(p *PropertiesMap, value any) { *field(p), _ = value.(T) },
+		addr: func(p *PropertiesMap) any { return field(p) },
+	}
+}
+
+// wordAnalysisField binds the word analysis, which is missing until it is
+// computed and again once the tokenizer that found its words is replaced
+func wordAnalysisField() binding {
+	b := fieldOf(func(p *PropertiesMap) **WordAnalysis { return &p.WordAnalysis })
+	b.get = func(p *PropertiesMap) (any, bool) {
+		return p.WordAnalysis, p.WordAnalysis.current()
+	}
+	return b
+}
+
+// wordField binds a property that is part of the word analysis block. It
+// is missing while the block is, and set with it.
+func wordField(read func(w *WordAnalysis) int) binding {
+	return binding{
+		get: func(p *PropertiesMap) (any, bool) {
+			if !p.WordAnalysis.current() {
+				return nil, false
+			}
+			return read(p.WordAnalysis), true
+		},
+		set:  func(*PropertiesMap, any) {},
+		addr: func(*PropertiesMap) any { return nil },
+	}
+}
+
+func (a *builtinAnalyzer) Name() string                    { return a.name }
+func (a *builtinAnalyzer) Version() int                    { return a.version }
+func (a *builtinAnalyzer) FilterType() FilterType          { return a.filterType }
+func (a *builtinAnalyzer) Compute(h *StringApiHandler) any { return a.compute(h) }
+func (a *builtinAnalyzer) Lazy() bool                      { return a.lazy }
+
+var builtinAnalyzers = []Analyzer{
+	&builtinAnalyzer{name: "length", version: 2, filterType: FilterNumber,
+		field:   fieldOf(func(p *PropertiesMap) *int { return &p.Length }),
+		compute: func(h *StringApiHandler) any { return CountGraphemes(h.String) }},
+	&builtinAnalyzer{name: "byte_length", version: 1, filterType: FilterNumber,
+		field:   fieldOf(func(p *PropertiesMap) *int { return &p.ByteLength }),
+		compute: func(h *StringApiHandler) any { return len(h.String) }},
+	&builtinAnalyzer{name: "rune_count", version: 1, filterType: FilterNumber,
+		field:   fieldOf(func(p *PropertiesMap) *int { return &p.RuneCount }),
+		compute: func(h *StringApiHandler) any { return CountRunes(h.String) }},
+	&builtinAnalyzer{name: "is_palindrome", version: 3, filterType: FilterFlag,
+		field:   fieldOf(func(p *PropertiesMap) *bool { return &p.IsPalindrome }),
+		compute: func(h *StringApiHandler) any { return h.palindromeMode().IsPalindrome(h.String) }},
+	&builtinAnalyzer{name: "palindrome_mode", version: 1, filterType: FilterNone,
+		field:   fieldOf(func(p *PropertiesMap) *string { return &p.PalindromeMode }),
+		compute: func(h *StringApiHandler) any { return h.palindromeMode().String() }},
+	&builtinAnalyzer{name: "unique_characters", version: 2, filterType: FilterNumber,
+		field:   fieldOf(func(p *PropertiesMap) *int { return &p.UniqueCharacters }),
+		compute: func(h *StringApiHandler) any { return CountUniqueCharacters(h.String) }},
+	&builtinAnalyzer{name: "word_count", version: 1, filterType: FilterNumber,
+		field:   fieldOf(func(p *PropertiesMap) *int { return &p.WordCount }),
+		compute: func(h *StringApiHandler) any { return CountWords(h.String) }},
+	&builtinAnalyzer{name: "sha256_hash", version: 1, filterType: FilterNone,
+		field:   fieldOf(func(p *PropertiesMap) *string { return &p.Sha256Hash }),
+		compute: func(h *StringApiHandler) any { return CalculateSHA256(h.String) }},
+	&builtinAnalyzer{name: "character_frequency_map", version: 2, filterType: FilterNone, lazy: true,
+		field:   fieldOf(func(p *PropertiesMap) *CharacterFrequencyMap { return &p.CharacterFrequencyMap }),
+		compute: func(h *StringApiHandler) any { return CharacterFrequencyMap(CalculateCharacterFrequency(h.String)) }},
+	&builtinAnalyzer{name: "word_analysis", version: 2, filterType: FilterNone, lazy: true,
+		field:   wordAnalysisField(),
+		compute: func(h *StringApiHandler) any { return h.wordAnalysis() }},
+	&builtinAnalyzer{name: "longest_word_length", version: 1, filterType: FilterNumber, lazy: true, block: "word_analysis",
+		field:   wordField(func(w *WordAnalysis) int { return w.LongestWordLength }),
+		compute: func(h *StringApiHandler) any { return h.wordAnalysis().LongestWordLength }},
+	&builtinAnalyzer{name: "shortest_word_length", version: 1, filterType: FilterNumber, lazy: true, block: "word_analysis",
+		field:   wordField(func(w *WordAnalysis) int { return w.ShortestWordLength }),
+		compute: func(h *StringApiHandler) any { return h.wordAnalysis().ShortestWordLength }},
+	&builtinAnalyzer{name: "distinct_words", version: 1, filterType: FilterNumber, lazy: true, block: "word_analysis",
+		field:   wordField(func(w *WordAnalysis) int { return w.DistinctWords }),
+		compute: func(h *StringApiHandler) any { return h.wordAnalysis().DistinctWords }},
+}
+
+// builtinNamed finds a built-in analyzer by property name
+func builtinNamed(name string) (*builtinAnalyzer, bool) {
+	for _, a := range builtinAnalyzers {
+		if builtin := a.(*builtinAnalyzer); builtin.name == name {
+			return builtin, true
+		}
+	}
+	return nil, false
+}
+
+// blockOf returns the property an analyzer's value is part of, if any
+func blockOf(a Analyzer) string {
+	if builtin, ok := a.(*builtinAnalyzer); ok {
+		return builtin.block
+	}
+	return ""
+}
+
+var analyzers = struct {
+	sync.RWMutex
+	list []Analyzer
+}{list: builtinAnalyzers}
+
+// analyzerName is what a property name must look like to be usable in q
+var analyzerName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// RegisterAnalyzer adds an analyzer, replacing a registered one of the
+// same name. Built-in analyzers cannot be replaced, and names must not
+// clash with words of the q grammar.
+func RegisterAnalyzer(a Analyzer) error {
+	name := a.Name()
+	if !analyzerName.MatchString(name) {
+		return fmt.Errorf("analyzer name %q is not a lowercase identifier", name)
+	}
+	switch name {
+	case "and", "or", "not", "between", "is", "contains", "true", "false":
+		return fmt.Errorf("analyzer name %q is a filter expression keyword", name)
+	}
+	switch a.FilterType() {
+	case FilterNone, FilterNumber, FilterFlag:
+	default:
+		return fmt.Errorf("analyzer %q has unknown filter type %q", name, a.FilterType())
+	}
+	if named, ok := a.(QueryNouns); ok {
+		if a.FilterType() == FilterNone {
+			return fmt.Errorf("analyzer %q has query nouns but cannot be filtered on", name)
+		}
+		singular, plural := named.Nouns()
+		for _, noun := range []string{singular, plural} {
+			if tokens := tokenizeNaturalLanguage(noun); len(tokens) != 1 || tokens[0].kind != nlWord || tokens[0].text != noun {
+				return fmt.Errorf("analyzer %q: query noun %q is not a single lowercase word", name, noun)
+			}
+		}
+	}
+
+	analyzers.Lock()
+	defer analyzers.Unlock()
+	for i, existing := range analyzers.list {
+		if existing.Name() != name {
+			continue
+		}
+		if _, builtin := existing.(*builtinAnalyzer); builtin {
+			return fmt.Errorf("built-in analyzer %q cannot be replaced", name)
+		}
+		analyzers.list[i] = a
+		return nil
+	}
+	analyzers.list = append(analyzers.list, a)
+	return nil
+}
+
+// Analyzers lists the registered analyzers, built-ins first
+func Analyzers() []Analyzer {
+	analyzers.RLock()
+	defer analyzers.RUnlock()
+	return append([]Analyzer(nil), analyzers.list...)
+}
+
+// LookupAnalyzer finds an analyzer by property name
+func LookupAnalyzer(name string) (Analyzer, bool) {
+	analyzers.RLock()
+	defer analyzers.RUnlock()
+	for _, a := range analyzers.list {
+		if a.Name() == name {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// IsBuiltinAnalyzer reports whether a is one of the analyzers behind the
+// fields of PropertiesMap
+func IsBuiltinAnalyzer(a Analyzer) bool {
+	_, ok := a.(*builtinAnalyzer)
+	return ok
+}
+
+// FilterableProperties lists the names of the properties of a filter type,
+// in registration order
+func FilterableProperties(filterType FilterType) []string {
+	var names []string
+	for _, a := range Analyzers() {
+		if a.FilterType() == filterType {
+			names = append(names, a.Name())
+		}
+	}
+	return names
+}
+
+// IsTextProperty reports whether a property holds text, as sha256_hash
+// and palindrome_mode do. Only built-in properties are known to.
+func IsTextProperty(name string) bool {
+	builtin, ok := builtinNamed(name)
+	if !ok {
+		return false
+	}
+	_, text := builtin.field.addr(&PropertiesMap{}).(*string)
+	return text
+}
+
+// filterable returns the analyzer of a property of the given filter type
+func filterable(name string, filterType FilterType) (Analyzer, bool) {
+	a, ok := LookupAnalyzer(name)
+	return a, ok && a.FilterType() == filterType
+}
+
+// analyzerNouns maps the query nouns of registered analyzers of a filter
+// type, singular and plural, onto their properties
+func analyzerNouns(filterType FilterType) map[string]string {
+	nouns := make(map[string]string)
+	for _, a := range Analyzers() {
+		if named, ok := a.(QueryNouns); ok && a.FilterType() == filterType {
+			singular, plural := named.Nouns()
+			nouns[singular], nouns[plural] = a.Name(), a.Name()
+		}
+	}
+	return nouns
+}
+
+// analyzerPlural is the plural query noun of a registered analyzer
+func analyzerPlural(name string) (string, bool) {
+	a, ok := LookupAnalyzer(name)
+	if !ok {
+		return "", false
+	}
+	named, ok := a.(QueryNouns)
+	if !ok {
+		return "", false
+	}
+	_, plural := named.Nouns()
+	return plural, true
+}
+
+// Set stores the value of a property, in its field for the built-ins. The
+// properties of a block, such as longest_word_length, come with it.
+func (p *PropertiesMap) Set(name string, value any) {
+	if builtin, ok := builtinNamed(name); ok {
+		builtin.field.set(p, value)
+		return
+	}
+	if p.Extra == nil {
+		p.Extra = make(map[string]any)
+	}
+	p.Extra[name] = value
+}
+
+// Value returns a property by name, and false when the string lacks it:
+// an unknown property, or a lazy one not computed yet
+func (p PropertiesMap) Value(name string) (any, bool) {
+	if builtin, ok := builtinNamed(name); ok {
+		return builtin.field.get(&p)
+	}
+	value, ok := p.Extra[name]
+	return value, ok
+}
+
+// Number returns a number property, or 0 when the string has none, as
+// for strings stored before its analyzer was registered
+func (p PropertiesMap) Number(name string) int {
+	value, _ := p.Value(name)
+	n, _ := toInt(value)
+	return n
+}
+
+// Flag returns a flag property, or false when the string has none
+func (p PropertiesMap) Flag(name string) bool {
+	value, _ := p.Value(name)
+	flag, _ := value.(bool)
+	return flag
+}
+
+// propertiesFields is PropertiesMap without its JSON methods
+type propertiesFields PropertiesMap
+
+// builtinKeys are the JSON keys of the PropertiesMap fields
+var builtinKeys = sync.OnceValue(func() map[string]bool {
+	keys := make(map[string]bool)
+	fields := reflect.TypeOf(PropertiesMap{})
+	for i := 0; i < fields.NumField(); i++ {
+		if key, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ","); key != "-" {
+			keys[key] = true
+		}
+	}
+	return keys
+})
+
+// propertyKeys maps the built-in properties to the JSON keys of their
+// fields, which are their names but for word_count's "word_Count"
+var propertyKeys = sync.OnceValue(func() map[string]string {
+	keys := make(map[string]string)
+	var probe PropertiesMap
+	fields := reflect.ValueOf(&probe).Elem()
+	for _, a := range builtinAnalyzers {
+		builtin := a.(*builtinAnalyzer)
+		addr := builtin.field.addr(&probe)
+		for i := 0; i < fields.NumField(); i++ {
+			if fields.Field(i).Addr().Interface() == addr {
+				keys[builtin.name], _, _ = strings.Cut(fields.Type().Field(i).Tag.Get("json"), ",")
+			}
+		}
+	}
+	return keys
+})
+
+// propertyKey is the JSON key of a property
+func propertyKey(name string) string {
+	if key, ok := propertyKeys()[name]; ok {
+		return key
+	}
+	return name
+}
+
+// MarshalJSON writes the properties of registered analyzers next to the
+// built-in ones
+func (p PropertiesMap) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(propertiesFields(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+	var merged map[string]any
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for name, value := range p.Extra {
+		if !builtinKeys()[name] {
+			merged[name] = value
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// UnmarshalJSON reads the built-in properties into their fields and the
+// rest into Extra
+func (p *PropertiesMap) UnmarshalJSON(data []byte) error {
+	var fields propertiesFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	var all map[string]any
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	*p = PropertiesMap(fields)
+	p.Extra = nil
+	for name, value := range all {
+		if !builtinKeys()[name] {
+			p.Set(name, value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,566 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CanonicalQuery renders the tree as an English query that
+// ParseNaturalLanguageFilter reads back as the same tree, e.g.
+// "single-word palindromic strings longer than 4 characters". Trees built
+// by the parser round-trip exactly; other trees come back as an equivalent
+// one. ok is false for the empty tree and for conditions English cannot
+// express: substrings longer than one character, whitespace characters and
+// unique_characters.
+func (t FilterTree) CanonicalQuery() (query string, ok bool) {
+	alternatives := []FilterTree{t}
+	if t.Filter.IsEmpty() && t.Expression == nil && t.And == nil && t.Not == nil && len(t.Or) > 1 {
+		alternatives = t.Or
+	}
+
+	parts := make([]string, len(alternatives))
+	for i, alternative := range alternatives {
+		part, ok := describeAlternative(alternative)
+		if !ok {
+			return describeDisjunctiveForm(t)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " or "), true
+}
+
+// CanonicalURL renders the tree as the equivalent GET /strings request.
+// Filter fields become their own parameters and everything else is joined
+// into a q expression. A zero length or word count means "no constraint"
+// to GET /strings, so constraints on zero are written in q instead.
+func (t FilterTree) CanonicalURL() string {
+	params := url.Values{}
+	var rest []ExprNode
+	f := t.Filter
+
+	if f.IsPalindrome != nil {
+		params.Set("is_palindrome", strconv.FormatBool(*f.IsPalindrome))
+	}
+	if f.MinLength != nil && *f.MinLength > 0 {
+		params.Set("min_length", strconv.Itoa(*f.MinLength))
+	}
+	if f.MaxLength != nil {
+		if *f.MaxLength > 0 {
+			params.Set("max_length", strconv.Itoa(*f.MaxLength))
+		} else {
+			rest = append(rest, &CompareExpr{Field: "length", Op: "<=", Value: *f.MaxLength})
+		}
+	}
+	if f.WordCount != nil {
+		if *f.WordCount > 0 {
+			params.Set("word_count", strconv.Itoa(*f.WordCount))
+		} else {
+			rest = append(rest, &CompareExpr{Field: "word_count", Op: "=", Value: *f.WordCount})
+		}
+	}
+	if f.ContainsCharacter != "" {
+		params.Set("contains_character", f.ContainsCharacter)
+	}
+
+	if len(t.Or) > 0 {
+		or := &OrExpr{}
+		for _, sub := range t.Or {
+			or.Terms = append(or.Terms, sub.canonicalExpr())
+		}
+		rest = append(rest, or)
+	}
+	if t.Not != nil {
+		rest = append(rest, &NotExpr{Operand: t.Not.canonicalExpr()})
+	}
+	for _, sub := range t.And {
+		rest = append(rest, sub.canonicalExpr())
+	}
+	if t.Expression != nil {
+		rest = append(rest, t.Expression)
+	}
+	if len(rest) > 0 {
+		params.Set("q", compactExpr(&AndExpr{Terms: rest}).String())
+	}
+
+	if len(params) == 0 {
+		return "/strings"
+	}
+	return "/strings?" + params.Encode()
+}
+
+// canonicalExpr returns the tree as an expression, writing a pair of
+// length bounds as one comparison or range
+func (t FilterTree) canonicalExpr() ExprNode {
+	if term, ok := t.singleTerm(); ok {
+		return term
+	}
+	return t.expr()
+}
+
+// compactExpr drops the single-term ANDs and ORs that expr builds and
+// merges nested ANDs, so q reads the way a person would write it
+func compactExpr(node ExprNode) ExprNode {
+	switch n := node.(type) {
+	case *AndExpr:
+		var terms []ExprNode
+		for _, term := range n.Terms {
+			term = compactExpr(term)
+			if and, ok := term.(*AndExpr); ok {
+				terms = append(terms, and.Terms...)
+			} else {
+				terms = append(terms, term)
+			}
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return &AndExpr{Terms: terms}
+	case *OrExpr:
+		terms := make([]ExprNode, len(n.Terms))
+		for i, term := range n.Terms {
+			terms[i] = compactExpr(term)
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return &OrExpr{Terms: terms}
+	case *NotExpr:
+		return &NotExpr{Operand: compactExpr(n.Operand)}
+	}
+	return node
+}
+
+// describeAlternative renders a tree without top-level alternatives as
+// "[word count] [palindromic] strings [clause] { and clause }". The parts
+// are written in the order treeFromExpr folds them: the filter fields,
+// then the first OR, the NOT, the separate subtrees and the expression.
+func describeAlternative(t FilterTree) (string, bool) {
+	var prefix, clauses []string
+	f := t.Filter
+
+	if f.WordCount != nil {
+		if *f.WordCount == 1 {
+			prefix = append(prefix, "single-word")
+		} else {
+			prefix = append(prefix, fmt.Sprintf("%d-word", *f.WordCount))
+		}
+	}
+	if f.IsPalindrome != nil {
+		if *f.IsPalindrome {
+			prefix = append(prefix, "palindromic")
+		} else {
+			prefix = append(prefix, "non-palindromic")
+		}
+	}
+	clauses = append(clauses, describeLength(f.MinLength, f.MaxLength)...)
+	if f.ContainsCharacter != "" {
+		clause, ok := describeTerm(&ContainsExpr{Substring: f.ContainsCharacter})
+		if !ok {
+			return "", false
+		}
+		clauses = append(clauses, clause)
+	}
+
+	if len(t.Or) > 0 {
+		clause, ok := describeOr(t.Or)
+		if !ok {
+			return "", false
+		}
+		clauses = append(clauses, clause)
+	}
+	if t.Not != nil {
+		term, ok := t.Not.singleTerm()
+		if !ok {
+			return "", false
+		}
+		clause, ok := describeTerm(negateTerm(term))
+		if !ok {
+			return "", false
+		}
+		clauses = append(clauses, clause)
+	}
+	for _, sub := range t.And {
+		clause, ok := describeSubtree(sub)
+		if !ok {
+			return "", false
+		}
+		clauses = append(clauses, clause)
+	}
+	if t.Expression != nil {
+		terms := []ExprNode{t.Expression}
+		if and, ok := t.Expression.(*AndExpr); ok {
+			terms = and.Terms
+		}
+		for _, term := range terms {
+			clause, ok := describeTerm(term)
+			if !ok {
+				return "", false
+			}
+			clauses = append(clauses, clause)
+		}
+	}
+
+	if len(prefix) == 0 && len(clauses) == 0 {
+		return "", false
+	}
+	words := append(prefix, "strings")
+	if len(clauses) > 0 {
+		words = append(words, strings.Join(clauses, " and "))
+	}
+	return strings.Join(words, " "), true
+}
+
+// describeSubtree renders a subtree of And as a single clause
+func describeSubtree(sub FilterTree) (string, bool) {
+	if sub.Filter.IsEmpty() && sub.Expression == nil && sub.And == nil && sub.Not == nil && len(sub.Or) > 0 {
+		return describeOr(sub.Or)
+	}
+	term, ok := sub.singleTerm()
+	if !ok {
+		return "", false
+	}
+	return describeTerm(term)
+}
+
+// describeDisjunctiveForm renders the tree as alternatives of plain
+// clauses, for trees whose shape the parser would not build on its own
+func describeDisjunctiveForm(t FilterTree) (string, bool) {
+	branches, ok := disjunctiveForm(t.expr(), false)
+	if !ok || len(branches) == 0 {
+		return "", false
+	}
+	parts := make([]string, len(branches))
+	for i, branch := range branches {
+		if len(branch) == 0 {
+			return "", false
+		}
+		clauses := make([]string, len(branch))
+		for j, term := range branch {
+			clause, ok := describeTerm(term)
+			if !ok {
+				return "", false
+			}
+			clauses[j] = clause
+		}
+		parts[i] = "strings " + strings.Join(clauses, " and ")
+	}
+	return strings.Join(parts, " or "), true
+}
+
+// singleTerm returns the tree as one predicate, if it is one
+func (t FilterTree) singleTerm() (ExprNode, bool) {
+	if t.And != nil || t.Or != nil {
+		return nil, false
+	}
+	if t.Not != nil {
+		if !t.Filter.IsEmpty() || t.Expression != nil {
+			return nil, false
+		}
+		term, ok := t.Not.singleTerm()
+		if !ok {
+			return nil, false
+		}
+		return &NotExpr{Operand: term}, true
+	}
+
+	f := t.Filter
+	terms := f.terms()
+	if f.MinLength != nil && f.MaxLength != nil && *f.MinLength <= *f.MaxLength {
+		// The two bounds are one range
+		rest := f
+		rest.MinLength, rest.MaxLength = nil, nil
+		terms = rest.terms()
+		if *f.MinLength == *f.MaxLength {
+			terms = append(terms, &CompareExpr{Field: "length", Op: "=", Value: *f.MinLength})
+		} else {
+			terms = append(terms, &BetweenExpr{Field: "length", Low: *f.MinLength, High: *f.MaxLength})
+		}
+	}
+	if t.Expression != nil {
+		terms = append(terms, t.Expression)
+	}
+	if len(terms) != 1 {
+		return nil, false
+	}
+	return terms[0], true
+}
+
+// describeLength renders length bounds, preferring a single clause
+func describeLength(minLength, maxLength *int) []string {
+	switch {
+	case minLength != nil && maxLength != nil && *minLength == *maxLength:
+		return []string{"exactly " + count(*minLength, "character") + " long"}
+	case minLength != nil && maxLength != nil && *minLength < *maxLength:
+		return []string{fmt.Sprintf("between %d and %d characters long", *minLength, *maxLength)}
+	}
+	var clauses []string
+	if minLength != nil {
+		if *minLength > 0 {
+			clauses = append(clauses, "longer than "+count(*minLength-1, "character"))
+		} else {
+			clauses = append(clauses, "at least 0 characters long")
+		}
+	}
+	if maxLength != nil {
+		clauses = append(clauses, "shorter than "+count(*maxLength+1, "character"))
+	}
+	return clauses
+}
+
+// describeOr renders alternatives inside a clause, which the parser only
+// reads for characters ("containing the letter a or b") and for two exact
+// counts ("with 2 or 3 words")
+func describeOr(branches []FilterTree) (string, bool) {
+	terms := make([]ExprNode, len(branches))
+	for i, branch := range branches {
+		term, ok := branch.singleTerm()
+		if !ok {
+			return "", false
+		}
+		terms[i] = term
+	}
+	return describeList(terms)
+}
+
+func describeList(terms []ExprNode) (string, bool) {
+	if len(terms) == 2 {
+		first, isCompare := terms[0].(*CompareExpr)
+		second, bothCompare := terms[1].(*CompareExpr)
+		if isCompare && bothCompare && first.Op == "=" && second.Op == "=" && first.Field == second.Field {
+			if unit, ok := describeUnit(first.Field); ok {
+				return fmt.Sprintf("with %d or %d %s", first.Value, second.Value, unit), true
+			}
+		}
+	}
+
+	// A list of characters shares the verb of its first item
+	verb := ""
+	characters := make([]string, len(terms))
+	for i, term := range terms {
+		itemVerb := "containing"
+		if not, ok := term.(*NotExpr); ok {
+			itemVerb, term = "without", not.Operand
+		}
+		contains, ok := term.(*ContainsExpr)
+		if !ok || (verb != "" && itemVerb != verb) {
+			return "", false
+		}
+		verb = itemVerb
+		if characters[i], ok = describeCharacter(contains.Substring, i > 0); !ok {
+			return "", false
+		}
+	}
+	return verb + " " + strings.Join(characters, " or "), true
+}
+
+// describeTerm renders one predicate as a clause. Clauses never start with
+// a bare letter or number, which could continue a list of characters.
+func describeTerm(node ExprNode) (string, bool) {
+	switch n := node.(type) {
+	case *FlagExpr:
+		noun, ok := describeFlag(n.Field)
+		return "that are " + noun, ok
+	case *ContainsExpr:
+		character, ok := describeCharacter(n.Substring, false)
+		return "containing " + character, ok
+	case *NotExpr:
+		switch operand := n.Operand.(type) {
+		case *FlagExpr:
+			noun, ok := describeFlag(operand.Field)
+			return "that are not " + noun, ok
+		case *ContainsExpr:
+			character, ok := describeCharacter(operand.Substring, false)
+			return "without " + character, ok
+		case *CompareExpr:
+			return describeTerm(negateTerm(operand))
+		case *BetweenExpr:
+			clause, ok := describeTerm(operand)
+			return "not " + clause, ok && operand.Field == "length"
+		case *NumberClassExpr:
+			if complement, ok := negateTerm(operand).(*NumberClassExpr); ok {
+				return describeTerm(complement)
+			}
+			return describeNumberClass(operand, "non-"+operand.Class)
+		}
+	case *CompareExpr:
+		return describeComparison(n)
+	case *BetweenExpr:
+		switch n.Field {
+		case "length":
+			return fmt.Sprintf("between %d and %d characters long", n.Low, n.High), true
+		case "longest_word_length", "shortest_word_length":
+			return fmt.Sprintf("%s between %d and %d letters", describeWord(n.Field), n.Low, n.High), true
+		default:
+			unit, ok := describeUnit(n.Field)
+			return fmt.Sprintf("with between %d and %d %s", n.Low, n.High, unit), ok
+		}
+	case *NumberClassExpr:
+		return describeNumberClass(n, n.Class)
+	case *OrExpr:
+		return describeList(n.Terms)
+	}
+	return "", false
+}
+
+// describeNumberClass writes "with a prime number of characters", or with
+// another adjective such as "non-prime"
+func describeNumberClass(n *NumberClassExpr, adjective string) (string, bool) {
+	unit, ok := describeUnit(n.Field)
+	if n.Field == "unique_characters" {
+		unit, ok = "unique characters", true
+	}
+	article := "a"
+	if strings.ContainsRune("aeiou", rune(adjective[0])) {
+		article = "an"
+	}
+	return fmt.Sprintf("with %s %s number of %s", article, adjective, unit), ok
+}
+
+func describeComparison(n *CompareExpr) (string, bool) {
+	switch n.Field {
+	case "length":
+		switch n.Op {
+		case ">":
+			return "longer than " + count(n.Value, "character"), true
+		case ">=":
+			return "at least " + count(n.Value, "character") + " long", true
+		case "<":
+			return "shorter than " + count(n.Value, "character"), true
+		case "<=":
+			return "at most " + count(n.Value, "character") + " long", true
+		case "=":
+			return "exactly " + count(n.Value, "character") + " long", true
+		case "!=":
+			return "not exactly " + count(n.Value, "character") + " long", true
+		}
+	case "longest_word_length", "shortest_word_length":
+		word := describeWord(n.Field)
+		switch n.Op {
+		case ">":
+			return word + " more than " + count(n.Value, "letter"), true
+		case ">=":
+			return word + " at least " + count(n.Value, "letter"), true
+		case "<":
+			return word + " fewer than " + count(n.Value, "letter"), true
+		case "<=":
+			return word + " at most " + count(n.Value, "letter"), true
+		case "=":
+			return word + " exactly " + count(n.Value, "letter"), true
+		}
+	default:
+		units, ok := describeUnit(n.Field)
+		if !ok {
+			return "", false
+		}
+		unit := describeSingular(n.Field, units)
+		switch n.Op {
+		case ">":
+			return "with more than " + countNoun(n.Value, unit, units), true
+		case ">=":
+			return "with at least " + countNoun(n.Value, unit, units), true
+		case "<":
+			return "with fewer than " + countNoun(n.Value, unit, units), true
+		case "<=":
+			return "with at most " + countNoun(n.Value, unit, units), true
+		case "=":
+			return "with exactly " + countNoun(n.Value, unit, units), true
+		case "!=":
+			// "that" ends the previous clause, so "not" reaches the count
+			return "that do not have exactly " + countNoun(n.Value, unit, units), true
+		}
+	}
+	return "", false
+}
+
+// describeWord starts a clause about one word, as in "whose longest word
+// has"
+func describeWord(field string) string {
+	return "whose " + strings.TrimSuffix(field, "_word_length") + " word has"
+}
+
+// count writes a number with its unit, as in "1 word" or "3 words"
+func count(n int, unit string) string {
+	return countNoun(n, unit, unit+"s")
+}
+
+// countNoun is count for units with irregular plurals
+func countNoun(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// describeUnit names what a number property counts, in the plural. A
+// registered analyzer uses its query nouns.
+func describeUnit(field string) (string, bool) {
+	switch field {
+	case "length":
+		return "characters", true
+	case "word_count":
+		return "words", true
+	case "byte_length":
+		return "bytes", true
+	case "rune_count":
+		return "runes", true
+	}
+	if a, ok := filterable(field, FilterNumber); ok {
+		if named, ok := a.(QueryNouns); ok {
+			_, plural := named.Nouns()
+			return plural, true
+		}
+	}
+	return "", false
+}
+
+// describeSingular is the singular of a unit from describeUnit
+func describeSingular(field, plural string) string {
+	if a, ok := filterable(field, FilterNumber); ok {
+		if named, ok := a.(QueryNouns); ok {
+			singular, _ := named.Nouns()
+			return singular
+		}
+	}
+	return strings.TrimSuffix(plural, "s")
+}
+
+// describeFlag names the strings a flag property holds for, as in
+// "palindromes" or a registered analyzer's plural query noun
+func describeFlag(field string) (string, bool) {
+	if field == "is_palindrome" {
+		return "palindromes", true
+	}
+	if _, ok := filterable(field, FilterFlag); !ok {
+		return "", false
+	}
+	return analyzerPlural(field)
+}
+
+// describeCharacter names a character as "the letter z", "the digit 7" or
+// "'Q'"; later items of a list drop the noun. Whitespace and longer
+// substrings cannot be written.
+func describeCharacter(s string, listed bool) (string, bool) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || unicode.IsSpace(r) {
+		return "", false
+	}
+	switch {
+	case unicode.IsLetter(r) && unicode.IsLower(r):
+		if listed {
+			return s, true
+		}
+		return "the letter " + s, true
+	case r >= '0' && r <= '9':
+		if listed {
+			return s, true
+		}
+		return "the digit " + s, true
+	case r == '\'':
+		return `"'"`, true
+	}
+	return "'" + s + "'", true
+}
@@ -0,0 +1,556 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	utils "hng/step0/util"
+)
+
+// Filter expressions are the `q` parameter of GET /strings. The grammar is
+//
+//	expr      := or
+//	or        := and { OR and }
+//	and       := unary { AND unary }
+//	unary     := NOT unary | primary
+//	primary   := "(" expr ")" | predicate
+//	predicate := FLAG [ "(" STRING ")" ] [ "=" ( true | false ) ]
+//	           | FIELD op NUMBER
+//	           | FIELD BETWEEN NUMBER AND NUMBER
+//	           | FIELD IS [ NOT ] CLASS
+//	           | contains "(" STRING ")"
+//	op        := "=" | "==" | "!=" | "<" | "<=" | ">" | ">="
+//
+// FLAG is is_palindrome, optionally with a palindrome mode such as
+// 'case,punctuation' (see ParsePalindromeMode) to recheck strings under it.
+// FIELD is one of length (in grapheme clusters), byte_length, rune_count,
+// word_count or unique_characters and CLASS is one of prime, perfect,
+// armstrong, even or odd. Registered analyzers add their properties as
+// FLAGs and FIELDs by filter type. Keywords are case-insensitive and strings take
+// single or double quotes, e.g.
+//
+//	(is_palindrome OR word_count >= 3) AND NOT contains('z') AND length BETWEEN 5 AND 20
+//	length IS prime AND word_count IS NOT even
+//	byte_length > 100 AND length <= 10
+//	is_palindrome('case,punctuation,whitespace') AND word_count > 3
+
+// ExprNode is a compiled filter expression
+type ExprNode interface {
+	// Match reports whether a stored string satisfies the expression
+	Match(r Response) bool
+	// String returns the normalized form of the expression
+	String() string
+}
+
+// AndExpr matches when every term matches
+type AndExpr struct{ Terms []ExprNode }
+
+// OrExpr matches when any term matches
+type OrExpr struct{ Terms []ExprNode }
+
+// NotExpr matches when its operand does not
+type NotExpr struct{ Operand ExprNode }
+
+// FlagExpr matches strings whose boolean property is set
+type FlagExpr struct{ Field string }
+
+// PalindromeExpr matches strings that are palindromes under Mode, whatever
+// mode their stored is_palindrome was computed with
+type PalindromeExpr struct{ Mode PalindromeMode }
+
+// CompareExpr compares a numeric property with a constant
+type CompareExpr struct {
+	Field string
+	Op    string
+	Value int
+}
+
+// BetweenExpr matches numeric properties in the inclusive range [Low, High]
+type BetweenExpr struct {
+	Field     string
+	Low, High int
+}
+
+// ContainsExpr matches strings containing Substring
+type ContainsExpr struct{ Substring string }
+
+// NumberClassExpr matches numeric properties that belong to a class of
+// numbers, such as primes
+type NumberClassExpr struct{ Field, Class string }
+
+// numberClasses are the classes of numbers NumberClassExpr can test
+var numberClasses = map[string]func(n int) bool{
+	"prime":     utils.IsPrime,
+	"perfect":   utils.IsPerfect,
+	"armstrong": utils.IsArmstrong,
+	"even":      utils.IsEven,
+	"odd":       func(n int) bool { return !utils.IsEven(n) },
+}
+
+// NumberClasses lists the classes usable with IS, in the order they are
+// documented
+func NumberClasses() []string {
+	return []string{"prime", "perfect", "armstrong", "even", "odd"}
+}
+
+// IsNumberClass reports whether a class of numbers is known
+func IsNumberClass(class string) bool { return numberClasses[class] != nil }
+
+func (e *AndExpr) Match(r Response) bool {
+	for _, term := range e.Terms {
+		if !term.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *OrExpr) Match(r Response) bool {
+	for _, term := range e.Terms {
+		if term.Match(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *NotExpr) Match(r Response) bool { return !e.Operand.Match(r) }
+
+func (e *FlagExpr) Match(r Response) bool { return r.Flag(e.Field) }
+
+func (e *PalindromeExpr) Match(r Response) bool { return e.Mode.IsPalindrome(r.Value) }
+
+func (e *CompareExpr) Match(r Response) bool {
+	value := r.Number(e.Field)
+	switch e.Op {
+	case "=":
+		return value == e.Value
+	case "!=":
+		return value != e.Value
+	case "<":
+		return value < e.Value
+	case "<=":
+		return value <= e.Value
+	case ">":
+		return value > e.Value
+	case ">=":
+		return value >= e.Value
+	}
+	return false
+}
+
+func (e *BetweenExpr) Match(r Response) bool {
+	value := r.Number(e.Field)
+	return value >= e.Low && value <= e.High
+}
+
+func (e *ContainsExpr) Match(r Response) bool { return strings.Contains(r.Value, e.Substring) }
+
+func (e *NumberClassExpr) Match(r Response) bool {
+	return numberClasses[e.Class](r.Number(e.Field))
+}
+
+func (e *AndExpr) String() string { return joinTerms(e.Terms, " AND ", true) }
+
+func (e *OrExpr) String() string { return joinTerms(e.Terms, " OR ", false) }
+
+func (e *NotExpr) String() string {
+	switch e.Operand.(type) {
+	case *AndExpr, *OrExpr:
+		return "NOT (" + e.Operand.String() + ")"
+	}
+	return "NOT " + e.Operand.String()
+}
+
+func (e *FlagExpr) String() string { return e.Field }
+
+func (e *PalindromeExpr) String() string { return "is_palindrome('" + e.Mode.String() + "')" }
+
+func (e *CompareExpr) String() string { return fmt.Sprintf("%s %s %d", e.Field, e.Op, e.Value) }
+
+func (e *BetweenExpr) String() string {
+	return fmt.Sprintf("%s BETWEEN %d AND %d", e.Field, e.Low, e.High)
+}
+
+func (e *NumberClassExpr) String() string { return e.Field + " IS " + e.Class }
+
+func (e *ContainsExpr) String() string {
+	return "contains('" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(e.Substring) + "')"
+}
+
+// joinTerms parenthesizes OR terms inside an AND; AND binds tighter than OR
+// so the reverse needs no parentheses
+func joinTerms(terms []ExprNode, sep string, wrapOr bool) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term.String()
+		if _, isOr := term.(*OrExpr); isOr && wrapOr {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// SyntaxError reports where a filter expression stopped making sense.
+// Position is the 1-based character offset into the expression.
+type SyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Position, e.Message)
+}
+
+type exprTokenKind int
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	pos  int // 1-based character offset
+}
+
+func (t exprToken) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// tokenizeExpression splits an expression into tokens
+func tokenizeExpression(src string) ([]exprToken, error) {
+	runes := []rune(src)
+	var tokens []exprToken
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, exprToken{tokenLParen, "(", start + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, exprToken{tokenRParen, ")", start + 1})
+			i++
+		case r == '\'' || r == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, &SyntaxError{start + 1, "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, exprToken{tokenString, sb.String(), start + 1})
+		case strings.ContainsRune("=!<>", r):
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			op := string(runes[start:i])
+			switch op {
+			case "==":
+				op = "="
+			case "!":
+				return nil, &SyntaxError{start + 1, `unexpected "!" (use != or NOT)`}
+			}
+			tokens = append(tokens, exprToken{tokenOp, op, start + 1})
+		case r >= '0' && r <= '9':
+			for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokenNumber, string(runes[start:i]), start + 1})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokenIdent, string(runes[start:i]), start + 1})
+		default:
+			return nil, &SyntaxError{start + 1, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+	return append(tokens, exprToken{tokenEOF, "", len(runes) + 1}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// ParseFilterExpression compiles a filter expression. Errors are returned
+// as *SyntaxError.
+func ParseFilterExpression(src string) (ExprNode, error) {
+	tokens, err := tokenizeExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, p.errorf(next, "unexpected %s, expected AND, OR or end of expression", next.describe())
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...any) error {
+	return &SyntaxError{t.pos, fmt.Sprintf(format, args...)}
+}
+
+func (p *exprParser) parseOr() (ExprNode, error) {
+	terms, err := p.parseList("OR", p.parseAnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	// Merge parenthesized groups of the same operator, so that
+	// "a OR (b OR c)" normalizes to "a OR b OR c"
+	var flat []ExprNode
+	for _, term := range terms {
+		if group, ok := term.(*OrExpr); ok {
+			flat = append(flat, group.Terms...)
+		} else {
+			flat = append(flat, term)
+		}
+	}
+	return &OrExpr{Terms: flat}, nil
+}
+
+func (p *exprParser) parseAnd() (ExprNode, error) {
+	terms, err := p.parseList("AND", p.parseUnary)
+	if err != nil {
+		return nil, err
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	var flat []ExprNode
+	for _, term := range terms {
+		if group, ok := term.(*AndExpr); ok {
+			flat = append(flat, group.Terms...)
+		} else {
+			flat = append(flat, term)
+		}
+	}
+	return &AndExpr{Terms: flat}, nil
+}
+
+func (p *exprParser) parseList(keyword string, operand func() (ExprNode, error)) ([]ExprNode, error) {
+	var terms []ExprNode
+	for {
+		term, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+		if !p.isKeyword(keyword) {
+			return terms, nil
+		}
+		p.next()
+	}
+}
+
+func (p *exprParser) parseUnary() (ExprNode, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if inner, ok := operand.(*NotExpr); ok {
+			return inner.Operand, nil
+		}
+		return &NotExpr{Operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (ExprNode, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorf(closing, "expected \")\" to close the group opened at position %d, found %s", t.pos, closing.describe())
+		}
+		return node, nil
+	case t.kind != tokenIdent:
+		return nil, p.errorf(t, "unexpected %s, expected a property, contains(...) or \"(\"", t.describe())
+	}
+
+	name := strings.ToLower(t.text)
+	switch {
+	case name == "contains":
+		return p.parseContains(t)
+	}
+	switch analyzer, _ := LookupAnalyzer(name); {
+	case analyzer == nil:
+	case analyzer.FilterType() == FilterFlag:
+		return p.parseFlag(name)
+	case analyzer.FilterType() == FilterNumber:
+		return p.parseNumeric(name)
+	}
+	return nil, p.errorf(t, "unknown property %q", t.text)
+}
+
+func (p *exprParser) parseContains(t exprToken) (ExprNode, error) {
+	if open := p.next(); open.kind != tokenLParen {
+		return nil, p.errorf(open, "expected \"(\" after contains, found %s", open.describe())
+	}
+	arg := p.next()
+	if arg.kind != tokenString {
+		return nil, p.errorf(arg, "expected a quoted string, found %s", arg.describe())
+	}
+	if arg.text == "" {
+		return nil, p.errorf(arg, "contains needs a non-empty string")
+	}
+	if closing := p.next(); closing.kind != tokenRParen {
+		return nil, p.errorf(closing, "expected \")\" after the contains argument, found %s", closing.describe())
+	}
+	return &ContainsExpr{Substring: arg.text}, nil
+}
+
+func (p *exprParser) parseFlag(name string) (ExprNode, error) {
+	var flag ExprNode = &FlagExpr{Field: name}
+	if name == "is_palindrome" && p.peek().kind == tokenLParen {
+		mode, err := p.parsePalindromeMode()
+		if err != nil {
+			return nil, err
+		}
+		flag = &PalindromeExpr{Mode: mode}
+	}
+	if t := p.peek(); t.kind != tokenOp {
+		return flag, nil
+	}
+	op := p.next()
+	if op.text != "=" && op.text != "!=" {
+		return nil, p.errorf(op, "%s only supports = and !=", name)
+	}
+	value := p.next()
+	if value.kind != tokenIdent || (!strings.EqualFold(value.text, "true") && !strings.EqualFold(value.text, "false")) {
+		return nil, p.errorf(value, "expected true or false, found %s", value.describe())
+	}
+	if strings.EqualFold(value.text, "true") == (op.text == "=") {
+		return flag, nil
+	}
+	return &NotExpr{Operand: flag}, nil
+}
+
+// parsePalindromeMode reads the ("mode") after is_palindrome
+func (p *exprParser) parsePalindromeMode() (PalindromeMode, error) {
+	p.next()
+	arg := p.next()
+	if arg.kind != tokenString {
+		return PalindromeMode{}, p.errorf(arg, "expected a quoted palindrome mode, found %s", arg.describe())
+	}
+	mode, err := ParsePalindromeMode(arg.text)
+	if err != nil {
+		return mode, p.errorf(arg, "%v", err)
+	}
+	if closing := p.next(); closing.kind != tokenRParen {
+		return mode, p.errorf(closing, "expected \")\" after the palindrome mode, found %s", closing.describe())
+	}
+	return mode, nil
+}
+
+func (p *exprParser) parseNumeric(name string) (ExprNode, error) {
+	if p.isKeyword("IS") {
+		p.next()
+		negated := p.isKeyword("NOT")
+		if negated {
+			p.next()
+		}
+		t := p.next()
+		class := strings.ToLower(t.text)
+		if t.kind != tokenIdent || !IsNumberClass(class) {
+			classes := NumberClasses()
+			return nil, p.errorf(t, "expected %s or %s after IS, found %s", strings.Join(classes[:len(classes)-1], ", "), classes[len(classes)-1], t.describe())
+		}
+		var node ExprNode = &NumberClassExpr{Field: name, Class: class}
+		if negated {
+			node = &NotExpr{Operand: node}
+		}
+		return node, nil
+	}
+	if p.isKeyword("BETWEEN") {
+		p.next()
+		low, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.errorf(p.peek(), "expected AND in BETWEEN, found %s", p.peek().describe())
+		}
+		p.next()
+		high, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{Field: name, Low: low, High: high}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, p.errorf(op, "expected a comparison, BETWEEN or IS after %s, found %s", name, op.describe())
+	}
+	value, err := p.parseNumber()
+	if err != nil {
+		return nil, err
+	}
+	return &CompareExpr{Field: name, Op: op.text, Value: value}, nil
+}
+
+func (p *exprParser) parseNumber() (int, error) {
+	t := p.next()
+	if t.kind != tokenNumber {
+		return 0, p.errorf(t, "expected a number, found %s", t.describe())
+	}
+	value, err := strconv.Atoi(t.text)
+	if err != nil {
+		return 0, p.errorf(t, "number %s is out of range", t.text)
+	}
+	return value, nil
+}
@@ -0,0 +1,191 @@
+package helpers
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Lazy is implemented by analyzers too expensive to run on every string.
+// A lazy property is computed when a string is created only if it was
+// asked for, and otherwise on first read; stores keep it from then on.
+// Filters on a lazy property compute it for each string they check.
+type Lazy interface {
+	Lazy() bool
+}
+
+// IsLazyAnalyzer reports whether a runs on first access
+func IsLazyAnalyzer(a Analyzer) bool {
+	lazy, ok := a.(Lazy)
+	return ok && lazy.Lazy()
+}
+
+// deferrable reports whether a stored string can lack the property of a
+// until it is read: a lazy one, or a registered one, which no store
+// indexes. The properties of a block come with it.
+func deferrable(a Analyzer) bool {
+	return blockOf(a) == "" && (IsLazyAnalyzer(a) || !IsBuiltinAnalyzer(a))
+}
+
+// ParseFields reads a comma-separated list of property names, as in
+// "length,is_palindrome". A nil list selects every property.
+func ParseFields(s string) ([]string, error) {
+	var fields []string
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if _, ok := LookupAnalyzer(field); !ok {
+			names := make([]string, 0)
+			for _, a := range Analyzers() {
+				names = append(names, a.Name())
+			}
+			return nil, fmt.Errorf("unknown property %q, expected some of %s", field, strings.Join(names, ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no properties listed")
+	}
+	return fields, nil
+}
+
+// selected reports whether fields, nil for all, include a property or one
+// of the properties of its block
+func selected(fields []string, name string) bool {
+	if fields == nil || slices.Contains(fields, name) {
+		return true
+	}
+	for _, field := range fields {
+		if a, ok := LookupAnalyzer(field); ok && blockOf(a) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzeFields runs the analyzers a stored string needs, which are the
+// built-in ones the stores index, and the others among fields
+func (h *StringApiHandler) AnalyzeFields(fields []string) PropertiesMap {
+	var properties PropertiesMap
+	for _, analyzer := range Analyzers() {
+		if blockOf(analyzer) != "" {
+			continue
+		}
+		if !deferrable(analyzer) || selected(fields, analyzer.Name()) {
+			properties.Set(analyzer.Name(), analyzer.Compute(h))
+		}
+	}
+	return properties
+}
+
+// has reports whether a property has been computed. Only deferrable
+// properties can be missing.
+func (p PropertiesMap) has(name string) bool {
+	_, ok := p.Value(name)
+	return ok
+}
+
+// Complete computes the deferrable properties among fields, nil for all,
+// that r lacks, and reports whether there were any. The extra properties are
+// copied first, as stores hand out records that share them.
+func (r *Response) Complete(fields []string) bool {
+	var missing []Analyzer
+	for _, analyzer := range Analyzers() {
+		if deferrable(analyzer) && selected(fields, analyzer.Name()) && !r.Properties.has(analyzer.Name()) {
+			missing = append(missing, analyzer)
+		}
+	}
+	if len(missing) == 0 {
+		return false
+	}
+
+	r.Properties.Extra = maps.Clone(r.Properties.Extra)
+	h := StringApiHandler{String: r.Value}
+	for _, analyzer := range missing {
+		r.Properties.Set(analyzer.Name(), analyzer.Compute(&h))
+	}
+	return true
+}
+
+// Merge copies onto p the deferrable properties computed has, keeping
+// those p has that computed lacks. Two reads that completed different properties
+// of a string can both be kept this way. The extra properties are copied
+// first, as in Complete.
+func (p *PropertiesMap) Merge(computed PropertiesMap) {
+	p.Extra = maps.Clone(p.Extra)
+	for _, analyzer := range Analyzers() {
+		if !deferrable(analyzer) {
+			continue
+		}
+		if value, ok := computed.Value(analyzer.Name()); ok {
+			p.Set(analyzer.Name(), value)
+		}
+	}
+}
+
+// property returns a property of r, computing a lazy one that r lacks
+// without keeping it
+func (r Response) property(name string) any {
+	if !r.Properties.has(name) {
+		if analyzer, ok := LookupAnalyzer(name); ok {
+			h := StringApiHandler{String: r.Value}
+			return analyzer.Compute(&h)
+		}
+	}
+	value, _ := r.Properties.Value(name)
+	return value
+}
+
+// Number is the number property of r, 0 when it has none. A deferrable
+// property r lacks is computed but not kept, as filters and sorts need.
+func (r Response) Number(name string) int {
+	n, _ := toInt(r.property(name))
+	return n
+}
+
+// Flag is the flag property of r, false when it has none, computed like
+// Number
+func (r Response) Flag(name string) bool {
+	flag, _ := r.property(name).(bool)
+	return flag
+}
+
+// SelectedResponse is a Response written with some of its properties
+type SelectedResponse struct {
+	ID         string         `json:"id"`
+	Value      string         `json:"value"`
+	Properties map[string]any `json:"properties"`
+	CreatedAt  string         `json:"created_at"`
+}
+
+// Select returns r for JSON with only the properties among fields, or r
+// itself when fields is nil
+func (r Response) Select(fields []string) any {
+	if fields == nil {
+		return r
+	}
+	properties := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if value, ok := r.Properties.Value(field); ok {
+			properties[propertyKey(field)] = value
+		}
+	}
+	return SelectedResponse{ID: r.ID, Value: r.Value, Properties: properties, CreatedAt: r.CreatedAt}
+}
+
+// SelectAll is Select over a list of strings
+func SelectAll(items []Response, fields []string) any {
+	if fields == nil {
+		return items
+	}
+	responses := make([]any, len(items))
+	for i, item := range items {
+		responses[i] = item.Select(fields)
+	}
+	return responses
+}
@@ -0,0 +1,260 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Filter is the typed form of the filters accepted by GET /strings and
+// produced by ParseNaturalLanguageQuery. Nil pointers and an empty
+// ContainsCharacter mean "no constraint".
+type Filter struct {
+	IsPalindrome      *bool  `json:"is_palindrome,omitempty"`
+	MinLength         *int   `json:"min_length,omitempty"`
+	MaxLength         *int   `json:"max_length,omitempty"`
+	WordCount         *int   `json:"word_count,omitempty"`
+	ContainsCharacter string `json:"contains_character,omitempty"`
+}
+
+// FilterFromMap converts the map form used by ParseNaturalLanguageQuery.
+// Unknown keys and values of the wrong type are reported as errors instead
+// of panicking. Integers may be given as any Go integer type, or as
+// integral float64 and json.Number values as produced by encoding/json.
+func FilterFromMap(filters map[string]interface{}) (Filter, error) {
+	var f Filter
+	for key, value := range filters {
+		switch key {
+		case "is_palindrome":
+			b, ok := value.(bool)
+			if !ok {
+				return f, fmt.Errorf("is_palindrome must be a boolean, got %T", value)
+			}
+			f.IsPalindrome = &b
+		case "min_length", "max_length", "word_count":
+			n, err := toInt(value)
+			if err != nil {
+				return f, fmt.Errorf("%s %v", key, err)
+			}
+			switch key {
+			case "min_length":
+				f.MinLength = &n
+			case "max_length":
+				f.MaxLength = &n
+			case "word_count":
+				f.WordCount = &n
+			}
+		case "contains_character":
+			s, ok := value.(string)
+			if !ok {
+				return f, fmt.Errorf("contains_character must be a string, got %T", value)
+			}
+			f.ContainsCharacter = s
+		default:
+			return f, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+	return f, f.Validate()
+}
+
+func toInt(value interface{}) (int, error) {
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < math.MaxInt32 {
+			return int(n), nil
+		}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return 0, fmt.Errorf("must be an integer, got %v", value)
+}
+
+// ToMap returns the map form of the filter, as used in interpreted_query
+func (f Filter) ToMap() map[string]interface{} {
+	m := make(map[string]interface{})
+	if f.IsPalindrome != nil {
+		m["is_palindrome"] = *f.IsPalindrome
+	}
+	if f.MinLength != nil {
+		m["min_length"] = *f.MinLength
+	}
+	if f.MaxLength != nil {
+		m["max_length"] = *f.MaxLength
+	}
+	if f.WordCount != nil {
+		m["word_count"] = *f.WordCount
+	}
+	if f.ContainsCharacter != "" {
+		m["contains_character"] = f.ContainsCharacter
+	}
+	return m
+}
+
+// IsEmpty reports whether the filter matches every string
+func (f Filter) IsEmpty() bool {
+	return f.IsPalindrome == nil && f.MinLength == nil && f.MaxLength == nil &&
+		f.WordCount == nil && f.ContainsCharacter == ""
+}
+
+// Validate checks each constraint on its own: counts must not be negative
+// and contains_character must be exactly one character
+func (f Filter) Validate() error {
+	for name, value := range map[string]*int{"min_length": f.MinLength, "max_length": f.MaxLength, "word_count": f.WordCount} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if f.ContainsCharacter != "" && utf8.RuneCountInString(f.ContainsCharacter) != 1 {
+		return fmt.Errorf("contains_character must be a single character, got %q", f.ContainsCharacter)
+	}
+	return nil
+}
+
+// Conflicts lists the reasons no stored string can satisfy the filter, or
+// nil when it is satisfiable. Stored strings are never empty, an n-word
+// string needs at least 2n-1 characters, and every string shorter than two
+// characters is a palindrome.
+func (f Filter) Conflicts() []string {
+	var conflicts []string
+
+	// The shortest and longest lengths a matching string could have
+	minLength, maxLength := 1, math.MaxInt
+	if f.MinLength != nil {
+		minLength = max(minLength, *f.MinLength)
+	}
+	if f.MaxLength != nil {
+		maxLength = *f.MaxLength
+	}
+
+	if f.MinLength != nil && f.MaxLength != nil && *f.MinLength > *f.MaxLength {
+		conflicts = append(conflicts, fmt.Sprintf("min_length %d is greater than max_length %d", *f.MinLength, *f.MaxLength))
+	} else if maxLength < 1 {
+		conflicts = append(conflicts, "max_length 0 excludes every string")
+	}
+
+	if f.WordCount != nil {
+		if needed := 2**f.WordCount - 1; *f.WordCount > 0 && needed > maxLength {
+			conflicts = append(conflicts, fmt.Sprintf("%d words need at least %d characters but max_length is %d", *f.WordCount, needed, maxLength))
+		}
+		if *f.WordCount == 0 && f.ContainsCharacter != "" && !isSpace(f.ContainsCharacter) {
+			conflicts = append(conflicts, fmt.Sprintf("a string with no words cannot contain %q", f.ContainsCharacter))
+		}
+	}
+
+	if f.IsPalindrome != nil && !*f.IsPalindrome && maxLength < 2 {
+		conflicts = append(conflicts, "every string shorter than 2 characters is a palindrome")
+	}
+	return conflicts
+}
+
+func isSpace(s string) bool {
+	return strings.TrimFunc(s, unicode.IsSpace) == ""
+}
+
+// Match reports whether a string satisfies every constraint. Lengths are
+// grapheme counts taken, like the palindrome flag and word count, from the
+// stored properties.
+func (f Filter) Match(r Response) bool {
+	if f.IsPalindrome != nil && r.Properties.IsPalindrome != *f.IsPalindrome {
+		return false
+	}
+	if f.MinLength != nil && r.Properties.Length < *f.MinLength {
+		return false
+	}
+	if f.MaxLength != nil && r.Properties.Length > *f.MaxLength {
+		return false
+	}
+	if f.WordCount != nil && r.Properties.WordCount != *f.WordCount {
+		return false
+	}
+	if f.ContainsCharacter != "" && !strings.Contains(r.Value, f.ContainsCharacter) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the strings that satisfy the filter, in order
+func (f Filter) Apply(data []Response) []Response {
+	filtered := make([]Response, 0)
+	for _, item := range data {
+		if f.Match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// WithExpression narrows f with the index-friendly part of a filter
+// expression: the top-level AND terms that map onto Filter fields.
+// Constraints already in f are kept, so the result matches a superset of
+// "f AND expr" and callers must still run expr.Match on what it selects.
+func (f Filter) WithExpression(expr ExprNode) Filter {
+	terms := []ExprNode{expr}
+	if and, ok := expr.(*AndExpr); ok {
+		terms = and.Terms
+	}
+
+	atLeast := func(target **int, value int) {
+		if *target == nil || **target < value {
+			*target = &value
+		}
+	}
+	atMost := func(target **int, value int) {
+		if *target == nil || **target > value {
+			*target = &value
+		}
+	}
+
+	for _, term := range terms {
+		switch t := term.(type) {
+		case *FlagExpr:
+			if t.Field == "is_palindrome" && f.IsPalindrome == nil {
+				value := true
+				f.IsPalindrome = &value
+			}
+		case *NotExpr:
+			if flag, ok := t.Operand.(*FlagExpr); ok && flag.Field == "is_palindrome" && f.IsPalindrome == nil {
+				value := false
+				f.IsPalindrome = &value
+			}
+		case *BetweenExpr:
+			if t.Field == "length" {
+				atLeast(&f.MinLength, t.Low)
+				atMost(&f.MaxLength, t.High)
+			}
+		case *CompareExpr:
+			switch {
+			case t.Field == "length" && slices.Contains([]string{">=", "="}, t.Op):
+				atLeast(&f.MinLength, t.Value)
+			case t.Field == "length" && t.Op == ">" && t.Value < math.MaxInt:
+				atLeast(&f.MinLength, t.Value+1)
+			}
+			switch {
+			case t.Field == "length" && slices.Contains([]string{"<=", "="}, t.Op):
+				atMost(&f.MaxLength, t.Value)
+			case t.Field == "length" && t.Op == "<" && t.Value > math.MinInt:
+				atMost(&f.MaxLength, t.Value-1)
+			case t.Field == "word_count" && t.Op == "=" && f.WordCount == nil:
+				value := t.Value
+				f.WordCount = &value
+			}
+		case *ContainsExpr:
+			if utf8.RuneCountInString(t.Substring) == 1 && f.ContainsCharacter == "" {
+				f.ContainsCharacter = t.Substring
+			}
+		}
+	}
+	return f
+}
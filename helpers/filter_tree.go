@@ -0,0 +1,607 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// FilterTree is a boolean combination of filters, as produced by
+// ParseNaturalLanguageQuery. A string matches when it satisfies the Filter,
+// the Expression (a filter expression as in the q parameter), every tree in
+// And, at least one tree in Or when there are any, and not the tree in Not.
+//
+// The map form used in interpreted_query.parsed_filters keeps the GET
+// /strings parameters at the top level and adds "q", "and", "or" and "not":
+//
+//	{"is_palindrome": true, "or": [{"min_length": 5}, {"not": {"contains_character": "z"}}]}
+type FilterTree struct {
+	Filter     Filter
+	Expression ExprNode
+	And        []FilterTree
+	Or         []FilterTree
+	Not        *FilterTree
+}
+
+// maxBranches bounds the disjunctive normal form built by Conflicts. Larger
+// trees are assumed to be satisfiable.
+const maxBranches = 256
+
+// Validate runs Filter.Validate on the filter of every node of the tree
+func (t FilterTree) Validate() error {
+	if err := t.Filter.Validate(); err != nil {
+		return err
+	}
+	for _, sub := range append(slices.Clip(t.And), t.Or...) {
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+	}
+	if t.Not != nil {
+		return t.Not.Validate()
+	}
+	return nil
+}
+
+// FilterTreeFromMap converts the map form of a filter tree. Nested trees
+// may be given as map[string]interface{} values, or lists of them, as
+// produced by encoding/json.
+func FilterTreeFromMap(filters map[string]interface{}) (FilterTree, error) {
+	var t FilterTree
+	leaf := make(map[string]interface{})
+	for key, value := range filters {
+		switch key {
+		case "q":
+			source, ok := value.(string)
+			if !ok {
+				return t, fmt.Errorf("q must be a string, got %T", value)
+			}
+			expr, err := ParseFilterExpression(source)
+			if err != nil {
+				return t, err
+			}
+			t.Expression = expr
+		case "and", "or":
+			branches, err := treeList(key, value)
+			if err != nil {
+				return t, err
+			}
+			if key == "and" {
+				t.And = branches
+			} else {
+				t.Or = branches
+			}
+		case "not":
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return t, fmt.Errorf("not must be an object, got %T", value)
+			}
+			sub, err := FilterTreeFromMap(m)
+			if err != nil {
+				return t, err
+			}
+			t.Not = &sub
+		default:
+			leaf[key] = value
+		}
+	}
+
+	var err error
+	t.Filter, err = FilterFromMap(leaf)
+	return t, err
+}
+
+func treeList(key string, value interface{}) ([]FilterTree, error) {
+	var maps []map[string]interface{}
+	switch list := value.(type) {
+	case []map[string]interface{}:
+		maps = list
+	case []interface{}:
+		for _, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of objects, got a %T", key, item)
+			}
+			maps = append(maps, m)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a list, got %T", key, value)
+	}
+
+	trees := make([]FilterTree, len(maps))
+	for i, m := range maps {
+		var err error
+		if trees[i], err = FilterTreeFromMap(m); err != nil {
+			return nil, err
+		}
+	}
+	return trees, nil
+}
+
+// ToMap returns the map form of the tree
+func (t FilterTree) ToMap() map[string]interface{} {
+	m := t.Filter.ToMap()
+	if t.Expression != nil {
+		m["q"] = t.Expression.String()
+	}
+	list := func(trees []FilterTree) []interface{} {
+		maps := make([]interface{}, len(trees))
+		for i, sub := range trees {
+			maps[i] = sub.ToMap()
+		}
+		return maps
+	}
+	if len(t.And) > 0 {
+		m["and"] = list(t.And)
+	}
+	if len(t.Or) > 0 {
+		m["or"] = list(t.Or)
+	}
+	if t.Not != nil {
+		m["not"] = t.Not.ToMap()
+	}
+	return m
+}
+
+// Match reports whether a string satisfies the tree
+func (t FilterTree) Match(r Response) bool {
+	if !t.Filter.Match(r) {
+		return false
+	}
+	if t.Expression != nil && !t.Expression.Match(r) {
+		return false
+	}
+	for _, sub := range t.And {
+		if !sub.Match(r) {
+			return false
+		}
+	}
+	if len(t.Or) > 0 && !slices.ContainsFunc(t.Or, func(sub FilterTree) bool { return sub.Match(r) }) {
+		return false
+	}
+	return t.Not == nil || !t.Not.Match(r)
+}
+
+// Apply returns the strings that satisfy the tree, in order
+func (t FilterTree) Apply(data []Response) []Response {
+	filtered := make([]Response, 0)
+	for _, item := range data {
+		if t.Match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// PushDown returns a Filter matching a superset of the tree, for stores
+// to evaluate with their indexes. Only the parts every match must satisfy
+// contribute; callers still run Match on what it selects.
+func (t FilterTree) PushDown() Filter {
+	f := t.Filter
+	if t.Expression != nil {
+		f = f.WithExpression(t.Expression)
+	}
+	for _, sub := range t.And {
+		f = f.WithExpression(&AndExpr{Terms: sub.PushDown().terms()})
+	}
+	return f
+}
+
+// terms returns the filter as expression predicates
+func (f Filter) terms() []ExprNode {
+	var terms []ExprNode
+	if f.IsPalindrome != nil {
+		var flag ExprNode = &FlagExpr{Field: "is_palindrome"}
+		if !*f.IsPalindrome {
+			flag = &NotExpr{Operand: flag}
+		}
+		terms = append(terms, flag)
+	}
+	if f.MinLength != nil {
+		terms = append(terms, &CompareExpr{Field: "length", Op: ">=", Value: *f.MinLength})
+	}
+	if f.MaxLength != nil {
+		terms = append(terms, &CompareExpr{Field: "length", Op: "<=", Value: *f.MaxLength})
+	}
+	if f.WordCount != nil {
+		terms = append(terms, &CompareExpr{Field: "word_count", Op: "=", Value: *f.WordCount})
+	}
+	if f.ContainsCharacter != "" {
+		terms = append(terms, &ContainsExpr{Substring: f.ContainsCharacter})
+	}
+	return terms
+}
+
+// expr returns the whole tree as one filter expression
+func (t FilterTree) expr() ExprNode {
+	terms := t.Filter.terms()
+	if t.Expression != nil {
+		terms = append(terms, t.Expression)
+	}
+	for _, sub := range t.And {
+		terms = append(terms, sub.expr())
+	}
+	if len(t.Or) > 0 {
+		or := &OrExpr{}
+		for _, sub := range t.Or {
+			or.Terms = append(or.Terms, sub.expr())
+		}
+		terms = append(terms, or)
+	}
+	if t.Not != nil {
+		terms = append(terms, &NotExpr{Operand: t.Not.expr()})
+	}
+	return &AndExpr{Terms: terms}
+}
+
+// Conflicts lists why no stored string can satisfy the tree, or returns nil
+// when it is satisfiable. The tree is expanded into its alternatives (its
+// disjunctive normal form) and is only conflicting when every alternative
+// is; the reasons of all of them are returned.
+func (t FilterTree) Conflicts() []string {
+	branches, ok := disjunctiveForm(t.expr(), false)
+	if !ok {
+		return nil
+	}
+	// The alternatives share one budget of values to try against classes
+	budget := classScanLimit
+	var conflicts []string
+	for _, branch := range branches {
+		reasons := branchConflicts(branch, &budget)
+		if len(reasons) == 0 {
+			return nil
+		}
+		for _, reason := range reasons {
+			if !slices.Contains(conflicts, reason) {
+				conflicts = append(conflicts, reason)
+			}
+		}
+	}
+	return conflicts
+}
+
+// disjunctiveForm expands an expression into alternatives, each a list of
+// predicates that must all hold, pushing negations down onto predicates.
+// It gives up past maxBranches alternatives.
+func disjunctiveForm(node ExprNode, negated bool) ([][]ExprNode, bool) {
+	switch n := node.(type) {
+	case *NotExpr:
+		return disjunctiveForm(n.Operand, !negated)
+	case *AndExpr, *OrExpr:
+		var terms []ExprNode
+		conjunction := true
+		if and, ok := n.(*AndExpr); ok {
+			terms = and.Terms
+		} else {
+			terms, conjunction = n.(*OrExpr).Terms, false
+		}
+		// De Morgan: a negated AND is an OR of negations and vice versa
+		if negated {
+			conjunction = !conjunction
+		}
+
+		if !conjunction {
+			var branches [][]ExprNode
+			for _, term := range terms {
+				sub, ok := disjunctiveForm(term, negated)
+				if !ok || len(branches)+len(sub) > maxBranches {
+					return nil, false
+				}
+				branches = append(branches, sub...)
+			}
+			return branches, true
+		}
+
+		branches := [][]ExprNode{nil}
+		for _, term := range terms {
+			sub, ok := disjunctiveForm(term, negated)
+			if !ok || len(branches)*len(sub) > maxBranches {
+				return nil, false
+			}
+			product := make([][]ExprNode, 0, len(branches)*len(sub))
+			for _, left := range branches {
+				for _, right := range sub {
+					product = append(product, append(slices.Clip(left), right...))
+				}
+			}
+			branches = product
+		}
+		return branches, true
+	case *BetweenExpr:
+		if negated {
+			return [][]ExprNode{
+				{&CompareExpr{Field: n.Field, Op: "<", Value: n.Low}},
+				{&CompareExpr{Field: n.Field, Op: ">", Value: n.High}},
+			}, true
+		}
+	}
+	if negated {
+		return [][]ExprNode{{negateTerm(node)}}, true
+	}
+	return [][]ExprNode{{node}}, true
+}
+
+// branchConflicts lists the contradictions between predicates that must
+// all hold, drawing on budget to check number classes
+func branchConflicts(predicates []ExprNode, budget *int) []string {
+	var conflicts []string
+	type bounds struct{ low, high int }
+	ranges := make(map[string]*bounds)
+	excluded := make(map[string][]int)
+	rangeOf := func(field string) *bounds {
+		if ranges[field] == nil {
+			ranges[field] = &bounds{0, math.MaxInt}
+		}
+		return ranges[field]
+	}
+	// flags holds the values required of each flag predicate, such as
+	// is_palindrome or is_palindrome('none')
+	flags := make(map[string][]bool)
+	var contains, excludes []string
+	classes := make(map[string][]string)
+	notClasses := make(map[string][]string)
+
+	for _, predicate := range predicates {
+		switch p := predicate.(type) {
+		case *FlagExpr, *PalindromeExpr:
+			flags[p.String()] = append(flags[p.String()], true)
+		case *BetweenExpr:
+			r := rangeOf(p.Field)
+			r.low, r.high = max(r.low, p.Low), min(r.high, p.High)
+		case *CompareExpr:
+			r := rangeOf(p.Field)
+			switch p.Op {
+			case "=":
+				r.low, r.high = max(r.low, p.Value), min(r.high, p.Value)
+			case ">":
+				if p.Value == math.MaxInt {
+					conflicts = append(conflicts, fmt.Sprintf("%s cannot be more than %d", p.Field, p.Value))
+					break
+				}
+				r.low = max(r.low, p.Value+1)
+			case ">=":
+				r.low = max(r.low, p.Value)
+			case "<":
+				if p.Value == math.MinInt {
+					conflicts = append(conflicts, fmt.Sprintf("%s cannot be less than %d", p.Field, p.Value))
+					break
+				}
+				r.high = min(r.high, p.Value-1)
+			case "<=":
+				r.high = min(r.high, p.Value)
+			case "!=":
+				excluded[p.Field] = append(excluded[p.Field], p.Value)
+			}
+		case *ContainsExpr:
+			contains = append(contains, p.Substring)
+		case *NumberClassExpr:
+			classes[p.Field] = append(classes[p.Field], p.Class)
+		case *NotExpr:
+			switch operand := p.Operand.(type) {
+			case *FlagExpr, *PalindromeExpr:
+				flags[operand.String()] = append(flags[operand.String()], false)
+			case *ContainsExpr:
+				excludes = append(excludes, operand.Substring)
+			case *NumberClassExpr:
+				notClasses[operand.Field] = append(notClasses[operand.Field], operand.Class)
+			}
+		}
+	}
+
+	var contradicted []string
+	for flag, values := range flags {
+		if slices.Contains(values, true) && slices.Contains(values, false) {
+			contradicted = append(contradicted, flag)
+		}
+	}
+	slices.Sort(contradicted)
+	for _, flag := range contradicted {
+		conflicts = append(conflicts, fmt.Sprintf("%s is required to be both true and false", flag))
+	}
+	for _, field := range FilterableProperties(FilterNumber) {
+		r, ok := ranges[field]
+		if !ok {
+			continue
+		}
+		if r.low > r.high {
+			conflicts = append(conflicts, fmt.Sprintf("%s cannot be both at least %d and at most %d", field, r.low, r.high))
+		} else if r.low == r.high && slices.Contains(excluded[field], r.low) {
+			conflicts = append(conflicts, fmt.Sprintf("%s is required to be both %d and not %d", field, r.low, r.low))
+		}
+	}
+	for _, field := range FilterableProperties(FilterNumber) {
+		r := bounds{0, math.MaxInt}
+		if ranges[field] != nil {
+			r = *ranges[field]
+		}
+		conflicts = append(conflicts, classConflicts(field, r.low, r.high, excluded[field], classes[field], notClasses[field], budget)...)
+	}
+	for _, substring := range contains {
+		if slices.Contains(excludes, substring) {
+			conflicts = append(conflicts, fmt.Sprintf("strings cannot both contain and not contain %q", substring))
+		}
+	}
+
+	// The remaining checks relate fields to the length; Filter.Conflicts
+	// covers the palindrome, word count and character rules
+	length := rangeOf("length")
+	var f Filter
+	if palindrome := flags["is_palindrome"]; len(palindrome) > 0 {
+		f.IsPalindrome = &palindrome[0]
+	}
+	if length.low > 0 && length.low <= length.high {
+		f.MinLength = &length.low
+	}
+	if length.high < math.MaxInt {
+		f.MaxLength = &length.high
+	}
+	if words, ok := ranges["word_count"]; ok {
+		// The fewest words a match could have decides the length it needs
+		f.WordCount = &words.low
+		if words.low == 0 && words.high > 0 {
+			f.WordCount = nil
+		}
+	}
+	if len(contains) > 0 {
+		f.ContainsCharacter = contains[0]
+	}
+	conflicts = append(conflicts, f.Conflicts()...)
+
+	if unique, ok := ranges["unique_characters"]; ok && unique.low > length.high {
+		conflicts = append(conflicts, fmt.Sprintf("%d unique characters need at least %d characters but length is at most %d", unique.low, unique.low, length.high))
+	}
+	// Every grapheme holds at least one rune and every rune at least one byte
+	for _, pair := range [][2]string{{"length", "rune_count"}, {"rune_count", "byte_length"}, {"length", "byte_length"}} {
+		fewer, more := ranges[pair[0]], ranges[pair[1]]
+		if fewer != nil && more != nil && fewer.low > more.high {
+			conflicts = append(conflicts, fmt.Sprintf("%s of at least %d cannot fit in a %s of at most %d", pair[0], fewer.low, pair[1], more.high))
+		}
+	}
+	// A word is no longer than its string, and the shortest word no longer
+	// than the longest
+	for _, field := range []string{"shortest_word_length", "longest_word_length"} {
+		if word, ok := ranges[field]; ok && word.low > length.high {
+			conflicts = append(conflicts, fmt.Sprintf("%s of at least %d cannot fit in a length of at most %d", field, word.low, length.high))
+		}
+	}
+	if shortest, longest := ranges["shortest_word_length"], ranges["longest_word_length"]; shortest != nil && longest != nil && shortest.low > longest.high {
+		conflicts = append(conflicts, fmt.Sprintf("shortest_word_length of at least %d cannot exceed a longest_word_length of at most %d", shortest.low, longest.high))
+	}
+	for _, substring := range contains {
+		if CountGraphemes(substring) > length.high {
+			conflicts = append(conflicts, fmt.Sprintf("%q is longer than the maximum length %d", substring, length.high))
+		}
+	}
+	return conflicts
+}
+
+// classScanLimit bounds how many values Conflicts tries against number
+// classes, over all alternatives and fields, before assuming the class
+// constraints left can be met. Classes with few members are decided from
+// their members, so only primes and their complements are ever walked, and
+// a walk ends at the next prime or composite past each excluded value: the
+// limit is a backstop against long lists of excluded values.
+const classScanLimit = 1 << 12
+
+// perfectInts are the perfect numbers an int64 holds. They are all even: no
+// odd perfect number is below 10^1500.
+var perfectInts = []int64{6, 28, 496, 8128, 33550336, 8589869056, 137438691328, 2305843008139952128}
+
+// armstrongInts are the Armstrong numbers an int64 holds
+var armstrongInts = []int64{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 153, 370, 371, 407, 1634, 8208, 9474, 54748, 92727, 93084,
+	548834, 1741725, 4210818, 9800817, 9926315, 24678050, 24678051, 88593477, 146511208,
+	472335975, 534494836, 912985153, 4679307774, 32164049650, 32164049651, 40028394225,
+	42678290603, 44708635679, 49388550606, 82693916578, 94204591914, 28116440335967,
+	4338281769391370, 4338281769391371, 21897142587612075, 35641594208964132,
+	35875699062250035, 1517841543307505039, 3289582984443187032, 4498128791164624869,
+	4929273885928088826,
+}
+
+// classConflicts reports when no value of a field in [low, high], other
+// than the excluded ones, is in every class of in and none of out. Perfect
+// and Armstrong numbers are decided from their members, even primes from
+// 2, and parity from the class names. Other values are walked one by one,
+// stepping over the wrong parity, while budget lasts.
+func classConflicts(field string, low, high int, excluded []int, in, out []string, budget *int) []string {
+	if len(in) == 0 && len(out) == 0 || low > high {
+		return nil
+	}
+	for _, class := range in {
+		if slices.Contains(out, class) {
+			return []string{fmt.Sprintf("%s is required to be both %s and not %s", field, class, class)}
+		}
+	}
+	if slices.Contains(in, "even") && slices.Contains(in, "odd") {
+		return []string{fmt.Sprintf("%s cannot be both even and odd", field)}
+	}
+	if slices.Contains(out, "even") && slices.Contains(out, "odd") {
+		return []string{fmt.Sprintf("%s cannot be neither even nor odd", field)}
+	}
+
+	// fits reports whether n meets the constraints, trusting that it is in
+	// the class known
+	fits := func(n int, known string) bool {
+		if n < low || n > high || slices.Contains(excluded, n) {
+			return false
+		}
+		for _, class := range in {
+			if class != known && !numberClasses[class](n) {
+				return false
+			}
+		}
+		for _, class := range out {
+			if numberClasses[class](n) {
+				return false
+			}
+		}
+		return true
+	}
+	fitsAny := func(members []int64, class string) bool {
+		for _, n := range members {
+			if n <= math.MaxInt && fits(int(n), class) {
+				return true
+			}
+		}
+		return false
+	}
+	even := slices.Contains(in, "even") || slices.Contains(out, "odd")
+	odd := slices.Contains(in, "odd") || slices.Contains(out, "even")
+	switch {
+	case slices.Contains(in, "perfect"):
+		if fitsAny(perfectInts, "perfect") {
+			return nil
+		}
+	case slices.Contains(in, "armstrong"):
+		if fitsAny(armstrongInts, "armstrong") {
+			return nil
+		}
+	case slices.Contains(in, "prime") && even:
+		if fits(2, "") {
+			return nil
+		}
+	default:
+		n, step := low, 1
+		if even || odd {
+			step = 2
+			if n%2 == 0 != even {
+				n++
+			}
+		}
+		for ; n >= low && n <= high; n += step {
+			if *budget == 0 {
+				// Too wide to rule out
+				return nil
+			}
+			*budget--
+			if fits(n, "") {
+				return nil
+			}
+			if n > high-step {
+				break
+			}
+		}
+	}
+
+	if low == high {
+		for _, class := range in {
+			if !numberClasses[class](low) {
+				return []string{fmt.Sprintf("%s is %d, which is not %s", field, low, class)}
+			}
+		}
+		for _, class := range out {
+			if numberClasses[class](low) {
+				return []string{fmt.Sprintf("%s is %d, which is %s", field, low, class)}
+			}
+		}
+	}
+	description := slices.Clone(in)
+	for _, class := range out {
+		description = append(description, "not "+class)
+	}
+	if low == 0 && high == math.MaxInt {
+		return []string{fmt.Sprintf("no %s is %s", field, strings.Join(description, " and "))}
+	}
+	return []string{fmt.Sprintf("no %s between %d and %d is %s", field, low, high, strings.Join(description, " and "))}
+}
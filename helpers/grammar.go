@@ -0,0 +1,276 @@
+package helpers
+
+import (
+	"embed"
+	"fmt"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Grammar reads natural-language queries in one language into the shared
+// FilterTree model. English, French, Spanish and Yoruba are built in;
+// RegisterGrammar adds or replaces a language.
+//
+// The built-in languages share the clause grammar in natural_language.go.
+// Each is a lexicon overlay, lexicon.<language>.yaml, layered over the
+// active English lexicon: it adds the language's words to each role and,
+// through synonyms, rewrites its fixed phrases into the grammar's ("au
+// moins" is "at least", "láàrin" is "between"). Because English stays
+// underneath, canonical queries, which are always English, read the same
+// in every language.
+type Grammar interface {
+	// Language is the grammar's BCP 47 language tag, such as "fr"
+	Language() string
+	// Interpret parses a query the way InterpretNaturalLanguageQuery does,
+	// setting the Interpretation's Language
+	Interpret(query string) (Interpretation, error)
+}
+
+//go:embed lexicon.*.yaml
+var lexiconOverlays embed.FS
+
+// lexiconGrammar is a Grammar driven by a lexicon overlay on the active
+// lexicon. English has no overlay.
+type lexiconGrammar struct {
+	language string
+	overlay  Lexicon
+
+	mu sync.Mutex
+	// compiled is the overlay merged onto base, the active lexicon it was
+	// last built from
+	base, compiled *compiledLexicon
+}
+
+var grammars = struct {
+	sync.RWMutex
+	list []Grammar
+}{list: builtinGrammars()}
+
+func builtinGrammars() []Grammar {
+	list := []Grammar{&lexiconGrammar{language: "en"}}
+	for _, tag := range []string{"fr", "es", "yo"} {
+		data, err := lexiconOverlays.ReadFile("lexicon." + tag + ".yaml")
+		if err != nil {
+			panic(fmt.Sprintf("built-in %s lexicon: %v", tag, err))
+		}
+		overlay, err := ParseLexicon(data, "yaml")
+		if err != nil {
+			panic(fmt.Sprintf("built-in %s lexicon: %v", tag, err))
+		}
+		list = append(list, &lexiconGrammar{language: tag, overlay: overlay})
+	}
+	return list
+}
+
+// NewLexiconGrammar returns a Grammar for a language whose words are given
+// by a lexicon overlay on the active English lexicon, like the built-in
+// French, Spanish and Yoruba grammars
+func NewLexiconGrammar(language string, overlay Lexicon) Grammar {
+	return &lexiconGrammar{language: language, overlay: overlay}
+}
+
+// RegisterGrammar adds a grammar, replacing any grammar for the same
+// language. A lexicon grammar must combine with the active lexicon into a
+// valid one.
+func RegisterGrammar(g Grammar) error {
+	tag, err := baseLanguage(g.Language())
+	if err != nil {
+		return err
+	}
+	if tag != g.Language() {
+		return fmt.Errorf("grammar language %q is not a base language tag such as %q", g.Language(), tag)
+	}
+	if lg, ok := g.(*lexiconGrammar); ok && lg.language != "en" {
+		base := activeLexicon.Load().loaded.Lexicon
+		if err := base.Merge(lg.overlay).Validate(); err != nil {
+			return fmt.Errorf("%s lexicon: %w", tag, err)
+		}
+	}
+
+	grammars.Lock()
+	defer grammars.Unlock()
+	for i, existing := range grammars.list {
+		if existing.Language() == tag {
+			grammars.list[i] = g
+			return nil
+		}
+	}
+	grammars.list = append(grammars.list, g)
+	return nil
+}
+
+// Languages lists the languages with a grammar, English first
+func Languages() []string {
+	grammars.RLock()
+	defer grammars.RUnlock()
+	languages := make([]string, len(grammars.list))
+	for i, g := range grammars.list {
+		languages[i] = g.Language()
+	}
+	return languages
+}
+
+// LookupGrammar finds the grammar for a language tag; regional tags such
+// as "fr-CA" use the grammar of their base language
+func LookupGrammar(tag string) (Grammar, bool) {
+	base, err := baseLanguage(tag)
+	if err != nil {
+		return nil, false
+	}
+	grammars.RLock()
+	defer grammars.RUnlock()
+	for _, g := range grammars.list {
+		if g.Language() == base {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
+// MatchGrammar picks the grammar an Accept-Language header prefers, or
+// reports false when it names no supported language
+func MatchGrammar(acceptLanguage string) (Grammar, bool) {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return nil, false
+	}
+	supported := Languages()
+	candidates := make([]language.Tag, len(supported))
+	for i, tag := range supported {
+		candidates[i] = language.Make(tag)
+	}
+	_, index, confidence := language.NewMatcher(candidates).Match(tags...)
+	if confidence == language.No {
+		return nil, false
+	}
+	return LookupGrammar(supported[index])
+}
+
+func baseLanguage(tag string) (string, error) {
+	parsed, err := language.Parse(tag)
+	if err != nil {
+		return "", fmt.Errorf("invalid language tag %q", tag)
+	}
+	base, _ := parsed.Base()
+	return base.String(), nil
+}
+
+// DetectLanguage returns the language whose grammar reads the query best
+func DetectLanguage(query string) string {
+	g, _, _ := detect(query)
+	return g.Language()
+}
+
+// detect interprets the query in every language and keeps the best
+// reading: a successful parse over a failed one, then the higher
+// confidence, then the one that recognised more words. Ties go to the
+// language registered first, so queries every grammar reads alike stay
+// English.
+func detect(query string) (Grammar, Interpretation, error) {
+	grammars.RLock()
+	list := grammars.list
+	grammars.RUnlock()
+
+	var best Grammar
+	var bestInterpretation Interpretation
+	var bestErr error
+	for _, g := range list {
+		var interpretation Interpretation
+		var err error
+		if lg, ok := g.(*lexiconGrammar); ok {
+			// Suggestions are only worked out for the winner
+			interpretation, err = lg.interpret(query)
+		} else {
+			interpretation, err = g.Interpret(query)
+		}
+		if best == nil || readsBetter(interpretation, err, bestInterpretation, bestErr) {
+			best, bestInterpretation, bestErr = g, interpretation, err
+		}
+	}
+	return best, bestInterpretation, bestErr
+}
+
+func readsBetter(a Interpretation, aErr error, b Interpretation, bErr error) bool {
+	if (aErr == nil) != (bErr == nil) {
+		return aErr == nil
+	}
+	if a.Confidence != b.Confidence {
+		return a.Confidence > b.Confidence
+	}
+	return a.recognized > b.recognized
+}
+
+// detectAndInterpret interprets a query in the language DetectLanguage
+// picks, with suggestions when asked for
+func detectAndInterpret(query string, suggest bool) (Interpretation, error) {
+	g, interpretation, err := detect(query)
+	if lg, ok := g.(*lexiconGrammar); ok && suggest {
+		lg.suggest(query, &interpretation, err)
+	}
+	return interpretation, err
+}
+
+func (g *lexiconGrammar) Language() string { return g.language }
+
+func (g *lexiconGrammar) Interpret(query string) (Interpretation, error) {
+	interpretation, err := g.interpret(query)
+	g.suggest(query, &interpretation, err)
+	return interpretation, err
+}
+
+func (g *lexiconGrammar) interpret(query string) (Interpretation, error) {
+	interpretation, err := interpret(g.lexicon(), query)
+	interpretation.Language = g.language
+	return interpretation, err
+}
+
+func (g *lexiconGrammar) suggest(query string, interpretation *Interpretation, err error) {
+	if err != nil || interpretation.Confidence < LowConfidence {
+		interpretation.Suggestions = suggestQueries(g.lexicon(), query, interpretation.Confidence)
+	}
+}
+
+// lexicon returns the overlay merged onto the active lexicon, rebuilding
+// it after the active lexicon changes. SetLexicon has already checked that
+// the two combine.
+func (g *lexiconGrammar) lexicon() *compiledLexicon {
+	base := activeLexicon.Load()
+	if g.language == "en" {
+		return base
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.base != base {
+		source := fmt.Sprintf("%s + built-in %s", base.loaded.Source, g.language)
+		g.base, g.compiled = base, compileLexicon(base.loaded.Lexicon.Merge(g.overlay), g.language, source)
+	}
+	return g.compiled
+}
+
+// lexiconGrammars lists the registered grammars with a lexicon overlay
+func lexiconGrammars() []*lexiconGrammar {
+	grammars.RLock()
+	defer grammars.RUnlock()
+	var list []*lexiconGrammar
+	for _, g := range grammars.list {
+		if lg, ok := g.(*lexiconGrammar); ok && lg.language != "en" {
+			list = append(list, lg)
+		}
+	}
+	return list
+}
+
+// LanguageLexicon returns the lexicon a language's grammar reads queries
+// with, or false for languages without a lexicon grammar
+func LanguageLexicon(tag string) (LoadedLexicon, bool) {
+	g, ok := LookupGrammar(tag)
+	if !ok {
+		return LoadedLexicon{}, false
+	}
+	lg, ok := g.(*lexiconGrammar)
+	if !ok {
+		return LoadedLexicon{}, false
+	}
+	return lg.lexicon().loaded, true
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"unicode/utf8"
+
+	"github.com/clipperhouse/uax29/v2/graphemes"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Graphemes splits s into extended grapheme clusters, the user-perceived
+// characters of UAX #29: "e" plus a combining accent, a flag, a ZWJ emoji
+// family and a Devanagari conjunct are one cluster each. The segmenter is
+// generated from the Unicode data files and checked against the official
+// GraphemeBreakTest cases.
+func Graphemes(s string) []string {
+	clusters := make([]string, 0, len(s))
+	for iter := graphemes.FromString(s); iter.Next(); {
+		clusters = append(clusters, iter.Value())
+	}
+	return clusters
+}
+
+// normalizedGraphemes splits s into clusters after NFC normalization, so a
+// precomposed "é" and "e" plus a combining accent compare equal
+func normalizedGraphemes(s string) []string {
+	return Graphemes(norm.NFC.String(s))
+}
+
+// CountGraphemes returns the number of user-perceived characters in s
+func CountGraphemes(s string) int {
+	n := 0
+	for iter := graphemes.FromString(s); iter.Next(); {
+		n++
+	}
+	return n
+}
+
+// CountRunes returns the number of Unicode code points in s
+func CountRunes(s string) int {
+	return utf8.RuneCountInString(s)
+}
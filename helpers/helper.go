@@ -3,9 +3,7 @@ package helpers
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"fmt"
 	"slices"
-	"strconv"
 	"strings"
 	"time"
 
@@ -14,17 +12,38 @@ import (
 
 type StringApiHandler struct {
 	String string
+	// PalindromeMode decides is_palindrome; nil uses DefaultPalindromeMode
+	PalindromeMode *PalindromeMode
+
+	// words is the word analysis, shared by the analyzers that read it
+	words *WordAnalysis
 }
 
 type CharacterFrequencyMap map[string]int
 
+// PropertiesMap is the analysis of one string. Length, uniqueness, the
+// frequency map and palindromes count grapheme clusters (user-perceived
+// characters); ByteLength and RuneCount give the other two measures.
+//
+// Set and Value read and write every property by name. The fields are the
+// typed view of the built-in ones, which the stores index and keep in
+// columns of their own; adding a built-in means adding its field and
+// binding it in builtinAnalyzers.
 type PropertiesMap struct {
 	Length                int                   `json:"length"`
+	ByteLength            int                   `json:"byte_length"`
+	RuneCount             int                   `json:"rune_count"`
 	IsPalindrome          bool                  `json:"is_palindrome"`
+	PalindromeMode        string                `json:"palindrome_mode"`
 	UniqueCharacters      int                   `json:"unique_characters"`
 	WordCount             int                   `json:"word_Count"`
 	Sha256Hash            string                `json:"sha256_hash"`
 	CharacterFrequencyMap CharacterFrequencyMap `json:"character_frequency_map"`
+	// WordAnalysis is computed lazily, and nil until it is
+	WordAnalysis *WordAnalysis `json:"word_analysis,omitempty"`
+	// Extra holds the properties of registered analyzers, which are
+	// written next to the others in JSON
+	Extra map[string]any `json:"-"`
 }
 
 type Response struct {
@@ -34,19 +53,34 @@ type Response struct {
 	CreatedAt  string        `json:"created_at"`
 }
 
+// Analyze runs every registered analyzer, built-ins first
 func (h *StringApiHandler) Analyze() PropertiesMap {
-	return PropertiesMap{
-		Length:                len(h.String),
-		IsPalindrome:          IsPalindrome(h.String),
-		UniqueCharacters:      CountUniqueCharacters(h.String),
-		WordCount:             CountWords(h.String),
-		Sha256Hash:            CalculateSHA256(h.String),
-		CharacterFrequencyMap: CalculateCharacterFrequency(h.String),
+	return h.AnalyzeFields(nil)
+}
+
+// wordAnalysis analyzes the words of h.String once
+func (h *StringApiHandler) wordAnalysis() *WordAnalysis {
+	if h.words == nil {
+		h.words = AnalyzeWords(h.String)
 	}
+	return h.words
+}
+
+func (h *StringApiHandler) palindromeMode() PalindromeMode {
+	if h.PalindromeMode != nil {
+		return *h.PalindromeMode
+	}
+	return DefaultPalindromeMode
 }
 
 func (h *StringApiHandler) GetString() Response {
-	properties := h.Analyze()
+	return h.GetStringFields(nil)
+}
+
+// GetStringFields is GetString computing only the lazy properties among
+// fields
+func (h *StringApiHandler) GetStringFields(fields []string) Response {
+	properties := h.AnalyzeFields(fields)
 	return Response{
 		ID:         properties.Sha256Hash,
 		Value:      h.String,
@@ -66,25 +100,24 @@ func CountCharacters(s string) int {
 	return count
 }
 
+// CountWords counts the runs of non-space characters. The word analysis
+// finds words with the active Tokenizer instead, and can count differently.
 func CountWords(s string) int {
 	words := strings.Fields(s)
 	return len(words)
 }
+
+// IsPalindrome compares grapheme clusters in DefaultPalindromeMode, so
+// accented letters and emoji sequences are never split apart
 func IsPalindrome(s string) bool {
-	string := strings.ToLower(s)
-	string = strings.ReplaceAll(string, " ", "") // Normalize the string
-	length := len(string)
-	for i := 0; i < length/2; i++ {
-		if string[i] != string[length-i-1] {
-			return false
-		}
-	}
-	return true
+	return DefaultPalindromeMode.IsPalindrome(s)
 }
 
+// CountUniqueCharacters counts distinct grapheme clusters after NFC
+// normalization
 func CountUniqueCharacters(s string) int {
-	charSet := make(map[rune]struct{})
-	for _, char := range s {
+	charSet := make(map[string]struct{})
+	for _, char := range normalizedGraphemes(s) {
 		charSet[char] = struct{}{}
 	}
 	return len(charSet)
@@ -97,10 +130,11 @@ func CalculateSHA256(s string) string {
 	return hashHex
 }
 
+// CalculateCharacterFrequency counts each NFC grapheme cluster
 func CalculateCharacterFrequency(s string) map[string]int {
 	frequency := make(map[string]int)
-	for _, char := range s {
-		frequency[string(char)]++
+	for _, char := range normalizedGraphemes(s) {
+		frequency[char]++
 	}
 	return frequency
 }
@@ -121,148 +155,23 @@ func NaturalLanguageFilter(data []Response, naturalLanguageFilter string) []Resp
 	return filtered
 }
 
-// ParseNaturalLanguageQuery parses natural language queries into structured filters
-func ParseNaturalLanguageQuery(query string) (map[string]interface{}, error) {
-	query = strings.ToLower(query)
-	filters := make(map[string]interface{})
-
-	// Check for palindrome-related queries
-	if strings.Contains(query, "palindromic") || strings.Contains(query, "palindrome") {
-		if strings.Contains(query, "not") || strings.Contains(query, "non") {
-			filters["is_palindrome"] = false
-		} else {
-			filters["is_palindrome"] = true
-		}
-	}
-
-	// Check for word count queries
-	if strings.Contains(query, "single word") || strings.Contains(query, "one word") {
-		filters["word_count"] = 1
-	} else if strings.Contains(query, "two word") || strings.Contains(query, "2 word") {
-		filters["word_count"] = 2
-	} else if strings.Contains(query, "three word") || strings.Contains(query, "3 word") {
-		filters["word_count"] = 3
-	}
-
-	// Check for length-related queries
-	if strings.Contains(query, "longer than") {
-		// Extract number from "longer than X characters"
-		parts := strings.Split(query, "longer than")
-		if len(parts) > 1 {
-			numberPart := strings.TrimSpace(parts[1])
-			numberPart = strings.Split(numberPart, " ")[0] // Get just the number
-			if num, err := strconv.Atoi(numberPart); err == nil {
-				filters["min_length"] = num + 1
-			}
-		}
-	} else if strings.Contains(query, "shorter than") {
-		// Extract number from "shorter than X characters"
-		parts := strings.Split(query, "shorter than")
-		if len(parts) > 1 {
-			numberPart := strings.TrimSpace(parts[1])
-			numberPart = strings.Split(numberPart, " ")[0] // Get just the number
-			if num, err := strconv.Atoi(numberPart); err == nil {
-				filters["max_length"] = num - 1
-			}
-		}
-	}
-
-	// Check for character-specific queries
-	if strings.Contains(query, "contain") {
-		// Look for specific character mentions
-		if strings.Contains(query, "letter z") || strings.Contains(query, "character z") {
-			filters["contains_character"] = "z"
-		} else if strings.Contains(query, "letter a") || strings.Contains(query, "character a") {
-			filters["contains_character"] = "a"
-		} else if strings.Contains(query, "letter e") || strings.Contains(query, "character e") {
-			filters["contains_character"] = "e"
-		} else if strings.Contains(query, "letter i") || strings.Contains(query, "character i") {
-			filters["contains_character"] = "i"
-		} else if strings.Contains(query, "letter o") || strings.Contains(query, "character o") {
-			filters["contains_character"] = "o"
-		} else if strings.Contains(query, "letter u") || strings.Contains(query, "character u") {
-			filters["contains_character"] = "u"
-		} else if strings.Contains(query, "first vowel") {
-			filters["contains_character"] = "a"
-		}
-	}
-
-	// If no filters were found, return an error
-	if len(filters) == 0 {
-		return nil, fmt.Errorf("unable to parse query")
-	}
-
-	return filters, nil
-}
-
-// HasConflictingFilters checks if the parsed filters have any conflicts
+// HasConflictingFilters reports whether no string can satisfy the filter
+// tree, i.e. every one of its alternatives is contradictory. Filters that
+// fail validation are not conflicting; see FilterTreeFromMap.
 func HasConflictingFilters(filters map[string]interface{}) bool {
-	// Check for conflicting palindrome settings
-	if val, exists := filters["is_palindrome"]; exists {
-		if val == false {
-			// If explicitly set to false, check if we also have palindrome=true somewhere
-			// This is a basic check - you might want to expand this logic
-		}
-	}
-
-	// Check for conflicting length settings
-	if minLen, minExists := filters["min_length"]; minExists {
-		if maxLen, maxExists := filters["max_length"]; maxExists {
-			if minLen.(int) > maxLen.(int) {
-				return true
-			}
-		}
+	tree, err := FilterTreeFromMap(filters)
+	if err != nil {
+		return false
 	}
-
-	return false
+	return len(tree.Conflicts()) > 0
 }
 
-// ApplyFilters applies the parsed filters to the data
+// ApplyFilters applies the parsed filter tree to the data. Filters that
+// fail validation match nothing.
 func ApplyFilters(data []Response, filters map[string]interface{}) []Response {
-	var filtered []Response
-
-	for _, item := range data {
-		match := true
-
-		// Apply palindrome filter
-		if isPalindrome, exists := filters["is_palindrome"]; exists {
-			if isPalindrome.(bool) != IsPalindrome(item.Value) {
-				match = false
-			}
-		}
-
-		// Apply word count filter
-		if wordCount, exists := filters["word_count"]; exists {
-			if CountWords(item.Value) != wordCount.(int) {
-				match = false
-			}
-		}
-
-		// Apply minimum length filter
-		if minLength, exists := filters["min_length"]; exists {
-			if len(item.Value) < minLength.(int) {
-				match = false
-			}
-		}
-
-		// Apply maximum length filter
-		if maxLength, exists := filters["max_length"]; exists {
-			if len(item.Value) > maxLength.(int) {
-				match = false
-			}
-		}
-
-		// Apply character filter
-		if containsChar, exists := filters["contains_character"]; exists {
-			if !strings.ContainsRune(item.Value, rune(containsChar.(string)[0])) {
-				match = false
-			}
-		}
-
-		if match {
-			filtered = append(filtered, item)
-		}
+	tree, err := FilterTreeFromMap(filters)
+	if err != nil {
+		return []Response{}
 	}
-
-	return filtered
+	return tree.Apply(data)
 }
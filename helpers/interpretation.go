@@ -0,0 +1,213 @@
+package helpers
+
+import (
+	"math"
+	"slices"
+	"unicode/utf8"
+)
+
+// QuerySpan is a stretch of a natural-language query the parser understood.
+// Start and End are rune offsets into the query, End exclusive; Meaning is
+// the filter expression the stretch was read as.
+type QuerySpan struct {
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Text    string `json:"text"`
+	Meaning string `json:"meaning"`
+}
+
+// Interpretation describes how a natural-language query was read. Confidence
+// is the share of meaningful words the parser used, from 0 to 1; filler
+// words such as "strings" or "show me" count neither way. Suggestions holds
+// corrected queries for failed or low-confidence parses. Language is the
+// tag of the grammar that read the query.
+type Interpretation struct {
+	Filter       FilterTree  `json:"-"`
+	Language     string      `json:"language"`
+	Spans        []QuerySpan `json:"spans"`
+	IgnoredWords []string    `json:"ignored_words"`
+	Confidence   float64     `json:"confidence"`
+	Suggestions  []string    `json:"suggestions,omitempty"`
+
+	// recognized counts the words the grammar knew, filler included, to
+	// tell languages apart when their confidence ties
+	recognized int
+}
+
+// LowConfidence is the confidence below which a parse gets suggestions
+const LowConfidence = 0.8
+
+// InterpretNaturalLanguageQuery parses a query, in the language
+// DetectLanguage picks for it, and reports which parts of it were
+// understood. The Interpretation is filled in even when parsing fails, so
+// callers can show what went wrong. Grammar.Interpret reads a query in a
+// given language.
+func InterpretNaturalLanguageQuery(query string) (Interpretation, error) {
+	return detectAndInterpret(query, true)
+}
+
+func interpret(lex *compiledLexicon, query string) (Interpretation, error) {
+	p := newNLParser(lex, query)
+	expr, err := p.parse()
+	interpretation := Interpretation{
+		Spans:        p.spans,
+		IgnoredWords: p.ignored,
+		recognized:   p.consumed + p.neutral,
+	}
+	if interpretation.Spans == nil {
+		interpretation.Spans = []QuerySpan{}
+	}
+	if interpretation.IgnoredWords == nil {
+		interpretation.IgnoredWords = []string{}
+	}
+	if err != nil {
+		return interpretation, err
+	}
+	interpretation.Filter = treeFromExpr(expr)
+	interpretation.Confidence = math.Round(100*float64(p.consumed)/float64(p.consumed+len(p.ignored))) / 100
+	return interpretation, nil
+}
+
+// understood records the tokens read since from as a span starting at the
+// rune offset start
+func (p *nlParser) understood(from, start int, meaning string) {
+	end := p.tokens[p.pos-1].end
+	p.spans = append(p.spans, QuerySpan{Start: start, End: end, Text: string(p.runes[start:end]), Meaning: meaning})
+	p.consumed += p.pos - from
+}
+
+// ignore records a skipped token unless it is punctuation or filler. A
+// verb left over, as in "with at least three words" or Yoruba "ní ọ̀rọ̀
+// méjì" (has two words), is filler too.
+func (p *nlParser) ignore(t nlToken) {
+	if t.kind == nlPunct {
+		return
+	}
+	if t.kind == nlWord && (p.lex.fillers[t.text] || p.lex.boundaries[t.text] || p.lex.isVerb(t.text)) {
+		p.neutral++
+		return
+	}
+	// "hasn't" is two tokens sharing one stretch of the query
+	if t.start < p.ignoredEnd {
+		return
+	}
+	p.ignored = append(p.ignored, string(p.runes[t.start:t.end]))
+	p.ignoredEnd = t.end
+}
+
+// vocabulary lists every word the lexicon and grammar know, sorted
+func (c *compiledLexicon) vocabulary() []string {
+	var words []string
+	for _, set := range []map[string]bool{
+		c.negations, c.boundaries, c.fillers, c.palindromes, c.nonPalindromes, c.containsVerbs,
+		c.excludesVerbs, c.weakVerbs, c.characterNouns, c.articles,
+		wordSet("or", "nor", "at", "least", "most", "up", "minimum", "maximum", "between", "from",
+			"equal", "multiword", "multiple", "several", "single", "vowel", "alphabet", "last", "hundred",
+			"prime", "perfect", "armstrong", "even", "odd", "count", "unique", "distinct"),
+	} {
+		words = append(words, mapKeys(set)...)
+	}
+	for _, numbers := range []map[string]int{nlUnits, nlTeens, nlTens, nlOrdinals} {
+		words = append(words, mapKeys(numbers)...)
+	}
+	words = append(words, mapKeys(nlScales)...)
+	words = append(words, mapKeys(c.units)...)
+	words = append(words, mapKeys(c.wordSuperlatives)...)
+	words = append(words, mapKeys(analyzerNouns(FilterNumber))...)
+	words = append(words, mapKeys(analyzerNouns(FilterFlag))...)
+	words = append(words, mapKeys(c.comparators)...)
+	words = append(words, mapKeys(c.orSuffixes)...)
+	for _, synonym := range c.synonyms {
+		for _, t := range synonym.phrase {
+			if t.kind == nlWord {
+				words = append(words, t.text)
+			}
+		}
+	}
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// suggestQueries spells out the query with each unknown word replaced by
+// the closest known one, and offers it when it reads better than the
+// original
+func suggestQueries(lex *compiledLexicon, query string, confidence float64) []string {
+	vocabulary := lex.vocabulary()
+	known := wordSet(vocabulary...)
+	runes := []rune(query)
+
+	var corrected []rune
+	last, changed := 0, false
+	for _, t := range tokenizeNaturalLanguage(query) {
+		if t.kind != nlWord || known[t.text] || t.start < last {
+			continue
+		}
+		replacement, ok := closestWord(t.text, vocabulary)
+		if !ok {
+			continue
+		}
+		corrected = append(corrected, runes[last:t.start]...)
+		corrected = append(corrected, []rune(replacement)...)
+		last, changed = t.end, true
+	}
+	if !changed {
+		return nil
+	}
+	corrected = append(corrected, runes[last:]...)
+
+	suggestion := string(corrected)
+	if better, err := interpret(lex, suggestion); err != nil || better.Confidence <= confidence {
+		return nil
+	}
+	return []string{suggestion}
+}
+
+// closestWord finds the vocabulary word nearest to word, allowing one edit
+// for words of up to five letters and two for longer ones. Ties go to the
+// word with the same first letter, then to the first alphabetically.
+func closestWord(word string, vocabulary []string) (string, bool) {
+	length := utf8.RuneCountInString(word)
+	if length < 3 {
+		return "", false
+	}
+	limit := 1
+	if length > 5 {
+		limit = 2
+	}
+
+	best, bestDistance := "", limit+1
+	for _, candidate := range vocabulary {
+		distance := editDistance(word, candidate)
+		if distance < bestDistance || (distance == bestDistance && best != "" && candidate[0] == word[0] && best[0] != word[0]) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, bestDistance <= limit
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent letters needed to turn a into b
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := make([][]int, len(s)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(t)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)][len(t)]
+}
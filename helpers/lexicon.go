@@ -0,0 +1,535 @@
+package helpers
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lexicon is the vocabulary of the natural-language parser: the words it
+// accepts in each role and the phrases it rewrites before parsing. The
+// built-in lexicon is lexicon.yaml; LoadLexiconFile reads a replacement.
+type Lexicon struct {
+	Negations          []string                     `json:"negations" yaml:"negations"`
+	Boundaries         []string                     `json:"boundaries" yaml:"boundaries"`
+	Fillers            []string                     `json:"fillers" yaml:"fillers"`
+	PalindromeWords    []string                     `json:"palindrome_words" yaml:"palindrome_words"`
+	NonPalindromeWords []string                     `json:"non_palindrome_words" yaml:"non_palindrome_words"`
+	ContainsVerbs      []string                     `json:"contains_verbs" yaml:"contains_verbs"`
+	ExcludesVerbs      []string                     `json:"excludes_verbs" yaml:"excludes_verbs"`
+	WeakVerbs          []string                     `json:"weak_verbs" yaml:"weak_verbs"`
+	CharacterNouns     []string                     `json:"character_nouns" yaml:"character_nouns"`
+	Articles           []string                     `json:"articles" yaml:"articles"`
+	Units              map[string]string            `json:"units" yaml:"units"`
+	WordSuperlatives   map[string]string            `json:"word_superlatives" yaml:"word_superlatives"`
+	Comparators        map[string]LexiconComparator `json:"comparators" yaml:"comparators"`
+	OrSuffixes         map[string]string            `json:"or_suffixes" yaml:"or_suffixes"`
+	Synonyms           map[string]string            `json:"synonyms" yaml:"synonyms"`
+}
+
+// LexiconComparator is a comparator word: its operator and, for
+// comparatives about size like "longer", the property it implies
+type LexiconComparator struct {
+	Op    string `json:"op" yaml:"op"`
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+}
+
+// LoadedLexicon is a lexicon in use with its language and where and when
+// it was loaded
+type LoadedLexicon struct {
+	Language string  `json:"language"`
+	Source   string  `json:"source"`
+	LoadedAt string  `json:"loaded_at"`
+	Lexicon  Lexicon `json:"lexicon"`
+}
+
+//go:embed lexicon.yaml
+var defaultLexiconYAML []byte
+
+// lexiconRequired are words the grammar and CanonicalQuery write queries
+// with, by class. A lexicon without them could not read its own canonical
+// queries back.
+var lexiconRequired = []struct {
+	class string
+	words []string
+}{
+	{"negations", []string{"not", "non"}},
+	{"boundaries", []string{"and", "that"}},
+	{"palindrome_words", []string{"palindromes", "palindromic"}},
+	{"contains_verbs", []string{"containing"}},
+	{"excludes_verbs", []string{"without"}},
+	{"weak_verbs", []string{"with", "have"}},
+	{"character_nouns", []string{"letter", "digit"}},
+	{"articles", []string{"the"}},
+	{"units", []string{"character", "characters", "word", "words"}},
+	{"word_superlatives", []string{"longest", "shortest"}},
+	{"comparators", []string{"longer", "shorter", "more", "fewer", "exactly"}},
+}
+
+// compiledLexicon is a validated Lexicon in the form the parser reads
+type compiledLexicon struct {
+	loaded LoadedLexicon
+
+	negations, boundaries, fillers      map[string]bool
+	palindromes, nonPalindromes         map[string]bool
+	containsVerbs, excludesVerbs        map[string]bool
+	weakVerbs, characterNouns, articles map[string]bool
+	units, wordSuperlatives             map[string]string
+	comparators                         map[string]LexiconComparator
+	orSuffixes                          map[string]string
+	// synonyms are sorted longest phrase first
+	synonyms []lexiconSynonym
+}
+
+type lexiconSynonym struct {
+	phrase      []nlToken
+	replacement []nlToken
+}
+
+var activeLexicon atomic.Pointer[compiledLexicon]
+
+func init() {
+	lexicon, err := ParseLexicon(defaultLexiconYAML, "yaml")
+	if err != nil {
+		panic(fmt.Sprintf("built-in lexicon: %v", err))
+	}
+	if err := SetLexicon(lexicon, "built-in"); err != nil {
+		panic(fmt.Sprintf("built-in lexicon: %v", err))
+	}
+}
+
+// DefaultLexicon returns the built-in lexicon
+func DefaultLexicon() Lexicon {
+	lexicon, _ := ParseLexicon(defaultLexiconYAML, "yaml")
+	return lexicon
+}
+
+// ActiveLexicon returns the lexicon the parser currently uses
+func ActiveLexicon() LoadedLexicon {
+	return activeLexicon.Load().loaded
+}
+
+// SetLexicon validates a lexicon and makes it the active one. It is the
+// English lexicon, and the base the other languages' lexicons are layered
+// over, so it must also combine with each of them. Queries already being
+// parsed finish with the lexicon they started with.
+func SetLexicon(lexicon Lexicon, source string) error {
+	if err := lexicon.Validate(); err != nil {
+		return err
+	}
+	for _, g := range lexiconGrammars() {
+		if err := lexicon.Merge(g.overlay).Validate(); err != nil {
+			return fmt.Errorf("combined with the %s lexicon: %w", g.language, err)
+		}
+	}
+	activeLexicon.Store(compileLexicon(lexicon, "en", source))
+	return nil
+}
+
+// Merge returns the lexicon with overlay's words added. Word lists are
+// joined; in the maps, overlay's entries win.
+func (l Lexicon) Merge(overlay Lexicon) Lexicon {
+	join := func(base, extra []string) []string {
+		joined := slices.Clone(base)
+		for _, word := range extra {
+			if !slices.Contains(joined, word) {
+				joined = append(joined, word)
+			}
+		}
+		return joined
+	}
+	l.Negations = join(l.Negations, overlay.Negations)
+	l.Boundaries = join(l.Boundaries, overlay.Boundaries)
+	l.Fillers = join(l.Fillers, overlay.Fillers)
+	l.PalindromeWords = join(l.PalindromeWords, overlay.PalindromeWords)
+	l.NonPalindromeWords = join(l.NonPalindromeWords, overlay.NonPalindromeWords)
+	l.ContainsVerbs = join(l.ContainsVerbs, overlay.ContainsVerbs)
+	l.ExcludesVerbs = join(l.ExcludesVerbs, overlay.ExcludesVerbs)
+	l.WeakVerbs = join(l.WeakVerbs, overlay.WeakVerbs)
+	l.CharacterNouns = join(l.CharacterNouns, overlay.CharacterNouns)
+	l.Articles = join(l.Articles, overlay.Articles)
+	l.Units = mergeMaps(l.Units, overlay.Units)
+	l.WordSuperlatives = mergeMaps(l.WordSuperlatives, overlay.WordSuperlatives)
+	l.Comparators = mergeMaps(l.Comparators, overlay.Comparators)
+	l.OrSuffixes = mergeMaps(l.OrSuffixes, overlay.OrSuffixes)
+	l.Synonyms = mergeMaps(l.Synonyms, overlay.Synonyms)
+	return l
+}
+
+func mergeMaps[V any](base, overlay map[string]V) map[string]V {
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]V, len(overlay))
+	}
+	maps.Copy(merged, overlay)
+	return merged
+}
+
+// ParseLexicon decodes a lexicon in "yaml" or "json". Unknown keys are
+// errors, so a misspelled class is not silently ignored.
+func ParseLexicon(data []byte, format string) (Lexicon, error) {
+	var lexicon Lexicon
+	switch format {
+	case "yaml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&lexicon); err != nil {
+			return lexicon, fmt.Errorf("invalid lexicon: %w", err)
+		}
+	case "json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&lexicon); err != nil {
+			return lexicon, fmt.Errorf("invalid lexicon: %w", err)
+		}
+	default:
+		return lexicon, fmt.Errorf("unknown lexicon format %q", format)
+	}
+	return lexicon, nil
+}
+
+// LoadLexiconFile reads a lexicon from a .yaml, .yml or .json file and
+// validates it
+func LoadLexiconFile(path string) (Lexicon, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = "yaml"
+	case ".json":
+		format = "json"
+	default:
+		return Lexicon{}, fmt.Errorf("lexicon %s: extension must be .yaml, .yml or .json", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Lexicon{}, err
+	}
+	lexicon, err := ParseLexicon(data, format)
+	if err != nil {
+		return lexicon, fmt.Errorf("lexicon %s: %w", path, err)
+	}
+	if err := lexicon.Validate(); err != nil {
+		return lexicon, fmt.Errorf("lexicon %s: %w", path, err)
+	}
+	return lexicon, nil
+}
+
+// Validate checks that every entry is a single lowercase word, that no
+// word has two conflicting roles, that units and comparators name known
+// properties and operators, that synonyms do not redefine the words
+// queries are written with, and that those words are all present
+func (l Lexicon) Validate() error {
+	var problems []error
+	report := func(format string, args ...any) { problems = append(problems, fmt.Errorf(format, args...)) }
+
+	checkWord := func(class, word string) {
+		tokens := tokenizeNaturalLanguage(word)
+		if word != strings.ToLower(word) || len(tokens) != 1 || tokens[0].text != foldWord(word) || tokens[0].kind == nlNumber || tokens[0].kind == nlChar {
+			report("%s: %q is not a single lowercase word", class, word)
+		}
+	}
+
+	// A word may only have one of these roles
+	roles := make(map[string]string)
+	for _, class := range []struct {
+		name  string
+		words []string
+	}{
+		{"negations", l.Negations},
+		{"boundaries", l.Boundaries},
+		{"palindrome_words", l.PalindromeWords},
+		{"non_palindrome_words", l.NonPalindromeWords},
+		{"contains_verbs", l.ContainsVerbs},
+		{"excludes_verbs", l.ExcludesVerbs},
+		{"weak_verbs", l.WeakVerbs},
+	} {
+		for _, word := range class.words {
+			checkWord(class.name, word)
+			// Words that differ only in accents are the same word to the parser
+			folded := foldWord(word)
+			if other, ok := roles[folded]; ok && other != class.name {
+				report("%q is in both %s and %s", word, other, class.name)
+			}
+			roles[folded] = class.name
+		}
+	}
+	for _, class := range []struct {
+		name  string
+		words []string
+	}{
+		{"fillers", l.Fillers},
+		{"character_nouns", l.CharacterNouns},
+		{"articles", l.Articles},
+	} {
+		for _, word := range class.words {
+			checkWord(class.name, word)
+		}
+	}
+
+	for word, field := range l.Units {
+		checkWord("units", word)
+		if _, ok := describeUnit(field); !ok {
+			report("units: %q measures unknown property %q, expected length, word_count, byte_length or rune_count", word, field)
+		}
+	}
+	for word, field := range l.WordSuperlatives {
+		checkWord("word_superlatives", word)
+		if a, ok := LookupAnalyzer(field); !ok || blockOf(a) != "word_analysis" || a.FilterType() != FilterNumber {
+			report("word_superlatives: %q names unknown property %q, expected longest_word_length or shortest_word_length", word, field)
+		}
+	}
+	for word, comparator := range l.Comparators {
+		checkWord("comparators", word)
+		if !slices.Contains([]string{">", "<", "="}, comparator.Op) {
+			report("comparators: %q has operator %q, expected >, < or =", word, comparator.Op)
+		}
+		if _, ok := describeUnit(comparator.Field); comparator.Field != "" && !ok {
+			report("comparators: %q implies unknown property %q, expected length, word_count, byte_length or rune_count", word, comparator.Field)
+		}
+	}
+	for word, op := range l.OrSuffixes {
+		checkWord("or_suffixes", word)
+		if op != ">=" && op != "<=" {
+			report("or_suffixes: %q has operator %q, expected >= or <=", word, op)
+		}
+	}
+
+	classes := map[string][]string{
+		"negations":         l.Negations,
+		"boundaries":        l.Boundaries,
+		"palindrome_words":  l.PalindromeWords,
+		"contains_verbs":    l.ContainsVerbs,
+		"excludes_verbs":    l.ExcludesVerbs,
+		"weak_verbs":        l.WeakVerbs,
+		"character_nouns":   l.CharacterNouns,
+		"articles":          l.Articles,
+		"units":             mapKeys(l.Units),
+		"word_superlatives": mapKeys(l.WordSuperlatives),
+		"comparators":       mapKeys(l.Comparators),
+	}
+	required := make(map[string]bool)
+	for _, requirement := range lexiconRequired {
+		for _, word := range requirement.words {
+			required[word] = true
+			if !slices.Contains(classes[requirement.class], word) {
+				report("%s must include %q", requirement.class, word)
+			}
+		}
+	}
+
+	for phrase, replacement := range l.Synonyms {
+		if phrase != strings.ToLower(phrase) || len(tokenizeNaturalLanguage(phrase)) == 0 {
+			report("synonyms: %q is not a lowercase phrase", phrase)
+		} else if required[phrase] {
+			report("synonyms: %q is a word queries are written with and cannot be redefined", phrase)
+		}
+		if len(tokenizeNaturalLanguage(replacement)) == 0 {
+			report("synonyms: %q has an empty replacement", phrase)
+		}
+	}
+
+	// Report problems in a stable order
+	slices.SortFunc(problems, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(problems...)
+}
+
+// isVerb reports whether word introduces a character
+func (c *compiledLexicon) isVerb(word string) bool {
+	return c.containsVerbs[word] || c.excludesVerbs[word] || c.weakVerbs[word]
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// compileLexicon keys every word the way the tokenizer spells it, folded
+// with foldWord
+func compileLexicon(l Lexicon, language, source string) *compiledLexicon {
+	words := func(list []string) map[string]bool {
+		set := make(map[string]bool, len(list))
+		for _, word := range list {
+			set[foldWord(word)] = true
+		}
+		return set
+	}
+	c := &compiledLexicon{
+		loaded:           LoadedLexicon{Language: language, Source: source, LoadedAt: time.Now().UTC().Format(time.RFC3339), Lexicon: l},
+		negations:        words(l.Negations),
+		boundaries:       words(l.Boundaries),
+		fillers:          words(l.Fillers),
+		palindromes:      words(l.PalindromeWords),
+		nonPalindromes:   words(l.NonPalindromeWords),
+		containsVerbs:    words(l.ContainsVerbs),
+		excludesVerbs:    words(l.ExcludesVerbs),
+		weakVerbs:        words(l.WeakVerbs),
+		characterNouns:   words(l.CharacterNouns),
+		articles:         words(l.Articles),
+		units:            foldKeys(l.Units),
+		wordSuperlatives: foldKeys(l.WordSuperlatives),
+		comparators:      foldKeys(l.Comparators),
+		orSuffixes:       foldKeys(l.OrSuffixes),
+	}
+	for phrase, replacement := range l.Synonyms {
+		c.synonyms = append(c.synonyms, lexiconSynonym{
+			phrase:      tokenizeNaturalLanguage(phrase),
+			replacement: tokenizeNaturalLanguage(replacement),
+		})
+	}
+	slices.SortFunc(c.synonyms, func(a, b lexiconSynonym) int { return len(b.phrase) - len(a.phrase) })
+	return c
+}
+
+func foldKeys[V any](m map[string]V) map[string]V {
+	folded := make(map[string]V, len(m))
+	for key, value := range m {
+		folded[foldWord(key)] = value
+	}
+	return folded
+}
+
+// expandSynonyms rewrites synonym phrases, longest first. The replacement
+// tokens keep the position of the phrase they replace, so spans point at
+// what the user wrote. A single letter after a character noun or article
+// is a character and never rewritten: Spanish "la letra o" is about o, not
+// "or".
+func (c *compiledLexicon) expandSynonyms(tokens []nlToken) []nlToken {
+	if len(c.synonyms) == 0 {
+		return tokens
+	}
+	expanded := make([]nlToken, 0, len(tokens))
+	for i := 0; i < len(tokens); {
+		synonym, ok := c.synonymAt(tokens[i:])
+		if ok && len(expanded) > 0 && utf8.RuneCountInString(tokens[i].text) == 1 {
+			previous := expanded[len(expanded)-1].text
+			// A one-letter article is likely a letter itself: "a o b"
+			ok = !c.characterNouns[previous] && !(c.articles[previous] && utf8.RuneCountInString(previous) > 1)
+		}
+		if !ok {
+			expanded = append(expanded, tokens[i])
+			i++
+			continue
+		}
+		start, end := tokens[i].start, tokens[i+len(synonym.phrase)-1].end
+		for _, t := range synonym.replacement {
+			t.start, t.end = start, end
+			expanded = append(expanded, t)
+		}
+		i += len(synonym.phrase)
+	}
+	return expanded
+}
+
+func (c *compiledLexicon) synonymAt(tokens []nlToken) (lexiconSynonym, bool) {
+	for _, synonym := range c.synonyms {
+		if len(synonym.phrase) > len(tokens) {
+			continue
+		}
+		matches := true
+		for j, t := range synonym.phrase {
+			if tokens[j].kind == nlChar || tokens[j].kind == nlBadNumber || tokens[j].text != t.text {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return synonym, true
+		}
+	}
+	return lexiconSynonym{}, false
+}
+
+// LexiconWatcher reloads a lexicon file when it changes. A file that fails
+// to load or validate leaves the active lexicon in place.
+type LexiconWatcher struct {
+	path string
+	mu   sync.Mutex
+	// modTime and size identify the version of the file last loaded
+	modTime time.Time
+	size    int64
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+// WatchLexicon loads the lexicon at path, makes it active and checks the
+// file for changes every interval; 0 disables the polling, leaving Reload
+func WatchLexicon(path string, interval time.Duration) (*LexiconWatcher, error) {
+	w := &LexiconWatcher{path: path, stop: make(chan struct{})}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		w.done.Add(1)
+		go w.poll(interval)
+	}
+	return w, nil
+}
+
+// Reload loads the file now, as on SIGHUP
+func (w *LexiconWatcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	// A broken version is not retried until the file changes again
+	w.modTime, w.size = info.ModTime(), info.Size()
+	lexicon, err := LoadLexiconFile(w.path)
+	if err != nil {
+		return err
+	}
+	return SetLexicon(lexicon, w.path)
+}
+
+// changed reports whether the file differs from the version last loaded
+func (w *LexiconWatcher) changed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info, err := os.Stat(w.path)
+	return err == nil && (!info.ModTime().Equal(w.modTime) || info.Size() != w.size)
+}
+
+func (w *LexiconWatcher) poll(interval time.Duration) {
+	defer w.done.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			if err := w.Reload(); err != nil {
+				fmt.Printf("❌ Lexicon reload failed, keeping the previous lexicon: %v\n", err)
+			} else {
+				fmt.Printf("📖 Lexicon reloaded from %s\n", w.path)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Close stops watching the file
+func (w *LexiconWatcher) Close() error {
+	close(w.stop)
+	w.done.Wait()
+	return nil
+}
@@ -0,0 +1,1061 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Natural-language queries are read in two steps. tokenizeNaturalLanguage
+// splits the query into words, numbers and quoted characters, folding number
+// words ("twenty-three", "3 million") into numbers and "n't" into "not". A
+// number too large for an int, or with a scale word the tokenizer does not
+// know ("a billion billion", "nine sextillion"), fails the query rather than
+// being read as a smaller one. The grammar then
+// reads the tokens as a sequence of clauses, skipping filler words:
+//
+//	query      := clauses { or clauses }
+//	clauses    := { [ negation ] predicate | boundary | filler }
+//	predicate  := palindrome | FLAG-NOUN
+//	            | contains [ no ] [ article ] [ noun ] CHAR
+//	            | contains [ article ] ORDINAL ( vowel | letter )
+//	            | [ length [ of | is ] | one-word ] quantity [ "-" ] [ unit ] [ or-suffix ] [ long ]
+//	            | [ article ] CLASS ( number | count ) of [ unique ] unit
+//	            | [ article ] CLASS ( length | word count )
+//	            | property ( is | are ) [ not ] [ article ] CLASS [ number ]
+//	quantity   := [ comparator ] [ unit ] NUMBER | NUMBER or NUMBER
+//	            | ( between | from ) NUMBER ( and | to ) NUMBER
+//	comparator := at least | at most | exactly | up to | minimum of | maximum of
+//	            | ( longer | more | greater | over | ... ) [ than [ or equal to ] ]
+//	            | ( shorter | less | fewer | under | ... ) [ than [ or equal to ] ]
+//	or-suffix  := or ( more | longer | greater | fewer | less | shorter ) | "+"
+//	unit       := characters | letters | chars | words | ...
+//	one-word   := ( longest | shortest ) word [ is | has | have ]
+//	property   := length | word count | number of [ unique ] unit
+//	CLASS      := prime | perfect | armstrong | even | odd
+//
+// Registered analyzers with QueryNouns add their nouns: a number analyzer's
+// as units ("more than 3 vowels") and a flag analyzer's as FLAG-NOUN
+// ("pangrams").
+//
+// A negation ("not", "no", "non", "never", "isn't", "without") applies to the
+// next predicate only and is dropped at a clause boundary ("and", "but",
+// "that", "which", ","), so "not palindromes but containing z" negates just
+// the palindrome clause. "or" separates alternatives, each an AND of its
+// clauses, except that a character after "or" joins the previous character
+// requirement: "palindromes containing a or b". "neither ... nor" negates
+// both clauses.
+//
+// The words in each role (negations, boundaries, fillers, verbs, nouns,
+// units, comparators) come from the Lexicon of the query's language, whose
+// synonyms are rewritten into known words before the grammar runs. Number
+// words and fixed phrases like "at least" belong to the grammar; other
+// languages reach them through synonyms ("au moins" is "at least"), as
+// described in grammar.go.
+
+type nlTokenKind int
+
+const (
+	nlWord nlTokenKind = iota
+	nlNumber
+	nlChar // a single quoted character
+	nlPunct
+	// nlBadNumber is a number no int holds, or one with a scale word
+	// readNumberWords does not know, as in "nine sextillion"
+	nlBadNumber
+)
+
+type nlToken struct {
+	kind   nlTokenKind
+	text   string
+	number int
+	// start and end are rune offsets into the query
+	start, end int
+}
+
+var (
+	nlUnits = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+		"seven": 7, "eight": 8, "nine": 9,
+	}
+	nlTeens = map[string]int{
+		"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15,
+		"sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	}
+	nlTens = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70,
+		"eighty": 80, "ninety": 90,
+	}
+	// nlScales are int64 so that they compile where int is 32 bits; a
+	// scale no int holds makes any number using it overflow
+	nlScales = map[string]int64{
+		"thousand": 1e3, "million": 1e6, "billion": 1e9, "trillion": 1e12,
+		"quadrillion": 1e15, "quintillion": 1e18,
+	}
+
+	nlOrdinals = map[string]int{
+		"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5, "sixth": 6,
+		"seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+	}
+)
+
+// nlElisions are the French words that lose their vowel before another
+// word: "l'", "d'", "qu'"
+var nlElisions = wordSet("c", "d", "j", "l", "m", "n", "qu", "s", "t", "jusqu", "lorsqu", "puisqu")
+
+// foldWord drops accents and tone marks from words of two or more letters,
+// so "caracteres" matches "caractères" and "oro" matches "ọ̀rọ̀". Single
+// letters keep theirs: "the letter é" is about é.
+func foldWord(word string) string {
+	decomposed := norm.NFD.String(word)
+	var folded strings.Builder
+	letters := 0
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			folded.WriteRune(r)
+			letters++
+		}
+	}
+	if letters < 2 {
+		return word
+	}
+	return folded.String()
+}
+
+func wordSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// tokenizeNaturalLanguage splits a query into tokens. Words are lowercased
+// and folded with foldWord; quoted characters keep their case.
+func tokenizeNaturalLanguage(query string) []nlToken {
+	runes := []rune(query)
+	var tokens []nlToken
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) }
+	isQuote := func(r rune) bool { return strings.ContainsRune(`'"‘’“”`, r) }
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isQuote(r) && i+2 < len(runes) && isQuote(runes[i+2]) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, nlToken{kind: nlChar, text: string(runes[i+1]), start: i, end: i + 3})
+			i += 3
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && (isWordRune(runes[i]) ||
+				// Apostrophes inside words, as in "doesn't"
+				(strings.ContainsRune(`'’`, runes[i]) && i+1 < len(runes) && unicode.IsLetter(runes[i+1]) && i > start)) {
+				i++
+			}
+			// French elision: "d'" in "d'au moins" is a word of its own
+			if i < len(runes) && strings.ContainsRune(`'’`, runes[i]) && nlElisions[strings.ToLower(string(runes[start:i]))] {
+				i++
+			}
+			text := foldWord(strings.ToLower(strings.ReplaceAll(string(runes[start:i]), "’", "'")))
+			if n, err := strconv.Atoi(text); err == nil {
+				tokens = append(tokens, nlToken{kind: nlNumber, text: text, number: n, start: start, end: i})
+			} else if errors.Is(err, strconv.ErrRange) {
+				tokens = append(tokens, nlToken{kind: nlBadNumber, text: text, start: start, end: i})
+			} else if base, ok := strings.CutSuffix(text, "n't"); ok {
+				switch base {
+				case "ca":
+					base = "can"
+				case "wo":
+					base = "will"
+				}
+				tokens = append(tokens,
+					nlToken{kind: nlWord, text: base, start: start, end: i},
+					nlToken{kind: nlWord, text: "not", start: start, end: i})
+			} else if prefix, rest, ok := strings.Cut(text, "'"); ok && rest != "" && nlElisions[prefix] {
+				split := start + utf8.RuneCountInString(prefix) + 1
+				tokens = append(tokens,
+					nlToken{kind: nlWord, text: prefix + "'", start: start, end: split},
+					nlToken{kind: nlWord, text: rest, start: split, end: i})
+			} else {
+				tokens = append(tokens, nlToken{kind: nlWord, text: text, start: start, end: i})
+			}
+		default:
+			tokens = append(tokens, nlToken{kind: nlPunct, text: string(r), start: i, end: i + 1})
+			i++
+		}
+	}
+	return foldNumberWords(tokens)
+}
+
+// NormalizeQuery reduces a natural-language query to the form used to
+// group phrasings that differ only in spelling: words lowercased and
+// folded, number words and digits written as "#", punctuation dropped.
+// "Strings with Twenty  blorps!" and "strings with 3 blorps" both become
+// "strings with # blorps".
+func NormalizeQuery(query string) string {
+	var words []string
+	for _, t := range tokenizeNaturalLanguage(query) {
+		switch t.kind {
+		case nlNumber, nlBadNumber:
+			words = append(words, "#")
+		case nlChar:
+			words = append(words, "'"+t.text+"'")
+		case nlWord:
+			words = append(words, t.text)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// foldNumberWords replaces runs of number words such as "one hundred and
+// twenty-three" or "3 million" with a single number token
+func foldNumberWords(tokens []nlToken) []nlToken {
+	folded := make([]nlToken, 0, len(tokens))
+	for i := 0; i < len(tokens); {
+		n, next, ok := readNumberWords(tokens, i)
+		if next == i {
+			folded = append(folded, tokens[i])
+			i++
+			continue
+		}
+		t := nlToken{
+			kind: nlNumber, text: strconv.Itoa(n), number: n,
+			start: tokens[i].start, end: tokens[next-1].end,
+		}
+		if !ok {
+			words := make([]string, 0, next-i)
+			for _, word := range tokens[i:next] {
+				words = append(words, word.text)
+			}
+			t = nlToken{kind: nlBadNumber, text: strings.Join(words, " "), start: t.start, end: t.end}
+		}
+		folded = append(folded, t)
+		i = next
+	}
+	return folded
+}
+
+// isScaleWord reports whether a word names a power of a thousand, known or
+// not: "million", "sextillion", "milliard"
+func isScaleWord(word string) bool {
+	return strings.HasSuffix(word, "illion") || strings.HasSuffix(word, "illiard")
+}
+
+// readNumberWords reads an English cardinal starting at tokens[i], such as
+// "one hundred and five" or "3 million", and returns its value and the
+// index after it, or i when there is none. ok is false when the number does
+// not fit in an int or has a scale word it cannot read, as in "a billion
+// billion" or "nine sextillion"; the unread scale word is part of it.
+func readNumberWords(tokens []nlToken, i int) (n, next int, ok bool) {
+	var total, current int64
+	end := i
+	// last is the kind of the previous number word
+	last := ""
+	word := func(j int) string {
+		if j < len(tokens) && tokens[j].kind == nlWord {
+			return tokens[j].text
+		}
+		return ""
+	}
+
+	j := i
+	if tokens[i].kind == nlNumber {
+		// Digits only start a number word with a scale: "3 million"
+		if next := word(i + 1); nlScales[next] == 0 && !isScaleWord(next) {
+			return 0, i, true
+		}
+		current, last, j, end = int64(tokens[i].number), "digits", i+1, i+1
+	}
+	for j < len(tokens) {
+		w := word(j)
+		if value, ok := nlUnits[w]; ok && (last == "" || last == "tens" || last == "hundred" || last == "scale") {
+			current += int64(value)
+			last = "unit"
+		} else if value, ok := nlTeens[w]; ok && (last == "" || last == "hundred" || last == "scale") {
+			current += int64(value)
+			last = "teen"
+		} else if value, ok := nlTens[w]; ok && (last == "" || last == "hundred" || last == "scale") {
+			current += int64(value)
+			last = "tens"
+		} else if w == "hundred" && (last == "" || last == "unit" || last == "teen") {
+			current = max(current, 1) * 100
+			last = "hundred"
+		} else if scale, ok := nlScales[w]; ok && last != "scale" {
+			current = max(current, 1)
+			if current > math.MaxInt/scale || total > math.MaxInt-current*scale {
+				return 0, j + 1, false
+			}
+			total += current * scale
+			current = 0
+			last = "scale"
+		} else if isScaleWord(w) && last != "" {
+			// "nine sextillion", "a billion billion"
+			return 0, j + 1, false
+		} else if tokens[j].text == "-" && last == "tens" && nlUnits[word(j+1)] > 0 {
+			// twenty-three
+			j++
+			continue
+		} else if w == "and" && (last == "hundred" || last == "scale") && (nlUnits[word(j+1)] > 0 || nlTeens[word(j+1)] > 0 || nlTens[word(j+1)] > 0) {
+			// one hundred and five
+			j++
+			continue
+		} else {
+			break
+		}
+		j++
+		end = j
+	}
+	if total > math.MaxInt-current {
+		return 0, end, false
+	}
+	return int(total + current), end, true
+}
+
+type nlParser struct {
+	lex    *compiledLexicon
+	runes  []rune
+	tokens []nlToken
+	pos    int
+	// spans, ignored and consumed record what parse understood
+	spans      []QuerySpan
+	ignored    []string
+	ignoredEnd int
+	consumed   int
+	// neutral counts the filler words ignore skipped
+	neutral int
+	// inCharacterList is set after a character requirement, so "and b"
+	// continues it with the same verb
+	inCharacterList bool
+	listNegates     bool
+	// err is set by a clause that was read but cannot be a filter, and
+	// fails the whole query
+	err error
+}
+
+// ParseNaturalLanguageQuery interprets a query such as "palindromes with at
+// least three words or strings that don't contain the letter z" and returns
+// the map form of its FilterTree
+func ParseNaturalLanguageQuery(query string) (map[string]interface{}, error) {
+	tree, err := ParseNaturalLanguageFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	return tree.ToMap(), nil
+}
+
+// ParseNaturalLanguageFilter interprets a query as a FilterTree, in the
+// language DetectLanguage picks for it
+func ParseNaturalLanguageFilter(query string) (FilterTree, error) {
+	interpretation, err := detectAndInterpret(query, false)
+	return interpretation.Filter, err
+}
+
+func newNLParser(lex *compiledLexicon, query string) *nlParser {
+	return &nlParser{lex: lex, runes: []rune(query), tokens: lex.expandSynonyms(tokenizeNaturalLanguage(query))}
+}
+
+// parse reads the query as a filter expression, recording what it
+// understood and what it skipped
+func (p *nlParser) parse() (ExprNode, error) {
+	var alternatives []ExprNode
+	var terms []ExprNode
+	negated := false
+	// negation holds the negation words waiting for a predicate
+	var negation []nlToken
+
+	// Each side of an "or" has to say something, or the query would
+	// silently lose an alternative
+	sawOr, emptyAlternative := false, false
+	closeAlternative := func() {
+		switch len(terms) {
+		case 0:
+			emptyAlternative = true
+		case 1:
+			alternatives = append(alternatives, terms[0])
+		default:
+			alternatives = append(alternatives, &AndExpr{Terms: terms})
+		}
+		terms = nil
+	}
+	dropNegation := func() {
+		for _, t := range negation {
+			p.ignore(t)
+		}
+		negated, negation = false, nil
+	}
+
+	for p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		switch {
+		case t.kind == nlBadNumber:
+			return nil, fmt.Errorf("unable to parse query: cannot read the number %q", string(p.runes[t.start:t.end]))
+		case t.kind == nlWord && t.text == "nor":
+			// "neither palindromes nor strings with z"
+			dropNegation()
+			negated, negation = true, []nlToken{t}
+			p.inCharacterList = false
+			p.pos++
+			continue
+		case t.kind != nlChar && p.lex.boundaries[t.text]:
+			dropNegation()
+			p.pos++
+			continue
+		case t.kind == nlWord && p.lex.negations[t.text]:
+			negated = !negated
+			negation = append(negation, t)
+			p.pos++
+			continue
+		case t.kind == nlWord && t.text == "or":
+			dropNegation()
+			p.pos++
+			p.understood(p.pos-1, t.start, "OR")
+			if len(terms) > 0 && p.inCharacterList && !p.lex.isVerb(p.word(0)) {
+				// "containing a or b" widens the last requirement
+				from := p.pos
+				if node, negates, ok := p.contains(); ok {
+					if negates {
+						node = negateTerm(node)
+					}
+					p.understood(from, p.tokens[from].start, node.String())
+					last := len(terms) - 1
+					terms[last] = &OrExpr{Terms: []ExprNode{terms[last], node}}
+					continue
+				}
+			}
+			closeAlternative()
+			sawOr = true
+			p.inCharacterList = false
+			continue
+		}
+
+		from := p.pos
+		node, negates, ok := p.predicate()
+		if p.err != nil {
+			return nil, p.err
+		}
+		if !ok {
+			p.ignore(t)
+			p.pos++
+			continue
+		}
+		if negated != negates {
+			node = negateTerm(node)
+		}
+		start := t.start
+		if len(negation) > 0 {
+			start = negation[0].start
+			p.consumed += len(negation)
+		}
+		p.understood(from, start, node.String())
+		terms = append(terms, node)
+		negated, negation = false, nil
+	}
+	dropNegation()
+	closeAlternative()
+	if sawOr && emptyAlternative {
+		return nil, fmt.Errorf("unable to parse query: an alternative joined by \"or\" has no filters")
+	}
+
+	switch len(alternatives) {
+	case 0:
+		return nil, fmt.Errorf("unable to parse query")
+	case 1:
+		return alternatives[0], nil
+	}
+	return &OrExpr{Terms: alternatives}, nil
+}
+
+// predicate reads one predicate at the current position. negates is set
+// for predicates that carry their own negation, like "without z".
+func (p *nlParser) predicate() (node ExprNode, negates bool, ok bool) {
+	if node, negates, ok := p.contains(); ok {
+		return node, negates, true
+	}
+	p.inCharacterList = false
+	if node, ok := p.flag(); ok {
+		return node, false, true
+	}
+	if node, ok := p.multiWord(); ok {
+		return node, false, true
+	}
+	if node, negates, ok := p.numberClass(); ok {
+		return node, negates, true
+	}
+	if node, ok := p.comparison(); ok {
+		return node, false, true
+	}
+	return nil, false, false
+}
+
+func (p *nlParser) word(offset int) string {
+	if i := p.pos + offset; i < len(p.tokens) && (p.tokens[i].kind == nlWord || p.tokens[i].kind == nlPunct) {
+		return p.tokens[i].text
+	}
+	return ""
+}
+
+// accept consumes the next token if it is one of words
+func (p *nlParser) accept(words ...string) bool {
+	for _, w := range words {
+		if p.word(0) == w {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+// acceptPhrase consumes the given words if they come next, in order
+func (p *nlParser) acceptPhrase(words ...string) bool {
+	for i, w := range words {
+		if p.word(i) != w {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+// flag reads a palindrome word, or the query noun of a registered flag
+// analyzer such as "pangrams"
+func (p *nlParser) flag() (ExprNode, bool) {
+	switch {
+	case p.lex.palindromes[p.word(0)]:
+		p.pos++
+		return &FlagExpr{Field: "is_palindrome"}, true
+	case p.lex.nonPalindromes[p.word(0)]:
+		p.pos++
+		return &NotExpr{Operand: &FlagExpr{Field: "is_palindrome"}}, true
+	}
+	if field := analyzerNouns(FilterFlag)[p.word(0)]; field != "" {
+		p.pos++
+		return &FlagExpr{Field: field}, true
+	}
+	return nil, false
+}
+
+// multiWord reads "multi-word", "multiword" and "multiple words"
+func (p *nlParser) multiWord() (ExprNode, bool) {
+	if p.acceptPhrase("multi", "-", "word") || p.accept("multiword") ||
+		p.acceptPhrase("multiple", "words") || p.acceptPhrase("several", "words") {
+		return &CompareExpr{Field: "word_count", Op: ">=", Value: 2}, true
+	}
+	return nil, false
+}
+
+// contains reads a character requirement such as "containing the letter z",
+// "with 'Q'", "without the digit 7" or "that contain the first vowel".
+// Further characters after "and" share the verb: "containing a and b".
+func (p *nlParser) contains() (ExprNode, bool, bool) {
+	start := p.pos
+	verb := p.word(0)
+	negates, bare := false, false
+	switch {
+	case p.lex.isVerb(verb):
+		p.pos++
+		negates, bare = p.lex.excludesVerbs[verb], !p.lex.weakVerbs[verb]
+		// Filler between the verb and its character, as in "containing
+		// only z" or French "ne contiennent pas la lettre z"; articles and
+		// single letters are the character's own
+		for w := p.word(0); p.lex.fillers[w] && !p.lex.articles[w] && utf8.RuneCountInString(w) > 1; w = p.word(0) {
+			p.pos++
+		}
+	case p.inCharacterList:
+		negates, bare = p.listNegates, true
+	default:
+		return nil, false, false
+	}
+	if p.accept("no") {
+		negates, bare = !negates, true
+	}
+
+	node, ok := p.character(bare)
+	if !ok {
+		p.pos = start
+		return nil, false, false
+	}
+	p.inCharacterList, p.listNegates = true, negates
+	return node, negates, true
+}
+
+// character reads "the letter z", "a z", "'Q'", "the digit 7" or "the first
+// vowel". A bare letter or digit is only accepted when bare is set.
+func (p *nlParser) character(bare bool) (ExprNode, bool) {
+	// "the letter z", "a z", "an 'x'", but "a" alone is the letter a
+	if p.lex.articles[p.word(0)] && p.isCharacterAhead(1) {
+		p.pos++
+	}
+	if p.lex.characterNouns[p.word(0)] {
+		p.pos++
+		bare = true
+	}
+
+	if n, ok := nlOrdinals[p.word(0)]; ok || p.word(0) == "last" {
+		switch p.word(1) {
+		case "vowel":
+			if !ok {
+				n = 5
+			}
+			if n <= 5 {
+				p.pos += 2
+				return &ContainsExpr{Substring: string("aeiou"[n-1])}, true
+			}
+		case "letter":
+			p.pos += 2
+			p.acceptPhrase("of", "the", "alphabet")
+			if !ok {
+				n = 26
+			}
+			return &ContainsExpr{Substring: string(rune('a' + n - 1))}, true
+		}
+		return nil, false
+	}
+
+	if p.pos >= len(p.tokens) {
+		return nil, false
+	}
+	t := p.tokens[p.pos]
+	isLetter := t.kind == nlWord && utf8.RuneCountInString(t.text) == 1 && unicode.IsLetter([]rune(t.text)[0])
+	isDigit := t.kind == nlNumber && len(t.text) == 1 && !p.isUnitAhead(1)
+	// A bare "a" is an article unless the clause ends with it
+	if next := p.pos + 1; t.text == "a" && next < len(p.tokens) && !p.lex.boundaries[p.tokens[next].text] && p.tokens[next].text != "or" && p.tokens[next].text != "nor" {
+		isLetter = false
+	}
+	if t.kind == nlChar || bare && (isLetter || isDigit) {
+		p.pos++
+		return &ContainsExpr{Substring: t.text}, true
+	}
+	return nil, false
+}
+
+// isCharacterAhead reports whether the token at offset names a character
+func (p *nlParser) isCharacterAhead(offset int) bool {
+	i := p.pos + offset
+	if i >= len(p.tokens) {
+		return false
+	}
+	t := p.tokens[i]
+	_, ordinal := nlOrdinals[t.text]
+	return t.kind == nlChar || p.lex.characterNouns[t.text] || ordinal || t.text == "last" ||
+		(t.kind == nlWord && utf8.RuneCountInString(t.text) == 1)
+}
+
+func (p *nlParser) isUnitAhead(offset int) bool {
+	i := p.pos + offset
+	for i < len(p.tokens) && p.tokens[i].text == "-" {
+		i++
+	}
+	return i < len(p.tokens) && p.unit(p.tokens[i].text) != ""
+}
+
+// unit returns the property a unit word counts: a lexicon unit or the
+// query noun of a registered number analyzer
+func (p *nlParser) unit(word string) string {
+	if field := p.lex.units[word]; field != "" {
+		return field
+	}
+	return analyzerNouns(FilterNumber)[word]
+}
+
+func (p *nlParser) isNumberAhead(offset int) bool {
+	i := p.pos + offset
+	return i < len(p.tokens) && p.tokens[i].kind == nlNumber
+}
+
+// number reads a number token; "single" counts as one
+func (p *nlParser) number() (int, bool) {
+	if p.pos >= len(p.tokens) {
+		return 0, false
+	}
+	if t := p.tokens[p.pos]; t.kind == nlNumber {
+		p.pos++
+		return t.number, true
+	}
+	if p.word(0) == "single" && p.isUnitAhead(1) {
+		p.pos++
+		return 1, true
+	}
+	return 0, false
+}
+
+// numberClass reads a class of numbers that a property belongs to, such as
+// "a prime number of characters", "an even word count" or "whose length is
+// not odd"
+func (p *nlParser) numberClass() (ExprNode, bool, bool) {
+	start := p.pos
+	fail := func() (ExprNode, bool, bool) {
+		p.pos = start
+		return nil, false, false
+	}
+
+	if p.lex.articles[p.word(0)] && numberClasses[p.word(1)] != nil {
+		p.pos++
+	}
+	if class := p.word(0); numberClasses[class] != nil {
+		p.pos++
+		field, ok := "", false
+		switch {
+		case p.accept("number", "count"):
+			if !p.accept("of") {
+				return fail()
+			}
+			field, ok = p.countedUnit()
+		case p.accept("length"):
+			field, ok = "length", true
+		case p.acceptPhrase("word", "count"):
+			field, ok = "word_count", true
+		}
+		if !ok {
+			return fail()
+		}
+		return &NumberClassExpr{Field: field, Class: class}, false, true
+	}
+
+	// "whose length is prime", "the number of words is even"
+	p.pos = start
+	p.accept("the")
+	field, ok := "", false
+	switch {
+	case p.accept("length"):
+		field, ok = "length", true
+	case p.acceptPhrase("word", "count"):
+		field, ok = "word_count", true
+	case p.acceptPhrase("number", "of"):
+		field, ok = p.countedUnit()
+	}
+	if !ok || !p.accept("is", "are") {
+		return fail()
+	}
+	negates := p.lex.negations[p.word(0)]
+	if negates {
+		p.pos++
+	}
+	if p.lex.articles[p.word(0)] && numberClasses[p.word(1)] != nil {
+		p.pos++
+	}
+	class := p.word(0)
+	if numberClasses[class] == nil {
+		return fail()
+	}
+	p.pos++
+	p.accept("number")
+	return &NumberClassExpr{Field: field, Class: class}, negates, true
+}
+
+// countedUnit reads the unit a count is of, with "unique" or "distinct"
+// before characters counting unique characters
+func (p *nlParser) countedUnit() (string, bool) {
+	unique := p.accept("unique", "distinct")
+	field := p.unit(p.word(0))
+	if field == "" || unique && field != "length" {
+		return "", false
+	}
+	p.pos++
+	if unique {
+		return "unique_characters", true
+	}
+	return field, true
+}
+
+// comparison reads a quantity with its unit, such as "longer than 10
+// characters", "between three and ten letters", "at least 2 words" or
+// "5-letter", or with a superlative the length of one word, as in
+// "longest word exceeds 8 letters"
+func (p *nlParser) comparison() (ExprNode, bool) {
+	start := p.pos
+	field := ""
+	subject := p.superlative()
+	if subject == "" && p.accept("length") {
+		field = "length"
+		p.accept("of", "is")
+	}
+
+	op, impliedField := "", ""
+	between := false
+	switch {
+	case p.acceptPhrase("at", "least"), p.acceptPhrase("minimum", "of"), p.acceptPhrase("a", "minimum", "of"):
+		op = ">="
+	case p.acceptPhrase("at", "most"), p.acceptPhrase("up", "to"), p.acceptPhrase("maximum", "of"), p.acceptPhrase("a", "maximum", "of"):
+		op = "<="
+	case p.accept("between"), p.accept("from"):
+		between = true
+	default:
+		if comparator, ok := p.lex.comparators[p.word(0)]; ok {
+			p.pos++
+			op, impliedField = comparator.Op, comparator.Field
+			if p.accept("than") && p.acceptPhrase("or", "equal", "to") {
+				op += "="
+			}
+		}
+	}
+
+	// Some languages put the unit first: "ọ̀rọ̀ méjì", two words
+	unitField := ""
+	if unit := p.unit(p.word(0)); unit != "" && !between && p.isNumberAhead(1) {
+		p.pos++
+		unitField = unit
+	}
+
+	low, ok := p.number()
+	if !ok {
+		p.pos = start
+		return nil, false
+	}
+	high := low
+	if between {
+		if !p.accept("and", "to") {
+			p.pos = start
+			return nil, false
+		}
+		if high, ok = p.number(); !ok {
+			p.pos = start
+			return nil, false
+		}
+		if low > high {
+			low, high = high, low
+		}
+	}
+
+	// "2 or 3 words"
+	alternative := -1
+	if op == "" && !between && p.word(0) == "or" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == nlNumber {
+		p.pos++
+		alternative, _ = p.number()
+	}
+
+	if op == "" && !between {
+		op = p.orSuffix()
+	}
+	if p.accept("-") && !p.isUnitAhead(0) {
+		p.pos--
+	}
+	if unit := p.unit(p.word(0)); unit != "" && unitField == "" {
+		p.pos++
+		unitField = unit
+	}
+	if op == "" && !between {
+		op = p.orSuffix()
+	}
+	if !p.accept("long") {
+		p.acceptPhrase("in", "length")
+	}
+
+	switch {
+	case subject != "":
+		// "longest word has more than 8 letters" counts characters
+		if unitField != "" && unitField != "length" {
+			p.pos = start
+			return nil, false
+		}
+		field = subject
+	case unitField != "":
+		field = unitField
+	case field != "":
+	case impliedField != "":
+		field = impliedField
+	case op != "" || between:
+		// A bare comparison like "at least 5" is about length
+		field = "length"
+	default:
+		// A bare number is not a filter
+		p.pos = start
+		return nil, false
+	}
+
+	if between {
+		return &BetweenExpr{Field: field, Low: low, High: high}, true
+	}
+	if alternative >= 0 && op == "" {
+		return &OrExpr{Terms: []ExprNode{
+			&CompareExpr{Field: field, Op: "=", Value: low},
+			&CompareExpr{Field: field, Op: "=", Value: alternative},
+		}}, true
+	}
+	if op == "" {
+		op = "="
+	}
+	if op == ">" && low == math.MaxInt {
+		// The filters would need a count one larger than an int holds
+		p.err = fmt.Errorf("unable to parse query: no count is more than %d", low)
+	}
+	return &CompareExpr{Field: field, Op: op, Value: low}, true
+}
+
+// superlative reads "longest word", which makes one word of the string the
+// subject of a comparison
+func (p *nlParser) superlative() string {
+	field := p.lex.wordSuperlatives[p.word(0)]
+	if field == "" || p.unit(p.word(1)) != "word_count" {
+		return ""
+	}
+	p.pos += 2
+	p.accept("is", "has", "have")
+	return field
+}
+
+// orSuffix reads "or more", "or fewer" and "+" after a number
+func (p *nlParser) orSuffix() string {
+	if p.word(0) == "+" {
+		p.pos++
+		return ">="
+	}
+	if p.word(0) == "or" {
+		if op, ok := p.lex.orSuffixes[p.word(1)]; ok {
+			p.pos += 2
+			return op
+		}
+	}
+	return ""
+}
+
+// negateTerm returns the complement of a predicate, keeping comparisons
+// in positive form: NOT length > 5 becomes length <= 5 and NOT length IS
+// even becomes length IS odd
+func negateTerm(node ExprNode) ExprNode {
+	switch n := node.(type) {
+	case *NotExpr:
+		return n.Operand
+	case *CompareExpr:
+		complement := map[string]string{"=": "!=", "!=": "=", "<": ">=", ">=": "<", ">": "<=", "<=": ">"}
+		return &CompareExpr{Field: n.Field, Op: complement[n.Op], Value: n.Value}
+	case *NumberClassExpr:
+		switch n.Class {
+		case "even":
+			return &NumberClassExpr{Field: n.Field, Class: "odd"}
+		case "odd":
+			return &NumberClassExpr{Field: n.Field, Class: "even"}
+		}
+	}
+	return &NotExpr{Operand: node}
+}
+
+// treeFromExpr folds the predicates of a query into a FilterTree, using
+// GET /strings parameters where one exists. Predicates that contradict what
+// is already folded are kept as separate subtrees, so Conflicts sees them.
+func treeFromExpr(node ExprNode) FilterTree {
+	terms := []ExprNode{node}
+	if and, ok := node.(*AndExpr); ok {
+		terms = and.Terms
+	}
+
+	var t FilterTree
+	var rest []ExprNode
+	f := &t.Filter
+	separate := func(sub FilterTree) { t.And = append(t.And, sub) }
+	atLeast := func(value int) {
+		if f.MinLength == nil || *f.MinLength < value {
+			f.MinLength = &value
+		}
+	}
+	atMost := func(value int) {
+		if f.MaxLength == nil || *f.MaxLength > value {
+			f.MaxLength = &value
+		}
+	}
+	setFlag := func(value bool) {
+		if f.IsPalindrome != nil && *f.IsPalindrome != value {
+			separate(FilterTree{Filter: Filter{IsPalindrome: &value}})
+			return
+		}
+		f.IsPalindrome = &value
+	}
+	not := func(sub FilterTree) {
+		if t.Not != nil {
+			separate(FilterTree{Not: &sub})
+			return
+		}
+		t.Not = &sub
+	}
+
+	for _, term := range terms {
+		switch x := term.(type) {
+		case *FlagExpr:
+			if x.Field != "is_palindrome" {
+				rest = append(rest, x)
+				break
+			}
+			setFlag(true)
+		case *NotExpr:
+			switch operand := x.Operand.(type) {
+			case *FlagExpr:
+				if operand.Field != "is_palindrome" {
+					rest = append(rest, x)
+					break
+				}
+				setFlag(false)
+			case *NumberClassExpr:
+				// Stays a q predicate: length IS NOT prime
+				rest = append(rest, x)
+			default:
+				not(treeFromExpr(x.Operand))
+			}
+		case *OrExpr:
+			branches := make([]FilterTree, len(x.Terms))
+			for i, branch := range x.Terms {
+				branches[i] = treeFromExpr(branch)
+			}
+			if t.Or != nil {
+				separate(FilterTree{Or: branches})
+			} else {
+				t.Or = branches
+			}
+		case *BetweenExpr:
+			if x.Field != "length" {
+				rest = append(rest, x)
+				break
+			}
+			atLeast(x.Low)
+			atMost(x.High)
+		case *CompareExpr:
+			switch {
+			case x.Field == "length" && x.Op == ">" && x.Value < math.MaxInt:
+				atLeast(x.Value + 1)
+			case x.Field == "length" && x.Op == ">=":
+				atLeast(x.Value)
+			case x.Field == "length" && x.Op == "<" && x.Value > math.MinInt:
+				atMost(x.Value - 1)
+			case x.Field == "length" && x.Op == "<=":
+				atMost(x.Value)
+			case x.Field == "length" && x.Op == "=":
+				atLeast(x.Value)
+				atMost(x.Value)
+			case x.Field == "length" && x.Op == "!=":
+				value := x.Value
+				not(FilterTree{Filter: Filter{MinLength: &value, MaxLength: &value}})
+			case x.Field == "word_count" && x.Op == "=":
+				value := x.Value
+				if f.WordCount != nil && *f.WordCount != value {
+					separate(FilterTree{Filter: Filter{WordCount: &value}})
+				} else {
+					f.WordCount = &value
+				}
+			default:
+				rest = append(rest, x)
+			}
+		case *ContainsExpr:
+			if f.ContainsCharacter == "" || f.ContainsCharacter == x.Substring {
+				f.ContainsCharacter = x.Substring
+			} else {
+				separate(FilterTree{Filter: Filter{ContainsCharacter: x.Substring}})
+			}
+		default:
+			rest = append(rest, x)
+		}
+	}
+
+	switch len(rest) {
+	case 0:
+	case 1:
+		t.Expression = rest[0]
+	default:
+		t.Expression = &AndExpr{Terms: rest}
+	}
+	return t
+}
@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// PalindromeMode is the normalization applied before a string is compared
+// with its reverse. Steps run in a fixed order: case folding, diacritic
+// stripping, then dropping whitespace, punctuation or everything but
+// digits.
+type PalindromeMode struct {
+	// FoldCase applies Unicode case folding, with the rules of CaseLocale
+	// when set: in Turkish, I folds to dotless ı and İ to i
+	FoldCase   bool
+	CaseLocale string
+	// StripDiacritics removes combining marks after NFD decomposition
+	StripDiacritics   bool
+	IgnoreWhitespace  bool
+	IgnorePunctuation bool
+	// DigitsOnly keeps decimal digits and nothing else
+	DigitsOnly bool
+}
+
+// DefaultPalindromeMode folds case and ignores whitespace
+var DefaultPalindromeMode = PalindromeMode{FoldCase: true, IgnoreWhitespace: true}
+
+// palindromeSteps are the names of the steps of a mode, in order
+var palindromeSteps = []string{"case", "diacritics", "whitespace", "punctuation", "digits"}
+
+// ParsePalindromeMode reads a comma-separated list of steps: case (or
+// case:LOCALE such as case:tr), diacritics, whitespace, punctuation and
+// digits. "none" is the empty mode and "default" is case,whitespace.
+func ParsePalindromeMode(s string) (PalindromeMode, error) {
+	var mode PalindromeMode
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "default":
+		return DefaultPalindromeMode, nil
+	case "none":
+		return mode, nil
+	}
+
+	for _, step := range strings.Split(s, ",") {
+		step = strings.ToLower(strings.TrimSpace(step))
+		name, locale, hasLocale := strings.Cut(step, ":")
+		if hasLocale && name != "case" {
+			return mode, fmt.Errorf("only case takes a locale, got %q", step)
+		}
+		switch name {
+		case "case":
+			mode.FoldCase = true
+			if hasLocale {
+				tag, err := language.Parse(locale)
+				if err != nil {
+					return mode, fmt.Errorf("unknown case folding locale %q", locale)
+				}
+				base, _ := tag.Base()
+				mode.CaseLocale = base.String()
+			}
+		case "diacritics":
+			mode.StripDiacritics = true
+		case "whitespace":
+			mode.IgnoreWhitespace = true
+		case "punctuation":
+			mode.IgnorePunctuation = true
+		case "digits":
+			mode.DigitsOnly = true
+		default:
+			return mode, fmt.Errorf("unknown palindrome mode step %q, expected %s or %s",
+				step, strings.Join(palindromeSteps[:len(palindromeSteps)-1], ", "), palindromeSteps[len(palindromeSteps)-1])
+		}
+	}
+	return mode, nil
+}
+
+// String is the canonical form of the mode, which ParsePalindromeMode
+// reads back: its steps in order, or "none"
+func (m PalindromeMode) String() string {
+	var steps []string
+	if m.FoldCase {
+		if m.CaseLocale != "" {
+			steps = append(steps, "case:"+m.CaseLocale)
+		} else {
+			steps = append(steps, "case")
+		}
+	}
+	for _, step := range []struct {
+		name string
+		on   bool
+	}{{"diacritics", m.StripDiacritics}, {"whitespace", m.IgnoreWhitespace}, {"punctuation", m.IgnorePunctuation}, {"digits", m.DigitsOnly}} {
+		if step.on {
+			steps = append(steps, step.name)
+		}
+	}
+	if len(steps) == 0 {
+		return "none"
+	}
+	return strings.Join(steps, ",")
+}
+
+// Normalize returns the grapheme clusters of s that the mode compares
+func (m PalindromeMode) Normalize(s string) []string {
+	s = norm.NFC.String(s)
+	if m.FoldCase {
+		if m.CaseLocale != "" {
+			// Locale rules live in lowercasing; folding then finishes the job
+			s = cases.Lower(language.Make(m.CaseLocale)).String(s)
+		}
+		s = cases.Fold().String(s)
+	}
+	if m.StripDiacritics {
+		s = norm.NFC.String(strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, norm.NFD.String(s)))
+	}
+
+	clusters := Graphemes(s)
+	kept := clusters[:0]
+	for _, cluster := range clusters {
+		base := []rune(cluster)[0]
+		switch {
+		case m.DigitsOnly && !unicode.IsDigit(base):
+		case m.IgnoreWhitespace && unicode.IsSpace(base):
+		case m.IgnorePunctuation && unicode.IsPunct(base):
+		default:
+			kept = append(kept, cluster)
+		}
+	}
+	return kept
+}
+
+// IsPalindrome reports whether s reads the same backwards, grapheme by
+// grapheme, after normalization
+func (m PalindromeMode) IsPalindrome(s string) bool {
+	clusters := m.Normalize(s)
+	length := len(clusters)
+	for i := 0; i < length/2; i++ {
+		if clusters[i] != clusters[length-i-1] {
+			return false
+		}
+	}
+	return true
+}
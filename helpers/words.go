@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Tokenizer splits a string into words. Name identifies how it splits
+// them, and is recorded with every word analysis: a tokenizer that splits
+// words differently needs a new name.
+type Tokenizer interface {
+	Name() string
+	Tokenize(s string) []string
+}
+
+type funcTokenizer struct {
+	name  string
+	split func(s string) []string
+}
+
+func (t funcTokenizer) Name() string               { return t.name }
+func (t funcTokenizer) Tokenize(s string) []string { return t.split(s) }
+
+// NewTokenizer names a function that splits strings into words. The name
+// must not be empty or taken by UnicodeTokenizer.
+func NewTokenizer(name string, split func(s string) []string) (Tokenizer, error) {
+	t := &funcTokenizer{name: name, split: split}
+	if err := checkTokenizerName(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// checkTokenizerName rejects the names that would pass off the analyses
+// of t as another tokenizer's
+func checkTokenizerName(t Tokenizer) error {
+	switch name := t.Name(); {
+	case name == "":
+		return errors.New("tokenizer name is empty")
+	case name == UnicodeTokenizer.Name() && t != UnicodeTokenizer:
+		return fmt.Errorf("tokenizer name %q is taken by UnicodeTokenizer", name)
+	}
+	return nil
+}
+
+// UnicodeTokenizer finds words the way UAX #29 does for most scripts:
+// runs of letters and digits, kept whole across the apostrophe of "don't"
+// and the separators of "3.14" and "1,000". Han ideographs and hiragana
+// have no spaces between words and count one word each.
+var UnicodeTokenizer Tokenizer = &funcTokenizer{name: "unicode", split: tokenizeUnicode}
+
+var tokenizer = struct {
+	sync.RWMutex
+	active Tokenizer
+}{active: UnicodeTokenizer}
+
+// SetTokenizer replaces the tokenizer the word analysis uses; nil restores
+// UnicodeTokenizer. The word analysis of a string stored with another
+// tokenizer counts as missing: it is recomputed when it is next read, and
+// filters compute it afresh. Tokenizers named like NewTokenizer forbids
+// are rejected.
+func SetTokenizer(t Tokenizer) error {
+	if t == nil {
+		t = UnicodeTokenizer
+	}
+	if err := checkTokenizerName(t); err != nil {
+		return err
+	}
+	tokenizer.Lock()
+	defer tokenizer.Unlock()
+	tokenizer.active = t
+	return nil
+}
+
+// ActiveTokenizer returns the tokenizer the word analysis uses
+func ActiveTokenizer() Tokenizer {
+	tokenizer.RLock()
+	defer tokenizer.RUnlock()
+	return tokenizer.active
+}
+
+func tokenizeUnicode(s string) []string {
+	clusters := Graphemes(norm.NFC.String(s))
+	base := func(i int) rune {
+		if i < 0 || i >= len(clusters) {
+			return 0
+		}
+		return []rune(clusters[i])[0]
+	}
+
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for i, cluster := range clusters {
+		r := base(i)
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana):
+			flush()
+			words = append(words, cluster)
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			word.WriteString(cluster)
+		case word.Len() > 0 && joinsWord(base(i-1), r, base(i+1)):
+			word.WriteString(cluster)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// joinsWord reports whether r between prev and next belongs to the word:
+// an apostrophe between letters or a decimal or thousands separator
+// between digits
+func joinsWord(prev, r, next rune) bool {
+	switch r {
+	case '\'', '’':
+		return unicode.IsLetter(prev) && unicode.IsLetter(next)
+	case '.', ',':
+		return unicode.IsDigit(prev) && unicode.IsDigit(next)
+	}
+	return false
+}
+
+// WordAnalysis describes the words of a string. Word lengths count
+// grapheme clusters, and words are compared case-insensitively: the
+// frequency map is keyed by the case-folded word. Tokenizer names the
+// tokenizer that found the words.
+//
+// word_count is not derived from it: it counts the runs of non-space
+// characters, as it always has, because the stores index it and it cannot
+// change with the tokenizer. "well-known" is one word to word_count and
+// two here.
+type WordAnalysis struct {
+	Tokenizer          string         `json:"tokenizer"`
+	WordFrequencyMap   map[string]int `json:"word_frequency_map"`
+	LongestWord        string         `json:"longest_word"`
+	LongestWordLength  int            `json:"longest_word_length"`
+	ShortestWord       string         `json:"shortest_word"`
+	ShortestWordLength int            `json:"shortest_word_length"`
+	// AverageWordLength and LexicalDiversity, the share of the words that
+	// are distinct, are rounded to two decimals
+	AverageWordLength float64 `json:"average_word_length"`
+	DistinctWords     int     `json:"distinct_words"`
+	LexicalDiversity  float64 `json:"lexical_diversity"`
+}
+
+// AnalyzeWords splits s with the active tokenizer and measures its words.
+// The first of several longest or shortest words is reported.
+func AnalyzeWords(s string) *WordAnalysis {
+	tokenizer := ActiveTokenizer()
+	words := tokenizer.Tokenize(s)
+	analysis := &WordAnalysis{Tokenizer: tokenizer.Name(), WordFrequencyMap: make(map[string]int)}
+	if len(words) == 0 {
+		return analysis
+	}
+
+	fold := cases.Fold()
+	total := 0
+	for _, word := range words {
+		analysis.WordFrequencyMap[fold.String(word)]++
+		length := CountGraphemes(word)
+		total += length
+		if length > analysis.LongestWordLength {
+			analysis.LongestWord, analysis.LongestWordLength = word, length
+		}
+		if analysis.ShortestWord == "" || length < analysis.ShortestWordLength {
+			analysis.ShortestWord, analysis.ShortestWordLength = word, length
+		}
+	}
+	analysis.DistinctWords = len(analysis.WordFrequencyMap)
+	analysis.AverageWordLength = roundHundredths(float64(total) / float64(len(words)))
+	analysis.LexicalDiversity = roundHundredths(float64(analysis.DistinctWords) / float64(len(words)))
+	return analysis
+}
+
+// current reports whether the active tokenizer found the words
+func (w *WordAnalysis) current() bool {
+	return w != nil && w.Tokenizer == ActiveTokenizer().Name()
+}
+
+func roundHundredths(x float64) float64 {
+	return math.Round(x*100) / 100
+}
@@ -0,0 +1,1036 @@
+package routes
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	utils "hng/step0/util"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+var ginMode = os.Getenv("GIN_MODE")
+
+// SetupRoutes configures all the API routes on top of the given string store
+func SetupRoutes(bank store.StringStore) *gin.Engine {
+	// Create Gin router with default middleware (logger and recovery)
+	if ginMode != "" {
+		gin.SetMode(ginMode)
+	}
+	router := gin.Default()
+	queries := store.NewQueryLog(queryLogSize())
+	facts := factProvider()
+	budget := numberBudget()
+
+	// Add CORS middleware to allow cross-origin requests
+	router.Use(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+		// Handle preflight requests
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Health check endpoint
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "healthy",
+			"service":   "HNG Step 1 API",
+			"timestamp": time.Now().UTC(),
+		})
+	})
+
+	// Main endpoint for creating/analyzing strings
+	router.POST("/strings", func(c *gin.Context) {
+		var requestBody struct {
+			Value          string `json:"value" binding:"required"`
+			PalindromeMode string `json:"palindrome_mode"`
+		}
+
+		if err := c.ShouldBindJSON(&requestBody); err != nil {
+			if ute, ok := err.(*json.UnmarshalTypeError); ok {
+				if ute.Value != "string" {
+					c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Invalid data type for \"%s\" (must be string)", ute.Field)})
+					return
+				}
+			} else {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid request body or missing \"value\" field"})
+				return
+			}
+		}
+
+		handler := helpers.StringApiHandler{String: requestBody.Value}
+		if requestBody.PalindromeMode != "" {
+			mode, err := helpers.ParsePalindromeMode(requestBody.PalindromeMode)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid palindrome_mode %q: %v", requestBody.PalindromeMode, err)})
+				return
+			}
+			handler.PalindromeMode = &mode
+		}
+		fields, err := parseFields(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response := handler.GetStringFields(fields)
+		if err := bank.Create(response); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				c.JSON(http.StatusConflict, gin.H{"error": "String already exists in the system"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store string"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, response.Select(fields))
+	})
+
+	router.GET("/strings/:string_value", func(c *gin.Context) {
+		fields, err := parseFields(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		stringValue := c.Param("string_value")
+		response, err := bank.GetByValue(stringValue)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		respondString(c, bank, response, fields)
+	})
+
+	router.GET("/strings", func(c *gin.Context) {
+		var filteredResponse struct {
+			Data           any               `json:"data"`
+			Count          int               `json:"count"`
+			FiltersApplied map[string]string `json:"filters_applied"`
+			CanonicalQuery string            `json:"canonical_query,omitempty"`
+			CanonicalURL   string            `json:"canonical_url"`
+			NextCursor     string            `json:"next_cursor"`
+		}
+
+		// Validate query parameter values and types, respond 400 if invalid
+		filter, filtersApplied, err := parseFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query parameter values or types"})
+			return
+		}
+		page, paginate, err := parsePageRequest(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fields, err := parseFields(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		terms, err := parseLengthUnit(c, &filter, filtersApplied)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		palindrome, err := parsePalindromeMode(c, &filter, filtersApplied)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		terms = append(terms, palindrome...)
+		classes, err := parseNumberClasses(c, filtersApplied)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		terms = append(terms, classes...)
+		analyzed, err := parseAnalyzerParams(c, filtersApplied)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		terms = append(terms, analyzed...)
+		if q := c.Query("q"); q != "" {
+			expression, err := helpers.ParseFilterExpression(q)
+			if err != nil {
+				var syntaxErr *helpers.SyntaxError
+				errors.As(err, &syntaxErr)
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "position": syntaxErr.Position})
+				return
+			}
+			filtersApplied["q"] = expression.String()
+			terms = append(terms, expression)
+		}
+		tree := helpers.FilterTree{Filter: filter}
+		switch len(terms) {
+		case 0:
+		case 1:
+			tree.Expression = terms[0]
+		default:
+			tree.Expression = &helpers.AndExpr{Terms: terms}
+		}
+
+		// Respond 422 if no string could ever satisfy the filters
+		if conflicts := tree.Conflicts(); len(conflicts) > 0 {
+			respondConflicts(c, "Query resulted in conflicting filters", conflicts)
+			return
+		}
+
+		filteredBank, err := selectMatching(bank, tree)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		if paginate {
+			filteredBank, filteredResponse.NextCursor, err = store.Paginate(filteredBank, page)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		store.Complete(bank, filteredBank, fields)
+		filteredResponse.Data = helpers.SelectAll(filteredBank, fields)
+		filteredResponse.Count = len(filteredBank)
+		filteredResponse.FiltersApplied = filtersApplied
+		filteredResponse.CanonicalQuery, _ = tree.CanonicalQuery()
+		filteredResponse.CanonicalURL = tree.CanonicalURL()
+
+		c.JSON(http.StatusOK, filteredResponse)
+	})
+
+	router.GET("/strings/filter-by-natural-language", func(c *gin.Context) {
+		started := time.Now()
+		query := c.DefaultQuery("query", "")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter is required"})
+			return
+		}
+
+		grammar, languageSource, err := queryGrammar(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "supported_languages": helpers.Languages()})
+			return
+		}
+		fields, err := parseFields(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Parse the natural language query
+		var interpretation helpers.Interpretation
+		if grammar != nil {
+			interpretation, err = grammar.Interpret(query)
+		} else {
+			interpretation, err = helpers.InterpretNaturalLanguageQuery(query)
+		}
+		parseTime := time.Since(started)
+		// Every outcome is recorded for GET /admin/nl-queries
+		record := func(outcome string) {
+			queries.Record(store.QueryEvent{
+				Time:       started,
+				Query:      query,
+				Normalized: helpers.NormalizeQuery(query),
+				Outcome:    outcome,
+				Language:   interpretation.Language,
+				Confidence: interpretation.Confidence,
+				ParseTime:  parseTime,
+				Duration:   time.Since(started),
+			})
+		}
+		if err != nil {
+			record(store.QueryUnparsed)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "Unable to parse natural language query",
+				"interpreted_query": interpretedQuery{Original: query, LanguageSource: languageSource, Interpretation: interpretation},
+			})
+			return
+		}
+		tree := interpretation.Filter
+		if err := tree.Validate(); err != nil {
+			record(store.QueryUnparsed)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":             "Invalid natural language query: " + err.Error(),
+				"interpreted_query": interpretedQuery{Original: query, LanguageSource: languageSource, Interpretation: interpretation},
+			})
+			return
+		}
+
+		// Check for conflicting filters; a query with alternatives is only
+		// conflicting when every alternative is
+		if conflicts := tree.Conflicts(); len(conflicts) > 0 {
+			record(store.QueryConflicting)
+			respondConflicts(c, "Query parsed but resulted in conflicting filters", conflicts)
+			return
+		}
+
+		// Apply filters to get matching strings
+		filteredBank, err := selectMatching(bank, tree)
+		record(store.QueryParsed)
+		if err != nil {
+			respondStoreError(c, err)
+			return
+		}
+
+		// Build response
+		var filteredResponse struct {
+			Data             any              `json:"data"`
+			Count            int              `json:"count"`
+			InterpretedQuery interpretedQuery `json:"interpreted_query"`
+		}
+
+		store.Complete(bank, filteredBank, fields)
+		filteredResponse.Data = helpers.SelectAll(filteredBank, fields)
+		filteredResponse.Count = len(filteredBank)
+		filteredResponse.InterpretedQuery = interpretedQuery{
+			Original:       query,
+			ParsedFilters:  tree.ToMap(),
+			CanonicalURL:   tree.CanonicalURL(),
+			LanguageSource: languageSource,
+			Interpretation: interpretation,
+		}
+		filteredResponse.InterpretedQuery.CanonicalQuery, _ = tree.CanonicalQuery()
+
+		c.JSON(http.StatusOK, filteredResponse)
+	})
+
+	// The vocabulary of the natural-language parser; read-only, it is
+	// changed by editing the lexicon file. lang selects another language's
+	// lexicon, which includes the English one it is layered over.
+	router.GET("/lexicon", func(c *gin.Context) {
+		lang := c.Query("lang")
+		if lang == "" {
+			c.JSON(http.StatusOK, helpers.ActiveLexicon())
+			return
+		}
+		lexicon, ok := helpers.LanguageLexicon(lang)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No lexicon for language %q", lang), "supported_languages": helpers.Languages()})
+			return
+		}
+		c.JSON(http.StatusOK, lexicon)
+	})
+
+	// Outcomes of natural-language queries: success rates over a window
+	// and the phrasings that fail most, to guide work on the grammar
+	router.GET("/admin/nl-queries", requireAdmin(os.Getenv("ADMIN_TOKEN")), func(c *gin.Context) {
+		window, bucket, limit, err := parseAnalyticsParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		until := time.Now()
+		c.JSON(http.StatusOK, queries.Summarize(until.Add(-window), until, bucket, limit))
+	})
+
+	// Number classification with the utils toolkit, plus a fun fact
+	router.GET("/api/classify-number", func(c *gin.Context) {
+		raw := c.Query("number")
+		n, err := parseInteger(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "number": raw})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+		classification, err := utils.Classify(ctx, n)
+		if err != nil {
+			respondBudgetExceeded(c, budget, raw)
+			return
+		}
+		classification.FunFact = funFact(ctx, facts, classification)
+		c.JSON(http.StatusOK, classification)
+	})
+
+	// Prime factorization and what follows from it, within the time budget
+	router.GET("/api/numbers/:n/factors", func(c *gin.Context) {
+		raw := c.Param("n")
+		n, err := parseInteger(raw)
+		if err == nil && n.Sign() <= 0 {
+			err = fmt.Errorf("Only positive integers can be factorized, got %s", raw)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "number": raw})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), budget)
+		defer cancel()
+		analysis, err := utils.AnalyzeDivisors(ctx, n)
+		if err != nil {
+			respondBudgetExceeded(c, budget, raw)
+			return
+		}
+		c.JSON(http.StatusOK, analysis)
+	})
+
+	router.DELETE("/strings/:string_value", func(c *gin.Context) {
+		stringValue := c.Param("string_value")
+		if err := bank.Delete(stringValue); err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		c.JSON(http.StatusNoContent, nil)
+	})
+
+	// Lookup and delete by ID, or by a unique prefix of it (like git short hashes)
+	router.GET("/strings/id/:id", func(c *gin.Context) {
+		fields, err := parseFields(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		response, ok := resolveID(c, bank)
+		if !ok {
+			return
+		}
+		respondString(c, bank, response, fields)
+	})
+
+	router.DELETE("/strings/id/:id", func(c *gin.Context) {
+		response, ok := resolveID(c, bank)
+		if !ok {
+			return
+		}
+		if err := bank.Delete(response.Value); err != nil {
+			respondStoreError(c, err)
+			return
+		}
+		c.JSON(http.StatusNoContent, nil)
+	})
+
+	// API documentation endpoint
+	router.GET("/", func(c *gin.Context) {
+		docs := map[string]any{
+			"service":     "HNG Step 0 API",
+			"version":     "1.0.0",
+			"description": "API for string analysis and natural language filtering",
+			"endpoints": map[string]any{
+				"POST /strings": map[string]any{
+					"description": "Create/Analyze Strings endpoint",
+					"request": map[string]string{
+						"value":           "string to analyze",
+						"palindrome_mode": "optional normalization for is_palindrome, e.g. case,whitespace,punctuation (default case,whitespace)",
+					},
+					"query_params": map[string]string{
+						"fields": "comma-separated properties to return, e.g. length,is_palindrome (default all); lazy and registered properties left out are not computed, indexed ones always are; also taken by every GET that returns strings",
+					},
+					"response": helpers.Response{
+						ID:    "sha256_hash_value",
+						Value: "string to analyze",
+						Properties: helpers.PropertiesMap{
+							Length:           17,
+							ByteLength:       17,
+							RuneCount:        17,
+							IsPalindrome:     false,
+							PalindromeMode:   helpers.DefaultPalindromeMode.String(),
+							UniqueCharacters: 12,
+							WordCount:        3,
+							Sha256Hash:       "abc123...",
+							CharacterFrequencyMap: helpers.CharacterFrequencyMap{
+								"s": 2,
+								"t": 3,
+								"r": 2,
+							},
+							WordAnalysis: &helpers.WordAnalysis{
+								Tokenizer:          helpers.UnicodeTokenizer.Name(),
+								WordFrequencyMap:   map[string]int{"string": 1, "to": 1, "analyze": 1},
+								LongestWord:        "analyze",
+								LongestWordLength:  7,
+								ShortestWord:       "to",
+								ShortestWordLength: 2,
+								AverageWordLength:  5,
+								DistinctWords:      3,
+								LexicalDiversity:   1,
+							},
+						},
+						CreatedAt: "2025-08-27T10:00:00Z",
+					},
+				},
+				"GET /strings": map[string]any{
+					"description": "Get all strings with optional filtering",
+					"query_params": map[string]string{
+						"is_palindrome":        "true/false",
+						"palindrome_mode":      "recheck is_palindrome under a comma-separated normalization: case (or case:tr), diacritics, whitespace, punctuation, digits; or none or default",
+						"min_length":           "number",
+						"max_length":           "number",
+						"length_unit":          "unit of min_length and max_length: graphemes (default), runes or bytes",
+						"word_count":           "number",
+						"contains_character":   "single character",
+						"length_is":            "comma-separated number classes: prime, perfect, armstrong, even, odd",
+						"word_count_is":        "comma-separated number classes, as for length_is; every number property has a <property>_is",
+						"unique_characters_is": "comma-separated number classes, as for length_is",
+						"<property>":           "for other filterable properties, such as longest_word_length, distinct_words and those of registered analyzers: true/false for a flag, a number for a number property, which also takes min_<property> and max_<property>",
+						"q":                    "filter expression over the filterable properties, e.g. (is_palindrome OR word_count >= 3) AND length IS prime",
+					},
+				},
+				"GET /strings/filter-by-natural-language": map[string]any{
+					"description": "Filter strings using natural language queries",
+					"query_params": map[string]string{
+						"query": "natural language query (e.g., 'all single word palindromic strings')",
+						"lang":  "language of the query: en, fr, es or yo; otherwise taken from Accept-Language, or detected",
+					},
+					"example_queries": []string{
+						"all single word palindromic strings",
+						"strings longer than 10 characters",
+						"palindromic strings that contain the first vowel",
+						"strings containing the letter z",
+						"strings between three and ten characters that are not palindromes",
+						"strings with at least two words that don't contain the letter e",
+						"strings with a prime number of characters",
+						"strings whose longest word exceeds 8 letters",
+						"chaînes palindromiques de plus de 5 caractères",
+						"cadenas que no contienen la letra z",
+						"okùn tí ó ní ọ̀rọ̀ méjì",
+					},
+					"interpreted_query": "original, language and language_source (param, header or detected), parsed_filters, canonical_query (always English) and canonical_url, spans of the query that were understood, ignored_words, confidence (0-1) and, for unclear queries, suggestions",
+				},
+				"GET /strings/:string_value": map[string]any{
+					"description": "Get a specific string by value",
+				},
+				"DELETE /strings/:string_value": map[string]any{
+					"description": "Delete a specific string by value",
+				},
+				"GET /strings/id/:id": map[string]any{
+					"description": "Get a specific string by ID or by a unique ID prefix (at least 4 hex characters)",
+				},
+				"DELETE /strings/id/:id": map[string]any{
+					"description": "Delete a specific string by ID or by a unique ID prefix (at least 4 hex characters)",
+				},
+				"GET /admin/nl-queries": map[string]any{
+					"description": "Natural-language query analytics: outcome counts, success rate and parse time over a window, per bucket, and the most frequent unparsed and conflicting phrasings. Requires Authorization: Bearer $ADMIN_TOKEN",
+					"query_params": map[string]string{
+						"window": "how far back to look (default 24h, at most 720h)",
+						"bucket": "width of each bucket (default 1h, at most 1000 buckets)",
+						"limit":  "how many phrasings to list (default 10, at most 100)",
+					},
+				},
+				"GET /api/classify-number": map[string]any{
+					"description": "Classify an integer: is_prime, is_perfect, properties (armstrong, odd or even), digit_sum and a fun_fact. Negative numbers are never prime, perfect or Armstrong and keep their sign in digit_sum; floats are rejected. Numbers may have up to 1000 digits; one that takes longer than NUMBER_TIME_BUDGET returns 422",
+					"query_params": map[string]string{
+						"number": "integer to classify, e.g. 371, up to 1000 digits",
+					},
+				},
+				"GET /api/numbers/:n/factors": map[string]any{
+					"description": "Prime factorization of a positive integer of up to 1000 digits, with divisor_count, divisor_sum, abundance (abundant, deficient or perfect), totient and is_squarefree. A number that cannot be factorized within NUMBER_TIME_BUDGET returns 422",
+				},
+				"GET /lexicon": map[string]any{
+					"description": "The natural-language vocabulary and synonyms in use, with their source and load time",
+					"query_params": map[string]string{
+						"lang": "language whose lexicon to show (default en)",
+					},
+				},
+			},
+			"properties": describeAnalyzers(),
+		}
+
+		c.JSON(http.StatusOK, docs)
+	})
+
+	return router
+}
+
+// describeAnalyzers lists the registered analyzers for the docs, with the
+// filter type of those that can be filtered on
+func describeAnalyzers() []map[string]any {
+	var properties []map[string]any
+	for _, a := range helpers.Analyzers() {
+		property := map[string]any{
+			"name":    a.Name(),
+			"version": a.Version(),
+			"builtin": helpers.IsBuiltinAnalyzer(a),
+			"lazy":    helpers.IsLazyAnalyzer(a),
+		}
+		if a.FilterType() != helpers.FilterNone {
+			property["filter"] = a.FilterType()
+		}
+		properties = append(properties, property)
+	}
+	return properties
+}
+
+// parseFilter reads the structured filters of GET /strings. A zero
+// length or word count means "no constraint", as before.
+func parseFilter(c *gin.Context) (helpers.Filter, map[string]string, error) {
+	var filter helpers.Filter
+	filtersApplied := make(map[string]string)
+
+	if isPalindrome := c.Query("is_palindrome"); isPalindrome != "" {
+		if isPalindrome != "true" && isPalindrome != "false" {
+			return filter, nil, fmt.Errorf("invalid is_palindrome %q", isPalindrome)
+		}
+		value := isPalindrome == "true"
+		filter.IsPalindrome = &value
+		filtersApplied["is_palindrome"] = isPalindrome
+	}
+
+	intParams := []struct {
+		name   string
+		target **int
+	}{
+		{"min_length", &filter.MinLength},
+		{"max_length", &filter.MaxLength},
+		{"word_count", &filter.WordCount},
+	}
+	for _, param := range intParams {
+		raw := c.Query(param.name)
+		if raw == "" || raw == "0" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return filter, nil, fmt.Errorf("invalid %s %q", param.name, raw)
+		}
+		*param.target = &value
+		filtersApplied[param.name] = raw
+	}
+
+	if containsCharacter := c.Query("contains_character"); containsCharacter != "" {
+		filter.ContainsCharacter = containsCharacter
+		filtersApplied["contains_character"] = containsCharacter
+	}
+
+	return filter, filtersApplied, filter.Validate()
+}
+
+// lengthUnits maps the length_unit values onto the property min_length and
+// max_length compare; graphemes is the default and needs no expression
+var lengthUnits = map[string]string{
+	"graphemes": "length",
+	"runes":     "rune_count",
+	"bytes":     "byte_length",
+}
+
+// parseLengthUnit reads length_unit. For runes or bytes, min_length and
+// max_length move out of the filter into comparisons on that property.
+func parseLengthUnit(c *gin.Context, filter *helpers.Filter, filtersApplied map[string]string) ([]helpers.ExprNode, error) {
+	unit := c.Query("length_unit")
+	if unit == "" {
+		return nil, nil
+	}
+	field, ok := lengthUnits[unit]
+	if !ok {
+		return nil, fmt.Errorf("Invalid length_unit %q: expected graphemes, runes or bytes", unit)
+	}
+	filtersApplied["length_unit"] = unit
+	if field == "length" {
+		return nil, nil
+	}
+
+	var terms []helpers.ExprNode
+	if filter.MinLength != nil {
+		terms = append(terms, &helpers.CompareExpr{Field: field, Op: ">=", Value: *filter.MinLength})
+		filter.MinLength = nil
+	}
+	if filter.MaxLength != nil {
+		terms = append(terms, &helpers.CompareExpr{Field: field, Op: "<=", Value: *filter.MaxLength})
+		filter.MaxLength = nil
+	}
+	return terms, nil
+}
+
+// parsePalindromeMode reads palindrome_mode, which rechecks every string
+// for is_palindrome under that mode instead of reading the stored flag
+func parsePalindromeMode(c *gin.Context, filter *helpers.Filter, filtersApplied map[string]string) ([]helpers.ExprNode, error) {
+	raw := c.Query("palindrome_mode")
+	if raw == "" {
+		return nil, nil
+	}
+	mode, err := helpers.ParsePalindromeMode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid palindrome_mode %q: %v", raw, err)
+	}
+	if filter.IsPalindrome == nil {
+		return nil, fmt.Errorf("Invalid palindrome_mode %q: it only applies together with is_palindrome", raw)
+	}
+	filtersApplied["palindrome_mode"] = mode.String()
+
+	var term helpers.ExprNode = &helpers.PalindromeExpr{Mode: mode}
+	if !*filter.IsPalindrome {
+		term = &helpers.NotExpr{Operand: term}
+	}
+	filter.IsPalindrome = nil
+	return []helpers.ExprNode{term}, nil
+}
+
+// parseNumberClasses reads <property>_is for every number property, such
+// as length_is, each a comma-separated list of number classes the property
+// must belong to, as length IS prime terms
+func parseNumberClasses(c *gin.Context, filtersApplied map[string]string) ([]helpers.ExprNode, error) {
+	var terms []helpers.ExprNode
+	for _, field := range helpers.FilterableProperties(helpers.FilterNumber) {
+		param := field + "_is"
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		for _, class := range strings.Split(raw, ",") {
+			class = strings.ToLower(strings.TrimSpace(class))
+			if !helpers.IsNumberClass(class) {
+				return nil, fmt.Errorf("Invalid %s %q: expected a comma-separated list of %s", param, raw, strings.Join(helpers.NumberClasses(), ", "))
+			}
+			terms = append(terms, &helpers.NumberClassExpr{Field: field, Class: class})
+		}
+		filtersApplied[param] = raw
+	}
+	return terms, nil
+}
+
+// structuredProperties are the properties parseFilter and length_unit read
+// parameters for
+var structuredProperties = map[string]bool{
+	"is_palindrome": true,
+	"length":        true,
+	"byte_length":   true,
+	"rune_count":    true,
+	"word_count":    true,
+}
+
+// parseAnalyzerParams reads the parameters of the other filterable
+// properties, such as those of registered analyzers: <name> set to true or
+// false for a flag, and <name>, min_<name> and max_<name> for a number
+func parseAnalyzerParams(c *gin.Context, filtersApplied map[string]string) ([]helpers.ExprNode, error) {
+	var terms []helpers.ExprNode
+	for _, a := range helpers.Analyzers() {
+		if structuredProperties[a.Name()] {
+			continue
+		}
+		name := a.Name()
+		switch a.FilterType() {
+		case helpers.FilterFlag:
+			raw := c.Query(name)
+			if raw == "" {
+				continue
+			}
+			if raw != "true" && raw != "false" {
+				return nil, fmt.Errorf("Invalid %s %q: expected true or false", name, raw)
+			}
+			var term helpers.ExprNode = &helpers.FlagExpr{Field: name}
+			if raw == "false" {
+				term = &helpers.NotExpr{Operand: term}
+			}
+			terms = append(terms, term)
+			filtersApplied[name] = raw
+		case helpers.FilterNumber:
+			for _, param := range []struct{ name, op string }{{name, "="}, {"min_" + name, ">="}, {"max_" + name, "<="}} {
+				raw := c.Query(param.name)
+				if raw == "" {
+					continue
+				}
+				value, err := strconv.Atoi(raw)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid %s %q: expected an integer", param.name, raw)
+				}
+				terms = append(terms, &helpers.CompareExpr{Field: name, Op: param.op, Value: value})
+				filtersApplied[param.name] = raw
+			}
+		}
+	}
+	return terms, nil
+}
+
+// selectMatching returns the strings matching a filter tree. The part of
+// the tree every match satisfies is pushed down to the store; the rest is
+// checked on what comes back.
+func selectMatching(bank store.StringStore, tree helpers.FilterTree) ([]helpers.Response, error) {
+	items, err := store.Select(bank, tree.PushDown())
+	if err != nil || tree.Expression == nil && tree.And == nil && tree.Or == nil && tree.Not == nil {
+		return items, err
+	}
+	return tree.Apply(items), nil
+}
+
+// interpretedQuery reports how a natural-language query was read: the
+// filters it produced, their canonical English and GET /strings forms, the
+// spans of the query behind them, the words that were ignored and a
+// confidence score. LanguageSource says how the language was chosen.
+type interpretedQuery struct {
+	Original       string                 `json:"original"`
+	LanguageSource string                 `json:"language_source"`
+	ParsedFilters  map[string]interface{} `json:"parsed_filters,omitempty"`
+	CanonicalQuery string                 `json:"canonical_query,omitempty"`
+	CanonicalURL   string                 `json:"canonical_url,omitempty"`
+	helpers.Interpretation
+}
+
+// queryLogSize reads NL_QUERY_LOG_SIZE, how many natural-language query
+// outcomes GET /admin/nl-queries can look back over (default 10000)
+func queryLogSize() int {
+	value := os.Getenv("NL_QUERY_LOG_SIZE")
+	if value == "" {
+		return defaultQueryLogSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 1 {
+		fmt.Printf("⚠️  Invalid NL_QUERY_LOG_SIZE %q, keeping %d queries\n", value, defaultQueryLogSize)
+		return defaultQueryLogSize
+	}
+	return size
+}
+
+const defaultQueryLogSize = 10000
+
+// factProvider picks where GET /api/classify-number gets its fun facts:
+//
+//	FUN_FACT_PROVIDER  numbersapi (default) or local, which works offline
+//	NUMBERS_API_URL    base URL of the Numbers API (default http://numbersapi.com)
+func factProvider() utils.FactProvider {
+	switch provider := os.Getenv("FUN_FACT_PROVIDER"); provider {
+	case "", "numbersapi":
+	case "local":
+		return utils.LocalFacts{}
+	default:
+		fmt.Printf("⚠️  Unknown FUN_FACT_PROVIDER %q, using numbersapi\n", provider)
+	}
+	return utils.NumbersAPI{BaseURL: os.Getenv("NUMBERS_API_URL")}
+}
+
+// funFact asks the provider for a fact within what is left of the time
+// budget in ctx, making one up locally when it fails or the budget is
+// spent, so that classification never depends on the network
+func funFact(ctx context.Context, facts utils.FactProvider, classification utils.Classification) string {
+	if ctx.Err() == nil {
+		fact, err := facts.Fact(ctx, classification)
+		if err == nil {
+			return fact
+		}
+		fmt.Printf("⚠️  Fun fact for %s unavailable, using a local one: %v\n", classification.Number, err)
+	}
+	fact, _ := utils.LocalFacts{}.Fact(ctx, classification)
+	return fact
+}
+
+// maxNumberDigits bounds the numbers the number endpoints accept. Primality
+// tests run in steps of one modular exponentiation, which takes
+// milliseconds at this size, so the time budget is checked often enough.
+const maxNumberDigits = 1000
+
+// parseInteger reads a number for the number endpoints. Only integers are accepted:
+// "3.0" and "1e3" are floats, even though they have integer values.
+func parseInteger(raw string) (*big.Int, error) {
+	if raw == "" {
+		return nil, errors.New("Missing \"number\" query parameter")
+	}
+	// The length is checked first, as parsing a long number is costly
+	if digits := strings.TrimLeft(raw, "+-"); len(digits) > maxNumberDigits {
+		if strings.Trim(digits, "0123456789") == "" {
+			return nil, fmt.Errorf("Number has %d digits, more than the %d allowed", len(digits), maxNumberDigits)
+		}
+	} else if n, ok := new(big.Int).SetString(raw, 10); ok {
+		return n, nil
+	}
+	if _, err := strconv.ParseFloat(raw, 64); err == nil {
+		return nil, fmt.Errorf("Invalid number %q: only integers can be classified", raw)
+	}
+	return nil, fmt.Errorf("Invalid number %q: expected an integer", raw)
+}
+
+// numberBudget is how long the number endpoints may work on one request:
+//
+//	NUMBER_TIME_BUDGET  a duration such as 500ms (default 2s)
+func numberBudget() time.Duration {
+	value := os.Getenv("NUMBER_TIME_BUDGET")
+	if value == "" {
+		return defaultNumberBudget
+	}
+	budget, err := time.ParseDuration(value)
+	if err != nil || budget <= 0 {
+		fmt.Printf("⚠️  Invalid NUMBER_TIME_BUDGET %q, keeping %s\n", value, defaultNumberBudget)
+		return defaultNumberBudget
+	}
+	return budget
+}
+
+const defaultNumberBudget = 2 * time.Second
+
+// respondBudgetExceeded answers a number that took too long to work on
+func respondBudgetExceeded(c *gin.Context, budget time.Duration, raw string) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":  fmt.Sprintf("Number could not be analyzed within the %s time budget", budget),
+		"number": raw,
+	})
+}
+
+// requireAdmin guards the admin endpoints with a bearer token. Without a
+// token configured they are disabled.
+func requireAdmin(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled; set ADMIN_TOKEN to enable them"})
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseAnalyticsParams reads window, bucket and limit for
+// GET /admin/nl-queries
+func parseAnalyticsParams(c *gin.Context) (time.Duration, time.Duration, int, error) {
+	window, err := time.ParseDuration(c.DefaultQuery("window", "24h"))
+	if err != nil || window <= 0 || window > 720*time.Hour {
+		return 0, 0, 0, fmt.Errorf("Invalid window %q: expected a duration such as 1h, at most 720h", c.Query("window"))
+	}
+	bucket, err := time.ParseDuration(c.DefaultQuery("bucket", "1h"))
+	if err != nil || bucket <= 0 || bucket > window {
+		return 0, 0, 0, fmt.Errorf("Invalid bucket %q: expected a duration no longer than the window", c.Query("bucket"))
+	}
+	if window/bucket > 1000 {
+		return 0, 0, 0, fmt.Errorf("Too many buckets: a %s window in %s buckets is more than 1000", window, bucket)
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 || limit > 100 {
+		return 0, 0, 0, fmt.Errorf("Invalid limit %q: expected 1 to 100", c.Query("limit"))
+	}
+	return window, bucket, limit, nil
+}
+
+// queryGrammar picks the grammar for a natural-language query: the lang
+// parameter, then the Accept-Language header. A nil grammar means neither
+// named a supported language and the language is to be detected.
+func queryGrammar(c *gin.Context) (helpers.Grammar, string, error) {
+	if lang := c.Query("lang"); lang != "" && lang != "auto" {
+		grammar, ok := helpers.LookupGrammar(lang)
+		if !ok {
+			return nil, "", fmt.Errorf("Unsupported language %q", lang)
+		}
+		return grammar, "param", nil
+	}
+	if grammar, ok := helpers.MatchGrammar(c.GetHeader("Accept-Language")); ok && c.Query("lang") == "" {
+		return grammar, "header", nil
+	}
+	return nil, "detected", nil
+}
+
+// respondConflicts writes the 422 response for a filter no string can satisfy
+func respondConflicts(c *gin.Context, message string, conflicts []string) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": message, "conflicts": conflicts})
+}
+
+// maxPageLimit caps the limit parameter of GET /strings
+const maxPageLimit = 1000
+
+// parsePageRequest reads the sort, limit, cursor and locale parameters of
+// GET /strings. Without any of them the listing keeps insertion order and is
+// not paginated. Paging without an explicit sort orders by created_at.
+func parsePageRequest(c *gin.Context) (store.PageRequest, bool, error) {
+	var page store.PageRequest
+	sortParam, limitParam, cursorParam, localeParam := c.Query("sort"), c.Query("limit"), c.Query("cursor"), c.Query("locale")
+	if sortParam == "" && limitParam == "" && cursorParam == "" {
+		return page, false, nil
+	}
+	if sortParam == "" {
+		sortParam = "created_at"
+	}
+
+	keys, err := store.ParseSort(sortParam)
+	if err != nil {
+		return page, false, err
+	}
+	page.Sort = keys
+	page.Cursor = cursorParam
+
+	if limitParam != "" {
+		limit, err := strconv.Atoi(limitParam)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, false, fmt.Errorf("limit must be a number between 1 and %d", maxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	page.Locale = language.Und
+	if localeParam != "" {
+		tag, err := language.Parse(localeParam)
+		if err != nil {
+			return page, false, fmt.Errorf("invalid locale %q", localeParam)
+		}
+		page.Locale = tag
+	}
+	return page, true, nil
+}
+
+// minIDPrefixLength is the shortest ID prefix accepted, as in git
+const minIDPrefixLength = 4
+
+// resolveID looks up the string named by the :id parameter and writes the
+// error response itself when there is no single match
+func resolveID(c *gin.Context, bank store.StringStore) (helpers.Response, bool) {
+	id := strings.ToLower(c.Param("id"))
+	if len(id) < minIDPrefixLength || len(id) > sha256.Size*2 || strings.Trim(id, "0123456789abcdef") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ID must be %d to %d hexadecimal characters", minIDPrefixLength, sha256.Size*2)})
+		return helpers.Response{}, false
+	}
+
+	response, err := store.ResolveID(bank, id)
+	var ambiguous *store.AmbiguousIDError
+	if errors.As(err, &ambiguous) {
+		candidates := make([]gin.H, len(ambiguous.Candidates))
+		for i, candidate := range ambiguous.Candidates {
+			candidates[i] = gin.H{"id": candidate.ID, "value": candidate.Value}
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"error":      "ID prefix is ambiguous",
+			"candidates": candidates,
+		})
+		return helpers.Response{}, false
+	}
+	if err != nil {
+		respondStoreError(c, err)
+		return helpers.Response{}, false
+	}
+	return response, true
+}
+
+// parseFields reads fields, the properties to compute and return. Without
+// it, every property is.
+func parseFields(c *gin.Context) ([]string, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	fields, err := helpers.ParseFields(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fields %q: %v", raw, err)
+	}
+	return fields, nil
+}
+
+// respondString writes one stored string with the properties among
+// fields, computing and keeping the lazy ones it lacks
+func respondString(c *gin.Context, bank store.StringStore, response helpers.Response, fields []string) {
+	items := []helpers.Response{response}
+	store.Complete(bank, items, fields)
+	c.JSON(http.StatusOK, items[0].Select(fields))
+}
+
+// respondStoreError maps store errors onto HTTP responses
+func respondStoreError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Storage error"})
+}
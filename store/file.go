@@ -0,0 +1,405 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	walFileName      = "strings.wal.jsonl"
+	snapshotFileName = "strings.snapshot.json"
+)
+
+// FsyncPolicy controls when the write-ahead log is flushed to disk
+type FsyncPolicy string
+
+const (
+	// FsyncAlways syncs the log after every write (safest, slowest)
+	FsyncAlways FsyncPolicy = "always"
+	// FsyncInterval syncs the log in the background every FsyncInterval
+	FsyncInterval FsyncPolicy = "interval"
+	// FsyncNever leaves flushing to the operating system
+	FsyncNever FsyncPolicy = "never"
+)
+
+// ParseFsyncPolicy validates a policy name read from configuration
+func ParseFsyncPolicy(s string) (FsyncPolicy, error) {
+	switch p := FsyncPolicy(s); p {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+		return p, nil
+	case "":
+		return FsyncAlways, nil
+	}
+	return "", fmt.Errorf("unknown fsync policy %q (expected always, interval or never)", s)
+}
+
+// FileOptions configures a FileStore
+type FileOptions struct {
+	// Dir holds the write-ahead log and the snapshot
+	Dir string
+	// Fsync is the log flushing policy, FsyncAlways when empty
+	Fsync FsyncPolicy
+	// FsyncInterval is the background sync period for FsyncInterval
+	FsyncInterval time.Duration
+	// CompactInterval is how often the log is folded into a snapshot, zero disables it
+	CompactInterval time.Duration
+}
+
+// walRecord is one line of the write-ahead log
+type walRecord struct {
+	Seq    uint64            `json:"seq"`
+	Op     string            `json:"op"`
+	Record *helpers.Response `json:"record,omitempty"`
+	Value  string            `json:"value,omitempty"`
+}
+
+// snapshot is the compacted state of the store up to Seq
+type snapshot struct {
+	Seq   uint64             `json:"seq"`
+	Items []helpers.Response `json:"items"`
+}
+
+// FileStore is a durable StringStore. Every create and delete is appended to
+// a JSONL write-ahead log before it is applied in memory, and the log is
+// replayed on startup. Compaction writes the full state to a snapshot and
+// starts a fresh log.
+type FileStore struct {
+	*MemoryStore
+
+	opts FileOptions
+
+	// mu serializes writers so the duplicate check, the log append and the
+	// in-memory update happen as one step
+	mu    sync.Mutex
+	wal   *os.File
+	seq   uint64
+	dirty bool
+
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// OpenFileStore loads the snapshot and replays the log in opts.Dir, creating
+// the directory if needed
+func OpenFileStore(opts FileOptions) (*FileStore, error) {
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncAlways
+	}
+	if opts.Fsync == FsyncInterval && opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f := &FileStore{
+		MemoryStore: NewMemoryStore(),
+		opts:        opts,
+		stop:        make(chan struct{}),
+	}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := f.replay(); err != nil {
+		return nil, err
+	}
+
+	wal, err := os.OpenFile(f.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.wal = wal
+
+	if opts.Fsync == FsyncInterval {
+		f.every(opts.FsyncInterval, f.Sync)
+	}
+	if opts.CompactInterval > 0 {
+		f.every(opts.CompactInterval, f.Compact)
+	}
+	return f, nil
+}
+
+func (f *FileStore) walPath() string {
+	return filepath.Join(f.opts.Dir, walFileName)
+}
+
+func (f *FileStore) snapshotPath() string {
+	return filepath.Join(f.opts.Dir, snapshotFileName)
+}
+
+func (f *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(f.snapshotPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("corrupt snapshot %s: %w", f.snapshotPath(), err)
+	}
+	for _, item := range snap.Items {
+		if err := f.MemoryStore.Create(upgradeRecord(item)); err != nil {
+			return fmt.Errorf("corrupt snapshot %s: %w", f.snapshotPath(), err)
+		}
+	}
+	f.seq = snap.Seq
+	return nil
+}
+
+// replay applies every log record newer than the snapshot. A record that
+// fails to decode at the very end of the log is the remains of a write cut
+// short by a crash: it is dropped and the log is truncated back to the last
+// complete record. A bad record anywhere else is reported as corruption.
+func (f *FileStore) replay() error {
+	file, err := os.OpenFile(f.walPath(), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(line) == 0 && readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		var record walRecord
+		complete := readErr == nil
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil || !complete {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				fmt.Printf("⚠️  Dropping truncated record at end of %s (offset %d)\n", f.walPath(), offset)
+				return file.Truncate(offset)
+			}
+			return fmt.Errorf("corrupt record in %s at offset %d: %v", f.walPath(), offset, err)
+		}
+		if record.Seq > f.seq {
+			if err := f.apply(record); err != nil {
+				return fmt.Errorf("replaying %s at offset %d: %w", f.walPath(), offset, err)
+			}
+			f.seq = record.Seq
+		}
+		offset += int64(len(line))
+	}
+}
+
+func (f *FileStore) apply(record walRecord) error {
+	switch record.Op {
+	case "create":
+		if record.Record == nil {
+			return errors.New("create record without a string")
+		}
+		return f.MemoryStore.Create(upgradeRecord(*record.Record))
+	case "delete":
+		return f.MemoryStore.Delete(record.Value)
+	case "memoize":
+		if record.Record == nil {
+			return errors.New("memoize record without a string")
+		}
+		return f.MemoryStore.Memoize(*record.Record)
+	}
+	return fmt.Errorf("unknown operation %q", record.Op)
+}
+
+// upgradeRecord reanalyzes a string written before properties counted
+// grapheme clusters, which has no rune_count, and rechecks one written
+// before palindrome modes in the default mode
+func upgradeRecord(r helpers.Response) helpers.Response {
+	switch {
+	case r.Value == "":
+	case r.Properties.RuneCount == 0:
+		handler := helpers.StringApiHandler{String: r.Value}
+		r.Properties = handler.Analyze()
+	case r.Properties.PalindromeMode == "":
+		r.Properties.IsPalindrome = helpers.DefaultPalindromeMode.IsPalindrome(r.Value)
+		r.Properties.PalindromeMode = helpers.DefaultPalindromeMode.String()
+	}
+	return r
+}
+
+// append writes one record to the log, honoring the fsync policy
+func (f *FileStore) append(record walRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := f.wal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if f.opts.Fsync == FsyncAlways {
+		return f.wal.Sync()
+	}
+	f.dirty = true
+	return nil
+}
+
+func (f *FileStore) Create(r helpers.Response) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.MemoryStore.GetByValue(r.Value); err == nil {
+		return ErrAlreadyExists
+	}
+	record := walRecord{Seq: f.seq + 1, Op: "create", Record: &r}
+	if err := f.append(record); err != nil {
+		return err
+	}
+	f.seq = record.Seq
+	return f.MemoryStore.Create(r)
+}
+
+// Memoize logs the lazily computed properties of a string, so they are not
+// computed again after a restart. Replaying the log merges them in order,
+// as Memoize does.
+func (f *FileStore) Memoize(r helpers.Response) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.MemoryStore.GetByID(r.ID); err != nil {
+		return err
+	}
+	record := walRecord{Seq: f.seq + 1, Op: "memoize", Record: &r}
+	if err := f.append(record); err != nil {
+		return err
+	}
+	f.seq = record.Seq
+	return f.MemoryStore.Memoize(r)
+}
+
+func (f *FileStore) Delete(value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.MemoryStore.GetByValue(value); err != nil {
+		return err
+	}
+	record := walRecord{Seq: f.seq + 1, Op: "delete", Value: value}
+	if err := f.append(record); err != nil {
+		return err
+	}
+	f.seq = record.Seq
+	return f.MemoryStore.Delete(value)
+}
+
+// Sync flushes pending log writes to disk
+func (f *FileStore) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+	f.dirty = false
+	return f.wal.Sync()
+}
+
+// Compact writes the current state to the snapshot file and starts an
+// empty log. The snapshot is written to a temporary file and renamed into
+// place, so a crash leaves either the old or the new snapshot. Log records
+// already covered by the snapshot are skipped on replay by sequence number.
+func (f *FileStore) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	items, err := f.MemoryStore.List()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot{Seq: f.seq, Items: items})
+	if err != nil {
+		return err
+	}
+
+	tmp := f.snapshotPath() + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.snapshotPath()); err != nil {
+		return err
+	}
+	syncDir(f.opts.Dir)
+
+	if err := f.wal.Truncate(0); err != nil {
+		return err
+	}
+	f.dirty = false
+	return f.wal.Sync()
+}
+
+// Close stops background work and flushes the log
+func (f *FileStore) Close() error {
+	close(f.stop)
+	f.done.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.wal.Sync(); err != nil {
+		f.wal.Close()
+		return err
+	}
+	return f.wal.Close()
+}
+
+// every runs fn on a ticker until Close is called
+func (f *FileStore) every(interval time.Duration, fn func() error) {
+	f.done.Add(1)
+	go func() {
+		defer f.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					fmt.Printf("❌ File store background task failed: %v\n", err)
+				}
+			case <-f.stop:
+				return
+			}
+		}
+	}()
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir makes a rename in dir durable; failures are ignored because not
+// every platform supports syncing directories
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	helpers "hng/step0/helpers"
+	"math/bits"
+	"slices"
+	"sort"
+)
+
+// bitmap is a growable set of slot numbers
+type bitmap []uint64
+
+func (b *bitmap) set(slot uint32) {
+	word := int(slot / 64)
+	for len(*b) <= word {
+		*b = append(*b, 0)
+	}
+	(*b)[word] |= 1 << (slot % 64)
+}
+
+func (b bitmap) clear(slot uint32) {
+	if word := int(slot / 64); word < len(b) {
+		b[word] &^= 1 << (slot % 64)
+	}
+}
+
+func (b bitmap) has(slot uint32) bool {
+	word := int(slot / 64)
+	return word < len(b) && b[word]&(1<<(slot%64)) != 0
+}
+
+// each calls fn for every slot in the set, in ascending order
+func (b bitmap) each(fn func(slot uint32)) {
+	for i, word := range b {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			fn(uint32(i*64 + bit))
+			word &= word - 1
+		}
+	}
+}
+
+// postings is a sorted list of slot numbers. Slots are handed out in
+// increasing order, so adding a new string is always an append.
+type postings []uint32
+
+func (p postings) without(slot uint32) postings {
+	if i, found := slices.BinarySearch(p, slot); found {
+		return slices.Delete(p, i, i+1)
+	}
+	return p
+}
+
+// stringIndex holds the secondary indexes of a MemoryStore
+type stringIndex struct {
+	live        bitmap
+	palindromes bitmap
+	liveCount   int
+	palCount    int
+
+	lengths    map[int]postings
+	lengthKeys []int // distinct lengths, sorted, for range scans
+	wordCounts map[int]postings
+	characters map[string]postings
+}
+
+func newStringIndex() *stringIndex {
+	return &stringIndex{
+		lengths:    make(map[int]postings),
+		wordCounts: make(map[int]postings),
+		characters: make(map[string]postings),
+	}
+}
+
+func (x *stringIndex) add(slot uint32, r helpers.Response) {
+	x.live.set(slot)
+	x.liveCount++
+	if r.Properties.IsPalindrome {
+		x.palindromes.set(slot)
+		x.palCount++
+	}
+
+	length := r.Properties.Length
+	if _, exists := x.lengths[length]; !exists {
+		i, _ := slices.BinarySearch(x.lengthKeys, length)
+		x.lengthKeys = slices.Insert(x.lengthKeys, i, length)
+	}
+	x.lengths[length] = append(x.lengths[length], slot)
+	x.wordCounts[r.Properties.WordCount] = append(x.wordCounts[r.Properties.WordCount], slot)
+	for _, character := range distinctCharacters(r.Value) {
+		x.characters[character] = append(x.characters[character], slot)
+	}
+}
+
+func (x *stringIndex) remove(slot uint32, r helpers.Response) {
+	x.live.clear(slot)
+	x.liveCount--
+	if x.palindromes.has(slot) {
+		x.palindromes.clear(slot)
+		x.palCount--
+	}
+
+	length := r.Properties.Length
+	if x.lengths[length] = x.lengths[length].without(slot); len(x.lengths[length]) == 0 {
+		delete(x.lengths, length)
+		if i, found := slices.BinarySearch(x.lengthKeys, length); found {
+			x.lengthKeys = slices.Delete(x.lengthKeys, i, i+1)
+		}
+	}
+	dropPosting(x.wordCounts, r.Properties.WordCount, slot)
+	for _, character := range distinctCharacters(r.Value) {
+		dropPosting(x.characters, character, slot)
+	}
+}
+
+func dropPosting[K comparable](m map[K]postings, key K, slot uint32) {
+	if m[key] = m[key].without(slot); len(m[key]) == 0 {
+		delete(m, key)
+	}
+}
+
+// lengthRange returns the posting lists of every length in [min, max]
+func (x *stringIndex) lengthRange(c helpers.Filter) []postings {
+	lo := 0
+	if c.MinLength != nil {
+		lo = sort.SearchInts(x.lengthKeys, *c.MinLength)
+	}
+	hi := len(x.lengthKeys)
+	if c.MaxLength != nil {
+		hi = sort.SearchInts(x.lengthKeys, *c.MaxLength+1)
+	}
+	if lo >= hi {
+		return nil
+	}
+	lists := make([]postings, 0, hi-lo)
+	for _, length := range x.lengthKeys[lo:hi] {
+		lists = append(lists, x.lengths[length])
+	}
+	return lists
+}
+
+// candidates returns the slots matching c in ascending order. It drives the
+// search from the most selective index and probes the rest per candidate,
+// so the cost follows the size of the smallest posting list rather than the
+// number of stored strings.
+func (x *stringIndex) candidates(c helpers.Filter, records []helpers.Response) []uint32 {
+	// Each indexed criterion offers the slots it could match and their count
+	type source struct {
+		size int
+		scan func(fn func(slot uint32))
+	}
+	var sources []source
+
+	if c.WordCount != nil {
+		list := x.wordCounts[*c.WordCount]
+		sources = append(sources, source{len(list), eachPosting(list)})
+	}
+	if c.ContainsCharacter != "" {
+		list := x.characters[c.ContainsCharacter]
+		sources = append(sources, source{len(list), eachPosting(list)})
+	}
+	if c.MinLength != nil || c.MaxLength != nil {
+		lists := x.lengthRange(c)
+		size := 0
+		for _, list := range lists {
+			size += len(list)
+		}
+		sources = append(sources, source{size, func(fn func(slot uint32)) {
+			for _, list := range lists {
+				eachPosting(list)(fn)
+			}
+		}})
+	}
+	if c.IsPalindrome != nil && *c.IsPalindrome {
+		sources = append(sources, source{x.palCount, x.palindromes.each})
+	}
+	if len(sources) == 0 {
+		sources = append(sources, source{x.liveCount, x.live.each})
+	}
+
+	driver := slices.MinFunc(sources, func(a, b source) int { return a.size - b.size })
+	matches := make([]uint32, 0, driver.size)
+	driver.scan(func(slot uint32) {
+		if !x.live.has(slot) {
+			return
+		}
+		if c.IsPalindrome != nil && x.palindromes.has(slot) != *c.IsPalindrome {
+			return
+		}
+		r := records[slot]
+		if c.MinLength != nil && r.Properties.Length < *c.MinLength {
+			return
+		}
+		if c.MaxLength != nil && r.Properties.Length > *c.MaxLength {
+			return
+		}
+		if c.WordCount != nil && r.Properties.WordCount != *c.WordCount {
+			return
+		}
+		if c.ContainsCharacter != "" {
+			if _, found := slices.BinarySearch(x.characters[c.ContainsCharacter], slot); !found {
+				return
+			}
+		}
+		matches = append(matches, slot)
+	})
+
+	// Length buckets are visited by length, not by slot
+	slices.Sort(matches)
+	return matches
+}
+
+func eachPosting(list postings) func(fn func(slot uint32)) {
+	return func(fn func(slot uint32)) {
+		for _, slot := range list {
+			fn(slot)
+		}
+	}
+}
+
+// distinctCharacters lists the characters of s once each, keyed the same way
+// as CharacterFrequencyMap
+func distinctCharacters(s string) []string {
+	seen := make(map[rune]struct{})
+	characters := make([]string, 0, len(s))
+	for _, char := range s {
+		if _, dup := seen[char]; !dup {
+			seen[char] = struct{}{}
+			characters = append(characters, string(char))
+		}
+	}
+	return characters
+}
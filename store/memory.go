@@ -0,0 +1,174 @@
+package store
+
+import (
+	helpers "hng/step0/helpers"
+	"sync"
+)
+
+// MemoryStore keeps analyzed strings in process memory. It is safe for
+// concurrent use: reads share a read lock and writes are serialized, so the
+// duplicate check in Create and the insert happen atomically.
+//
+// Every string lives in a numbered slot. Slots are never reused, which keeps
+// the secondary indexes in insertion order; deleted slots are reclaimed by
+// renumbering once they outnumber the live ones.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records []helpers.Response
+	byValue map[string]uint32
+	byID    map[string]uint32
+	ids     *idTrie
+	index   *stringIndex
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		byValue: make(map[string]uint32),
+		byID:    make(map[string]uint32),
+		ids:     &idTrie{},
+		index:   newStringIndex(),
+	}
+}
+
+func (m *MemoryStore) Create(r helpers.Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.byValue[r.Value]; exists {
+		return ErrAlreadyExists
+	}
+	m.insert(r)
+	return nil
+}
+
+func (m *MemoryStore) insert(r helpers.Response) {
+	slot := uint32(len(m.records))
+	m.records = append(m.records, r)
+	m.byValue[r.Value] = slot
+	m.byID[r.ID] = slot
+	m.ids.insert(r.ID, slot)
+	m.index.add(slot, r)
+}
+
+func (m *MemoryStore) GetByValue(value string) (helpers.Response, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	slot, exists := m.byValue[value]
+	if !exists {
+		return helpers.Response{}, ErrNotFound
+	}
+	return m.records[slot], nil
+}
+
+func (m *MemoryStore) GetByID(id string) (helpers.Response, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	slot, exists := m.byID[id]
+	if !exists {
+		return helpers.Response{}, ErrNotFound
+	}
+	return m.records[slot], nil
+}
+
+func (m *MemoryStore) MatchIDPrefix(prefix string, limit int) ([]helpers.Response, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	slots := m.ids.withPrefix(prefix, limit)
+	items := make([]helpers.Response, len(slots))
+	for i, slot := range slots {
+		items[i] = m.records[slot]
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) List() ([]helpers.Response, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := make([]helpers.Response, 0, m.index.liveCount)
+	m.index.live.each(func(slot uint32) {
+		items = append(items, m.records[slot])
+	})
+	return items, nil
+}
+
+// Query evaluates the filter against the secondary indexes
+func (m *MemoryStore) Query(c helpers.Filter) ([]helpers.Response, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	slots := m.index.candidates(c, m.records)
+	items := make([]helpers.Response, len(slots))
+	for i, slot := range slots {
+		items[i] = m.records[slot]
+	}
+	return items, nil
+}
+
+// Memoize merges the lazy properties of r into the stored string. The
+// indexed properties never change, so the indexes stay as they are.
+func (m *MemoryStore) Memoize(r helpers.Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot, exists := m.byID[r.ID]
+	if !exists {
+		return ErrNotFound
+	}
+	m.records[slot].Properties.Merge(r.Properties)
+	return nil
+}
+
+func (m *MemoryStore) Delete(value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	slot, exists := m.byValue[value]
+	if !exists {
+		return ErrNotFound
+	}
+	r := m.records[slot]
+	m.index.remove(slot, r)
+	delete(m.byValue, r.Value)
+	delete(m.byID, r.ID)
+	m.ids.remove(r.ID)
+	m.records[slot] = helpers.Response{}
+
+	if dead := len(m.records) - m.index.liveCount; dead > 1024 && dead > m.index.liveCount {
+		m.renumber()
+	}
+	return nil
+}
+
+// renumber drops deleted slots and rebuilds the indexes
+func (m *MemoryStore) renumber() {
+	live := make([]helpers.Response, 0, m.index.liveCount)
+	m.index.live.each(func(slot uint32) {
+		live = append(live, m.records[slot])
+	})
+
+	m.records = make([]helpers.Response, 0, len(live))
+	m.byValue = make(map[string]uint32, len(live))
+	m.byID = make(map[string]uint32, len(live))
+	m.ids = &idTrie{}
+	m.index = newStringIndex()
+	for _, r := range live {
+		m.insert(r)
+	}
+}
+
+func (m *MemoryStore) Count() (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.index.liveCount, nil
+}
+
+var (
+	_ Querier  = (*MemoryStore)(nil)
+	_ Memoizer = (*MemoryStore)(nil)
+)
@@ -0,0 +1,279 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// ErrInvalidCursor is returned when a cursor is malformed or was issued for
+// a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// sortKind is how the values of a sort key compare
+type sortKind int
+
+const (
+	sortNumber sortKind = iota
+	sortFlag
+	sortText
+	// sortCollated text compares with the request's locale rules
+	sortCollated
+)
+
+// sortField describes one sort key. The fields of the response itself
+// have a reader; properties are read by name.
+type sortField struct {
+	kind   sortKind
+	record func(r *helpers.Response) string
+}
+
+// recordSortFields are the sort keys that are not properties
+var recordSortFields = map[string]sortField{
+	"value":      {kind: sortCollated, record: func(r *helpers.Response) string { return r.Value }},
+	"id":         {kind: sortText, record: func(r *helpers.Response) string { return r.ID }},
+	"created_at": {kind: sortText, record: func(r *helpers.Response) string { return r.CreatedAt }},
+}
+
+// lookupSortField finds a sort key: a field of the response, or a number,
+// flag or text property of a registered analyzer
+func lookupSortField(name string) (sortField, bool) {
+	if field, ok := recordSortFields[name]; ok {
+		return field, true
+	}
+	a, ok := helpers.LookupAnalyzer(name)
+	switch {
+	case !ok:
+		return sortField{}, false
+	case a.FilterType() == helpers.FilterNumber:
+		return sortField{kind: sortNumber}, true
+	case a.FilterType() == helpers.FilterFlag:
+		return sortField{kind: sortFlag}, true
+	case helpers.IsTextProperty(name):
+		return sortField{kind: sortText}, true
+	}
+	return sortField{}, false
+}
+
+// value reads the sort key of r named name. Lazy properties r lacks are
+// computed without being kept.
+func (field sortField) value(name string, r *helpers.Response) any {
+	switch {
+	case field.record != nil:
+		return field.record(r)
+	case field.kind == sortNumber:
+		return r.Number(name)
+	case field.kind == sortFlag:
+		return r.Flag(name)
+	}
+	value, _ := r.Properties.Value(name)
+	text, _ := value.(string)
+	return text
+}
+
+// compareField orders two values of one sort key
+func compareField(field sortField, collator *collate.Collator, a, b any) int {
+	switch field.kind {
+	case sortNumber:
+		return cmp.Compare(a.(int), b.(int))
+	case sortFlag:
+		x, y := a.(bool), b.(bool)
+		if x == y {
+			return 0
+		} else if x {
+			return 1
+		}
+		return -1
+	case sortCollated:
+		if c := collator.CompareString(a.(string), b.(string)); c != 0 {
+			return c
+		}
+	}
+	// Plain text fields, and a deterministic order for collation-equal strings
+	return strings.Compare(a.(string), b.(string))
+}
+
+// SortKey is one comparison of a multi-key sort
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort reads a sort specification such as "length,-created_at,word_count",
+// where a leading "-" sorts that key in descending order
+func ParseSort(spec string) ([]SortKey, error) {
+	var keys []SortKey
+	if spec == "" {
+		return keys, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		key := SortKey{Field: strings.TrimSpace(part)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field, key.Desc = key.Field[1:], true
+		}
+		if _, ok := lookupSortField(key.Field); !ok {
+			return nil, fmt.Errorf("unknown sort key %q", key.Field)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// PageRequest describes one page of a sorted listing
+type PageRequest struct {
+	Sort []SortKey
+	// Limit is the page size, zero returns everything after the cursor
+	Limit int
+	// Cursor is the next_cursor of the previous page, empty for the first page
+	Cursor string
+	// Locale selects the collation rules for text sort keys
+	Locale language.Tag
+}
+
+// cursor marks the position after the last item of a page by its sort key
+// values, so a page boundary survives inserts and deletes around it. The ID
+// is always the final key, which makes every position unique.
+type cursor struct {
+	Sort   string            `json:"s"`
+	Locale string            `json:"l"`
+	Keys   []json.RawMessage `json:"k"`
+}
+
+// keyed is an item with the values of its sort keys, read once
+type keyed struct {
+	item   helpers.Response
+	values []any
+}
+
+// Paginate sorts items by the requested keys and returns the page following
+// the cursor, plus the cursor of the next page ("" on the last page)
+func Paginate(items []helpers.Response, req PageRequest) ([]helpers.Response, string, error) {
+	keys := append(slices.Clone(req.Sort), SortKey{Field: "id"})
+	fields := make([]sortField, len(keys))
+	for i, key := range keys {
+		var ok bool
+		if fields[i], ok = lookupSortField(key.Field); !ok {
+			return nil, "", fmt.Errorf("unknown sort key %q", key.Field)
+		}
+	}
+	spec := sortSpec(req.Sort)
+	collator := collate.New(req.Locale)
+
+	compare := func(a, b []any) int {
+		for i, key := range keys {
+			c := compareField(fields[i], collator, a[i], b[i])
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+
+	sorted := make([]keyed, len(items))
+	for i := range items {
+		sorted[i] = keyed{item: items[i], values: make([]any, len(keys))}
+		for j, key := range keys {
+			sorted[i].values[j] = fields[j].value(key.Field, &items[i])
+		}
+	}
+	slices.SortFunc(sorted, func(a, b keyed) int { return compare(a.values, b.values) })
+
+	start := 0
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor, spec, req.Locale, fields)
+		if err != nil {
+			return nil, "", err
+		}
+		start, _ = slices.BinarySearchFunc(sorted, after, func(k keyed, after []any) int {
+			if compare(k.values, after) <= 0 {
+				return -1
+			}
+			return 1
+		})
+	}
+
+	end := len(sorted)
+	if req.Limit > 0 && start+req.Limit < end {
+		end = start + req.Limit
+	}
+	page := make([]helpers.Response, 0, end-start)
+	for _, k := range sorted[start:end] {
+		page = append(page, k.item)
+	}
+
+	next := ""
+	if end < len(sorted) && len(page) > 0 {
+		next = encodeCursor(sorted[end-1].values, spec, req.Locale)
+	}
+	return page, next, nil
+}
+
+func sortSpec(keys []SortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.Field
+		if key.Desc {
+			parts[i] = "-" + key.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(values []any, spec string, locale language.Tag) string {
+	c := cursor{Sort: spec, Locale: locale.String()}
+	for _, value := range values {
+		data, _ := json.Marshal(value)
+		c.Keys = append(c.Keys, data)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads the sort key values of the last item of the previous
+// page
+func decodeCursor(token, spec string, locale language.Tag, fields []sortField) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Keys) != len(fields) {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != spec || c.Locale != locale.String() {
+		return nil, fmt.Errorf("%w: it was issued for sort %q and locale %q", ErrInvalidCursor, c.Sort, c.Locale)
+	}
+
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		switch field.kind {
+		case sortNumber:
+			var n int
+			err = json.Unmarshal(c.Keys[i], &n)
+			values[i] = n
+		case sortFlag:
+			var flag bool
+			err = json.Unmarshal(c.Keys[i], &flag)
+			values[i] = flag
+		default:
+			var text string
+			err = json.Unmarshal(c.Keys[i], &text)
+			values[i] = text
+		}
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return values, nil
+}
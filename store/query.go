@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	helpers "hng/step0/helpers"
+	"log"
+)
+
+// Querier is implemented by stores that can evaluate a filter natively
+// instead of scanning every string
+type Querier interface {
+	Query(f helpers.Filter) ([]helpers.Response, error)
+}
+
+// Memoizer is implemented by stores that keep the lazy properties computed
+// after a string was created
+type Memoizer interface {
+	// Memoize adds the lazy properties r has to the stored string with
+	// r's ID, keeping those it already has, as PropertiesMap.Merge does
+	Memoize(r helpers.Response) error
+}
+
+// Complete computes the lazy properties among fields, nil for all, that the
+// items lack, and stores them when the store supports it. Storing them is
+// only a cache write: a string deleted since it was read is skipped, and
+// other failures are logged without failing the caller.
+func Complete(s StringStore, items []helpers.Response, fields []string) {
+	memoizer, memoizes := s.(Memoizer)
+	for i := range items {
+		if !items[i].Complete(fields) || !memoizes {
+			continue
+		}
+		if err := memoizer.Memoize(items[i]); err != nil && !errors.Is(err, ErrNotFound) {
+			log.Printf("store: keeping the lazy properties of %s: %v", items[i].ID, err)
+		}
+	}
+}
+
+// Select returns the strings matching f, pushing the filter down to the
+// store when it supports it
+func Select(s StringStore, f helpers.Filter) ([]helpers.Response, error) {
+	if q, ok := s.(Querier); ok {
+		return q.Query(f)
+	}
+
+	items, err := s.List()
+	if err != nil || f.IsEmpty() {
+		return items, err
+	}
+	return f.Apply(items), nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Outcomes of a natural-language query
+const (
+	QueryParsed      = "parsed"
+	QueryUnparsed    = "unparsed"
+	QueryConflicting = "conflicting"
+)
+
+// QueryEvent is one natural-language query and what became of it
+type QueryEvent struct {
+	Time  time.Time `json:"time"`
+	Query string    `json:"query"`
+	// Normalized groups phrasings that differ only in case, accents or
+	// numbers; see helpers.NormalizeQuery
+	Normalized string  `json:"normalized"`
+	Outcome    string  `json:"outcome"`
+	Language   string  `json:"language,omitempty"`
+	Confidence float64 `json:"confidence"`
+	// ParseTime is spent interpreting the query, Duration on the whole
+	// request
+	ParseTime time.Duration `json:"parse_time"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// QueryLog keeps the most recent natural-language query events in memory,
+// dropping the oldest once it holds capacity events. It is safe for
+// concurrent use.
+type QueryLog struct {
+	mu     sync.Mutex
+	events []QueryEvent
+	// next is where the next event goes once the log is full
+	next int
+}
+
+// NewQueryLog returns an empty log holding up to capacity events
+func NewQueryLog(capacity int) *QueryLog {
+	return &QueryLog{events: make([]QueryEvent, 0, max(capacity, 1))}
+}
+
+// Record adds an event, evicting the oldest when the log is full
+func (l *QueryLog) Record(event QueryEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) < cap(l.events) {
+		l.events = append(l.events, event)
+		return
+	}
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+}
+
+// Since returns the events recorded at or after t, oldest first
+func (l *QueryLog) Since(t time.Time) []QueryEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []QueryEvent
+	for _, e := range slices.Concat(l.events[l.next:], l.events[:l.next]) {
+		if !e.Time.Before(t) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// QueryStats counts query outcomes. SuccessRate is the share of queries
+// that parsed without conflicts, 0 when there were none.
+type QueryStats struct {
+	Total        int     `json:"total"`
+	Parsed       int     `json:"parsed"`
+	Unparsed     int     `json:"unparsed"`
+	Conflicting  int     `json:"conflicting"`
+	SuccessRate  float64 `json:"success_rate"`
+	AvgParseTime float64 `json:"avg_parse_time_ms"`
+	MaxParseTime float64 `json:"max_parse_time_ms"`
+}
+
+// QueryBucket is the stats of one stretch of a summary window
+type QueryBucket struct {
+	Start time.Time `json:"start"`
+	QueryStats
+}
+
+// QueryPhrasing is a normalized query with how often it was seen
+type QueryPhrasing struct {
+	Normalized string    `json:"normalized"`
+	Count      int       `json:"count"`
+	Example    string    `json:"example"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// QuerySummary describes the queries of a time window
+type QuerySummary struct {
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+	QueryStats
+	Buckets        []QueryBucket   `json:"buckets"`
+	TopUnparsed    []QueryPhrasing `json:"top_unparsed"`
+	TopConflicting []QueryPhrasing `json:"top_conflicting"`
+}
+
+// Summarize reports the queries in [since, until): overall stats, stats
+// per bucket of the given width, and the limit most frequent unparsed and
+// conflicting phrasings
+func (l *QueryLog) Summarize(since, until time.Time, bucket time.Duration, limit int) QuerySummary {
+	var events []QueryEvent
+	for _, e := range l.Since(since) {
+		if e.Time.Before(until) {
+			events = append(events, e)
+		}
+	}
+
+	summary := QuerySummary{Since: since, Until: until, QueryStats: queryStats(events)}
+	byBucket := make([][]QueryEvent, (until.Sub(since)+bucket-1)/bucket)
+	for _, e := range events {
+		i := e.Time.Sub(since) / bucket
+		byBucket[i] = append(byBucket[i], e)
+	}
+	summary.Buckets = make([]QueryBucket, len(byBucket))
+	for i, inBucket := range byBucket {
+		summary.Buckets[i] = QueryBucket{Start: since.Add(time.Duration(i) * bucket), QueryStats: queryStats(inBucket)}
+	}
+	summary.TopUnparsed = topPhrasings(events, QueryUnparsed, limit)
+	summary.TopConflicting = topPhrasings(events, QueryConflicting, limit)
+	return summary
+}
+
+func queryStats(events []QueryEvent) QueryStats {
+	var stats QueryStats
+	var parseTime time.Duration
+	for _, e := range events {
+		stats.Total++
+		switch e.Outcome {
+		case QueryParsed:
+			stats.Parsed++
+		case QueryUnparsed:
+			stats.Unparsed++
+		case QueryConflicting:
+			stats.Conflicting++
+		}
+		parseTime += e.ParseTime
+		stats.MaxParseTime = max(stats.MaxParseTime, milliseconds(e.ParseTime))
+	}
+	if stats.Total > 0 {
+		stats.SuccessRate = float64(stats.Parsed) / float64(stats.Total)
+		stats.AvgParseTime = milliseconds(parseTime / time.Duration(stats.Total))
+	}
+	return stats
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// topPhrasings counts the normalized forms of events with the outcome,
+// most frequent first, ties broken by the most recent
+func topPhrasings(events []QueryEvent, outcome string, limit int) []QueryPhrasing {
+	byForm := make(map[string]*QueryPhrasing)
+	for _, e := range events {
+		if e.Outcome != outcome {
+			continue
+		}
+		phrasing, ok := byForm[e.Normalized]
+		if !ok {
+			phrasing = &QueryPhrasing{Normalized: e.Normalized}
+			byForm[e.Normalized] = phrasing
+		}
+		phrasing.Count++
+		phrasing.Example, phrasing.LastSeen = e.Query, e.Time
+	}
+
+	phrasings := make([]QueryPhrasing, 0, len(byForm))
+	for _, phrasing := range byForm {
+		phrasings = append(phrasings, *phrasing)
+	}
+	slices.SortFunc(phrasings, func(a, b QueryPhrasing) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	if limit > 0 && len(phrasings) > limit {
+		phrasings = phrasings[:limit]
+	}
+	return phrasings
+}
@@ -0,0 +1,435 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// migrations are applied in order and recorded in schema_migrations. Never
+// edit a migration that has shipped; append a new one instead.
+var migrations = []string{
+	// 1: one column per PropertiesMap field
+	`CREATE TABLE strings (
+		seq                     INTEGER PRIMARY KEY AUTOINCREMENT,
+		id                      TEXT    NOT NULL UNIQUE,
+		value                   TEXT    NOT NULL UNIQUE,
+		length                  INTEGER NOT NULL,
+		is_palindrome           INTEGER NOT NULL,
+		unique_characters       INTEGER NOT NULL,
+		word_count              INTEGER NOT NULL,
+		sha256_hash             TEXT    NOT NULL,
+		character_frequency_map TEXT    NOT NULL,
+		created_at              TEXT    NOT NULL
+	);
+	CREATE INDEX strings_length ON strings (length);
+	CREATE INDEX strings_is_palindrome ON strings (is_palindrome);
+	CREATE INDEX strings_word_count ON strings (word_count);`,
+
+	// 2: posting list of the characters each string contains, so
+	// contains_character is an index lookup instead of a LIKE scan
+	`CREATE TABLE string_characters (
+		string_seq INTEGER NOT NULL REFERENCES strings (seq) ON DELETE CASCADE,
+		character  TEXT    NOT NULL,
+		PRIMARY KEY (character, string_seq)
+	) WITHOUT ROWID;`,
+
+	// 3: byte and rune counts next to length, which now counts grapheme
+	// clusters; reanalyzeStrings fills them in
+	`ALTER TABLE strings ADD COLUMN byte_length INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE strings ADD COLUMN rune_count INTEGER NOT NULL DEFAULT 0;`,
+
+	// 4: the normalization is_palindrome was computed with;
+	// recheckPalindromes brings older rows to the default mode
+	`ALTER TABLE strings ADD COLUMN palindrome_mode TEXT NOT NULL DEFAULT '';`,
+
+	// 5: the properties of registered analyzers, as a JSON object
+	`ALTER TABLE strings ADD COLUMN extra_properties TEXT NOT NULL DEFAULT '{}';`,
+
+	// 6: the word analysis, null until it is first read
+	`ALTER TABLE strings ADD COLUMN word_analysis TEXT NOT NULL DEFAULT 'null';`,
+}
+
+// dataMigrations run in the same transaction as the schema migration of
+// their version, for changes SQL alone cannot make
+var dataMigrations = map[int]func(tx *sql.Tx) error{
+	3: reanalyzeStrings,
+	4: recheckPalindromes,
+}
+
+const stringColumns = `id, value, length, byte_length, rune_count, is_palindrome, palindrome_mode,
+	unique_characters, word_count, sha256_hash, character_frequency_map, word_analysis, extra_properties, created_at`
+
+// SQLiteStore keeps analyzed strings in an embedded SQLite database using
+// the pure-Go modernc.org/sqlite driver
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens (or creates) the database at path and brings its
+// schema up to date
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection keeps create-if-absent
+	// free of SQLITE_BUSY errors under concurrent requests
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// SchemaVersion returns the number of migrations applied to the database
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+func (s *SQLiteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("database schema version %d is newer than this build (%d)", current, len(migrations))
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if migrate := dataMigrations[version]; migrate != nil {
+			if err := migrate(tx); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %w", version, err)
+			}
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// stringValues reads every stored value by seq, for data migrations
+func stringValues(tx *sql.Tx) (map[int64]string, error) {
+	rows, err := tx.Query(`SELECT seq, value FROM strings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[int64]string)
+	for rows.Next() {
+		var seq int64
+		var value string
+		if err := rows.Scan(&seq, &value); err != nil {
+			return nil, err
+		}
+		values[seq] = value
+	}
+	return values, rows.Err()
+}
+
+// reanalyzeStrings recomputes the properties that moved from bytes and
+// runes to grapheme clusters, and the byte and rune counts
+func reanalyzeStrings(tx *sql.Tx) error {
+	values, err := stringValues(tx)
+	if err != nil {
+		return err
+	}
+	for seq, value := range values {
+		handler := helpers.StringApiHandler{String: value}
+		properties := handler.Analyze()
+		frequency, err := json.Marshal(properties.CharacterFrequencyMap)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE strings SET length = ?, byte_length = ?, rune_count = ?, is_palindrome = ?,
+			unique_characters = ?, character_frequency_map = ? WHERE seq = ?`,
+			properties.Length, properties.ByteLength, properties.RuneCount, properties.IsPalindrome,
+			properties.UniqueCharacters, string(frequency), seq)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recheckPalindromes recomputes is_palindrome in the default mode, which
+// also ignores tabs and newlines, and records that mode
+func recheckPalindromes(tx *sql.Tx) error {
+	values, err := stringValues(tx)
+	if err != nil {
+		return err
+	}
+	mode := helpers.DefaultPalindromeMode
+	for seq, value := range values {
+		_, err := tx.Exec(`UPDATE strings SET is_palindrome = ?, palindrome_mode = ? WHERE seq = ?`,
+			mode.IsPalindrome(value), mode.String(), seq)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Create(r helpers.Response) error {
+	frequency, words, extra, err := encodeLazyProperties(r.Properties)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ON CONFLICT DO NOTHING makes create-if-absent a single atomic statement
+	result, err := tx.Exec(`INSERT INTO strings (`+stringColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		r.ID, r.Value, r.Properties.Length, r.Properties.ByteLength, r.Properties.RuneCount,
+		r.Properties.IsPalindrome, r.Properties.PalindromeMode, r.Properties.UniqueCharacters,
+		r.Properties.WordCount, r.Properties.Sha256Hash, frequency, words, extra, r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrAlreadyExists
+	}
+	seq, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// contains_character takes a single rune, while the frequency map is
+	// keyed by grapheme cluster
+	for _, character := range distinctCharacters(r.Value) {
+		if _, err := tx.Exec(`INSERT INTO string_characters (string_seq, character) VALUES (?, ?)`, seq, character); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetByValue(value string) (helpers.Response, error) {
+	return s.getOne(`SELECT `+stringColumns+` FROM strings WHERE value = ?`, value)
+}
+
+func (s *SQLiteStore) GetByID(id string) (helpers.Response, error) {
+	return s.getOne(`SELECT `+stringColumns+` FROM strings WHERE id = ?`, id)
+}
+
+func (s *SQLiteStore) MatchIDPrefix(prefix string, limit int) ([]helpers.Response, error) {
+	// A range scan on the unique id index; LIKE would be case-insensitive
+	// and unable to use it
+	query := `SELECT ` + stringColumns + ` FROM strings WHERE id >= ?`
+	args := []any{prefix}
+	if upper, ok := prefixUpperBound(prefix); ok {
+		query += ` AND id < ?`
+		args = append(args, upper)
+	}
+	query += ` ORDER BY id`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return s.query(query, args...)
+}
+
+// prefixUpperBound returns the smallest string greater than every string
+// starting with prefix, or false when there is none (all 0xff bytes)
+func prefixUpperBound(prefix string) (string, bool) {
+	upper := []byte(prefix)
+	for i := len(upper) - 1; i >= 0; i-- {
+		if upper[i] < 0xff {
+			upper[i]++
+			return string(upper[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+func (s *SQLiteStore) List() ([]helpers.Response, error) {
+	return s.query(`SELECT ` + stringColumns + ` FROM strings ORDER BY seq`)
+}
+
+// Query evaluates the filter as indexed SQL predicates
+func (s *SQLiteStore) Query(c helpers.Filter) ([]helpers.Response, error) {
+	var where []string
+	var args []any
+
+	if c.IsPalindrome != nil {
+		where = append(where, "is_palindrome = ?")
+		args = append(args, *c.IsPalindrome)
+	}
+	if c.MinLength != nil {
+		where = append(where, "length >= ?")
+		args = append(args, *c.MinLength)
+	}
+	if c.MaxLength != nil {
+		where = append(where, "length <= ?")
+		args = append(args, *c.MaxLength)
+	}
+	if c.WordCount != nil {
+		where = append(where, "word_count = ?")
+		args = append(args, *c.WordCount)
+	}
+	if c.ContainsCharacter != "" {
+		where = append(where, "seq IN (SELECT string_seq FROM string_characters WHERE character = ?)")
+		args = append(args, c.ContainsCharacter)
+	}
+
+	query := `SELECT ` + stringColumns + ` FROM strings`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	return s.query(query+` ORDER BY seq`, args...)
+}
+
+func (s *SQLiteStore) Delete(value string) error {
+	result, err := s.db.Exec(`DELETE FROM strings WHERE value = ?`, value)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Count() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM strings`).Scan(&count)
+	return count, err
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// Memoize writes the properties that can be computed lazily: the character
+// frequency map, the word analysis and those of registered analyzers. It
+// merges them in the UPDATE, so a column r has no value for and extra
+// properties r lacks keep what another read stored.
+func (s *SQLiteStore) Memoize(r helpers.Response) error {
+	frequency, words, extra, err := encodeLazyProperties(r.Properties)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE strings SET
+		character_frequency_map = COALESCE(NULLIF(?1, 'null'), character_frequency_map),
+		word_analysis = COALESCE(NULLIF(?2, 'null'), word_analysis),
+		extra_properties = json_patch(extra_properties, ?3)
+		WHERE id = ?4`,
+		frequency, words, extra, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// encodeLazyProperties writes the JSON columns of the properties that can be
+// computed lazily
+func encodeLazyProperties(p helpers.PropertiesMap) (frequency, words, extra string, err error) {
+	data, err := json.Marshal(p.CharacterFrequencyMap)
+	if err != nil {
+		return "", "", "", err
+	}
+	analysis, err := json.Marshal(p.WordAnalysis)
+	if err != nil {
+		return "", "", "", err
+	}
+	extra = "{}"
+	if p.Extra != nil {
+		encoded, err := json.Marshal(p.Extra)
+		if err != nil {
+			return "", "", "", err
+		}
+		extra = string(encoded)
+	}
+	return string(data), string(analysis), extra, nil
+}
+
+func (s *SQLiteStore) getOne(query string, args ...any) (helpers.Response, error) {
+	items, err := s.query(query, args...)
+	if err != nil {
+		return helpers.Response{}, err
+	}
+	if len(items) == 0 {
+		return helpers.Response{}, ErrNotFound
+	}
+	return items[0], nil
+}
+
+func (s *SQLiteStore) query(query string, args ...any) ([]helpers.Response, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]helpers.Response, 0)
+	for rows.Next() {
+		var r helpers.Response
+		var frequency, words, extra string
+		err := rows.Scan(&r.ID, &r.Value, &r.Properties.Length, &r.Properties.ByteLength,
+			&r.Properties.RuneCount, &r.Properties.IsPalindrome, &r.Properties.PalindromeMode,
+			&r.Properties.UniqueCharacters, &r.Properties.WordCount, &r.Properties.Sha256Hash,
+			&frequency, &words, &extra, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(frequency), &r.Properties.CharacterFrequencyMap); err != nil {
+			return nil, fmt.Errorf("decoding character_frequency_map of %s: %w", r.ID, err)
+		}
+		if err := json.Unmarshal([]byte(words), &r.Properties.WordAnalysis); err != nil {
+			return nil, fmt.Errorf("decoding word_analysis of %s: %w", r.ID, err)
+		}
+		if err := json.Unmarshal([]byte(extra), &r.Properties.Extra); err != nil {
+			return nil, fmt.Errorf("decoding extra_properties of %s: %w", r.ID, err)
+		}
+		if len(r.Properties.Extra) == 0 {
+			r.Properties.Extra = nil
+		}
+		items = append(items, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+var (
+	_ Querier  = (*SQLiteStore)(nil)
+	_ Memoizer = (*SQLiteStore)(nil)
+)
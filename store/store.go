@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+)
+
+var (
+	// ErrNotFound is returned when no stored string matches the lookup
+	ErrNotFound = errors.New("string not found")
+	// ErrAlreadyExists is returned by Create when the value is already stored
+	ErrAlreadyExists = errors.New("string already exists")
+	// ErrAmbiguousID is matched by the *AmbiguousIDError returned by ResolveID
+	ErrAmbiguousID = errors.New("ambiguous id prefix")
+)
+
+// maxIDCandidates caps how many matches an ambiguous prefix reports
+const maxIDCandidates = 10
+
+// StringStore is the storage backend used by the API routes
+type StringStore interface {
+	// Create stores a new analyzed string, failing with ErrAlreadyExists on duplicates
+	Create(r helpers.Response) error
+	// GetByValue looks up a string by its raw value
+	GetByValue(value string) (helpers.Response, error)
+	// GetByID looks up a string by its ID (the SHA-256 of the value)
+	GetByID(id string) (helpers.Response, error)
+	// MatchIDPrefix returns up to limit strings whose ID starts with prefix,
+	// ordered by ID. A limit of zero means no limit.
+	MatchIDPrefix(prefix string, limit int) ([]helpers.Response, error)
+	// List returns every stored string in insertion order
+	List() ([]helpers.Response, error)
+	// Delete removes a string by its raw value
+	Delete(value string) error
+	// Count returns the number of stored strings
+	Count() (int, error)
+}
+
+// AmbiguousIDError is returned when an ID prefix matches more than one string
+type AmbiguousIDError struct {
+	Prefix     string
+	Candidates []helpers.Response
+}
+
+func (e *AmbiguousIDError) Error() string {
+	return fmt.Sprintf("id prefix %q matches %d or more strings", e.Prefix, len(e.Candidates))
+}
+
+func (e *AmbiguousIDError) Is(target error) bool {
+	return target == ErrAmbiguousID
+}
+
+// ResolveID finds the string whose ID is id or, git-style, the only string
+// whose ID starts with id
+func ResolveID(s StringStore, id string) (helpers.Response, error) {
+	if response, err := s.GetByID(id); err == nil || !errors.Is(err, ErrNotFound) {
+		return response, err
+	}
+
+	matches, err := s.MatchIDPrefix(id, maxIDCandidates)
+	if err != nil {
+		return helpers.Response{}, err
+	}
+	switch len(matches) {
+	case 0:
+		return helpers.Response{}, ErrNotFound
+	case 1:
+		return matches[0], nil
+	}
+	return helpers.Response{}, &AmbiguousIDError{Prefix: id, Candidates: matches}
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// idTrie is a radix tree from string IDs to slots. It answers "which IDs
+// start with this prefix" in time proportional to the prefix length plus
+// the number of matches returned, which is what short-ID lookups need.
+type idTrie struct {
+	root trieNode
+}
+
+type trieNode struct {
+	label    string
+	children []*trieNode // sorted by the first byte of their label
+	slot     uint32
+	terminal bool
+}
+
+func (n *trieNode) child(b byte) (int, bool) {
+	return slices.BinarySearchFunc(n.children, b, func(c *trieNode, b byte) int {
+		return int(c.label[0]) - int(b)
+	})
+}
+
+func commonPrefixLength(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+func (t *idTrie) insert(key string, slot uint32) {
+	n := &t.root
+	for {
+		if key == "" {
+			n.slot, n.terminal = slot, true
+			return
+		}
+		i, found := n.child(key[0])
+		if !found {
+			leaf := &trieNode{label: key, slot: slot, terminal: true}
+			n.children = slices.Insert(n.children, i, leaf)
+			return
+		}
+
+		c := n.children[i]
+		common := commonPrefixLength(c.label, key)
+		if common < len(c.label) {
+			// Split the edge at the point where the keys diverge
+			split := &trieNode{label: c.label[:common], children: []*trieNode{c}}
+			c.label = c.label[common:]
+			n.children[i] = split
+			c = split
+		}
+		n, key = c, key[common:]
+	}
+}
+
+func (t *idTrie) remove(key string) {
+	var path []*trieNode
+	n := &t.root
+	for key != "" {
+		i, found := n.child(key[0])
+		if !found || !strings.HasPrefix(key, n.children[i].label) {
+			return
+		}
+		path = append(path, n)
+		n, key = n.children[i], key[len(n.children[i].label):]
+	}
+	n.terminal = false
+
+	// Prune the emptied leaf and merge a parent left with a single child
+	for depth := len(path) - 1; depth >= 0 && !n.terminal; depth-- {
+		parent := path[depth]
+		switch len(n.children) {
+		case 0:
+			i, _ := parent.child(n.label[0])
+			parent.children = slices.Delete(parent.children, i, i+1)
+		case 1:
+			only := n.children[0]
+			n.label += only.label
+			n.children, n.slot, n.terminal = only.children, only.slot, only.terminal
+		}
+		n = parent
+	}
+}
+
+// withPrefix returns up to limit slots whose key starts with prefix, in key
+// order. A limit of zero means no limit.
+func (t *idTrie) withPrefix(prefix string, limit int) []uint32 {
+	n := &t.root
+	for prefix != "" {
+		i, found := n.child(prefix[0])
+		if !found {
+			return nil
+		}
+		c := n.children[i]
+		common := commonPrefixLength(c.label, prefix)
+		if common < len(prefix) && common < len(c.label) {
+			return nil
+		}
+		n, prefix = c, prefix[common:]
+	}
+
+	var slots []uint32
+	var walk func(n *trieNode) bool
+	walk = func(n *trieNode) bool {
+		if n.terminal {
+			slots = append(slots, n.slot)
+			if limit > 0 && len(slots) >= limit {
+				return false
+			}
+		}
+		for _, c := range n.children {
+			if !walk(c) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(n)
+	return slots
+}
@@ -0,0 +1,332 @@
+package tests
+
+import (
+	"encoding/json"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"unicode"
+)
+
+// capitalCount counts uppercase letters, named "capitals" in queries
+type capitalCount struct{}
+
+func (capitalCount) Name() string                     { return "capital_count" }
+func (capitalCount) Version() int                     { return 1 }
+func (capitalCount) FilterType() helpers.FilterType   { return helpers.FilterNumber }
+func (capitalCount) Nouns() (singular, plural string) { return "capital", "capitals" }
+func (capitalCount) Compute(h *helpers.StringApiHandler) any {
+	count := 0
+	for _, r := range h.String {
+		if unicode.IsUpper(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// pangram holds for strings using every letter of the English alphabet
+type pangram struct{}
+
+func (pangram) Name() string                     { return "is_pangram" }
+func (pangram) Version() int                     { return 1 }
+func (pangram) FilterType() helpers.FilterType   { return helpers.FilterFlag }
+func (pangram) Nouns() (singular, plural string) { return "pangram", "pangrams" }
+func (pangram) Compute(h *helpers.StringApiHandler) any {
+	lower := strings.ToLower(h.String)
+	for letter := 'a'; letter <= 'z'; letter++ {
+		if !strings.ContainsRune(lower, letter) {
+			return false
+		}
+	}
+	return true
+}
+
+// namedAnalyzer is an analyzer with a given name and filter type, for
+// registration errors
+type namedAnalyzer struct {
+	name       string
+	filterType helpers.FilterType
+}
+
+func (a namedAnalyzer) Name() string                          { return a.name }
+func (a namedAnalyzer) Version() int                          { return 1 }
+func (a namedAnalyzer) FilterType() helpers.FilterType        { return a.filterType }
+func (a namedAnalyzer) Compute(*helpers.StringApiHandler) any { return 0 }
+
+type nounedAnalyzer struct {
+	namedAnalyzer
+	singular, plural string
+}
+
+func (a nounedAnalyzer) Nouns() (string, string) { return a.singular, a.plural }
+
+var registerTestAnalyzers = sync.OnceValue(func() error {
+	if err := helpers.RegisterAnalyzer(capitalCount{}); err != nil {
+		return err
+	}
+	return helpers.RegisterAnalyzer(pangram{})
+})
+
+// useTestAnalyzers registers capital_count and is_pangram. They stay
+// registered; strings analyzed afterwards report them as extra properties.
+func useTestAnalyzers(t *testing.T) {
+	t.Helper()
+	if err := registerTestAnalyzers(); err != nil {
+		t.Fatalf("RegisterAnalyzer() unexpected error: %v", err)
+	}
+}
+
+const quickFox = "The Quick brown fox jumps over the lazy dog"
+
+func TestRegisterAnalyzerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		analyzer helpers.Analyzer
+	}{
+		{"not an identifier", namedAnalyzer{"Vowel Count", helpers.FilterNumber}},
+		{"keyword", namedAnalyzer{"between", helpers.FilterNumber}},
+		{"unknown filter type", namedAnalyzer{"vowel_count", "text"}},
+		{"built-in", namedAnalyzer{"length", helpers.FilterNumber}},
+		{"nouns without a filter", nounedAnalyzer{namedAnalyzer{"vowel_count", helpers.FilterNone}, "vowel", "vowels"}},
+		{"noun of two words", nounedAnalyzer{namedAnalyzer{"vowel_count", helpers.FilterNumber}, "vowel", "vowel sounds"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := helpers.RegisterAnalyzer(test.analyzer); err == nil {
+				t.Errorf("RegisterAnalyzer(%q) expected error, got nil", test.analyzer.Name())
+			}
+		})
+	}
+	if _, ok := helpers.LookupAnalyzer("vowel_count"); ok {
+		t.Errorf("A rejected analyzer was registered")
+	}
+}
+
+func TestBuiltinAnalyzers(t *testing.T) {
+	expected := map[helpers.FilterType][]string{
+		helpers.FilterNumber: {"length", "byte_length", "rune_count", "unique_characters", "word_count"},
+		helpers.FilterFlag:   {"is_palindrome"},
+	}
+	for filterType, names := range expected {
+		properties := helpers.FilterableProperties(filterType)
+		if len(properties) < len(names) || strings.Join(properties[:len(names)], ",") != strings.Join(names, ",") {
+			t.Errorf("FilterableProperties(%q) = %v, expected to start with %v", filterType, properties, names)
+		}
+	}
+	for _, a := range helpers.Analyzers()[:9] {
+		if !helpers.IsBuiltinAnalyzer(a) {
+			t.Errorf("Analyzer %q is not built in", a.Name())
+		}
+	}
+}
+
+func TestAnalyzeRegisteredAnalyzers(t *testing.T) {
+	useTestAnalyzers(t)
+
+	handler := helpers.StringApiHandler{String: quickFox}
+	properties := handler.Analyze()
+	if properties.Number("capital_count") != 2 || !properties.Flag("is_pangram") || properties.Number("length") != 43 {
+		t.Errorf("Analyze() = %+v, expected 2 capitals in a pangram of 43 characters", properties)
+	}
+
+	data, err := json.Marshal(properties)
+	if err != nil {
+		t.Fatalf("json.Marshal unexpected error: %v", err)
+	}
+	var flat map[string]any
+	json.Unmarshal(data, &flat)
+	if flat["capital_count"] != float64(2) || flat["is_pangram"] != true || flat["unique_characters"] != float64(28) {
+		t.Errorf("Properties marshal to %s, expected registered properties next to the built-ins", data)
+	}
+
+	var decoded helpers.PropertiesMap
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal unexpected error: %v", err)
+	}
+	if decoded.Number("capital_count") != 2 || !decoded.Flag("is_pangram") || decoded.WordCount != 9 || len(decoded.Extra) != 2 {
+		t.Errorf("Properties did not round-trip: %+v", decoded)
+	}
+}
+
+func TestRegisteredAnalyzerExpressions(t *testing.T) {
+	useTestAnalyzers(t)
+
+	handler := helpers.StringApiHandler{String: quickFox}
+	record := handler.GetString()
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"capital_count = 2", true},
+		{"capital_count BETWEEN 3 AND 5", false},
+		{"capital_count IS even", true},
+		{"is_pangram AND NOT is_palindrome", true},
+		{"is_pangram = false", false},
+	}
+
+	for _, test := range tests {
+		expression, err := helpers.ParseFilterExpression(test.input)
+		if err != nil {
+			t.Errorf("ParseFilterExpression(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if got := expression.Match(record); got != test.expected {
+			t.Errorf("%q matched %v, expected %v", test.input, got, test.expected)
+		}
+	}
+	if _, err := helpers.ParseFilterExpression("is_pangram > 1"); err == nil {
+		t.Errorf("ParseFilterExpression compared a flag")
+	}
+}
+
+func TestRegisteredAnalyzerNaturalLanguage(t *testing.T) {
+	useTestAnalyzers(t)
+
+	tests := []struct {
+		query     string
+		canonical string
+	}{
+		{"strings with more than 1 capital", "strings with more than 1 capital"},
+		{"pangrams with exactly 2 capitals", "strings that are pangrams and with exactly 2 capitals"},
+		{"strings that are not pangrams", "strings that are not pangrams"},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.InterpretNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if canonical, _ := result.Filter.CanonicalQuery(); canonical != test.canonical {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) canonical query = %q, expected %q", test.query, canonical, test.canonical)
+		}
+	}
+}
+
+func TestRegisteredAnalyzerEndpoints(t *testing.T) {
+	useTestAnalyzers(t)
+	ResetTestBank()
+	router := SetupTestRouter()
+	createValues(t, TestBank, quickFox, "Hello World", "racecar")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"flag parameter", "/strings?is_pangram=true", http.StatusOK, 1},
+		{"negated flag parameter", "/strings?is_pangram=false", http.StatusOK, 2},
+		{"number parameter", "/strings?capital_count=2", http.StatusOK, 2},
+		{"number range", "/strings?min_capital_count=1&max_capital_count=1", http.StatusOK, 0},
+		{"number class", "/strings?capital_count_is=even", http.StatusOK, 3},
+		{"in q", "/strings?q=" + url.QueryEscape("is_pangram OR capital_count = 0"), http.StatusOK, 2},
+		{"natural language", "/strings/filter-by-natural-language?query=" + url.QueryEscape("strings with 2 capitals that are not pangrams"), http.StatusOK, 1},
+		{"invalid flag", "/strings?is_pangram=yes", http.StatusBadRequest, 0},
+		{"invalid number", "/strings?min_capital_count=many", http.StatusBadRequest, 0},
+		{"conflicting flags", "/strings?is_pangram=true&q=" + url.QueryEscape("NOT is_pangram"), http.StatusUnprocessableEntity, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := get(test.target)
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			var response struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if test.expectedStatus == http.StatusOK && response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d: %s", test.expectedCount, response.Count, w.Body.String())
+			}
+		})
+	}
+
+	var docs struct {
+		Properties []struct {
+			Name    string `json:"name"`
+			Filter  string `json:"filter"`
+			Builtin bool   `json:"builtin"`
+		} `json:"properties"`
+	}
+	json.Unmarshal(get("/").Body.Bytes(), &docs)
+	listed := make(map[string]string)
+	for _, property := range docs.Properties {
+		listed[property.Name] = property.Filter
+	}
+	if listed["capital_count"] != "number" || listed["is_pangram"] != "flag" || listed["length"] != "number" {
+		t.Errorf("Docs list properties %v, expected the registered analyzers with their filter types", listed)
+	}
+}
+
+func TestSQLiteStoreRegisteredAnalyzers(t *testing.T) {
+	useTestAnalyzers(t)
+
+	s := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer s.Close()
+	createValues(t, s, quickFox)
+
+	response, err := s.GetByValue(quickFox)
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if response.Properties.Number("capital_count") != 2 || !response.Properties.Flag("is_pangram") {
+		t.Errorf("GetByValue returned properties %+v, expected the registered ones to be stored", response.Properties)
+	}
+
+	matches, err := store.Select(s, helpers.Filter{})
+	if err != nil || len(matches) != 1 || matches[0].Properties.Extra["is_pangram"] != true {
+		t.Errorf("Select returned %+v, %v", matches, err)
+	}
+}
+
+func TestBuiltinPropertiesByName(t *testing.T) {
+	handler := helpers.StringApiHandler{String: "Never odd or even"}
+	var properties helpers.PropertiesMap
+	for _, a := range helpers.Analyzers() {
+		if helpers.IsBuiltinAnalyzer(a) {
+			properties.Set(a.Name(), a.Compute(&handler))
+		}
+	}
+	// Registered analyzers from other tests go into Extra
+	expected := handler.Analyze()
+	expected.Extra = nil
+	if !reflect.DeepEqual(properties, expected) {
+		t.Errorf("Set by name gave %+v, expected %+v", properties, expected)
+	}
+
+	for _, a := range helpers.Analyzers() {
+		if !helpers.IsBuiltinAnalyzer(a) {
+			continue
+		}
+		value, ok := properties.Value(a.Name())
+		if expected := a.Compute(&handler); !ok || !reflect.DeepEqual(value, expected) {
+			t.Errorf("Value(%q) = %v, %v, expected %v", a.Name(), value, ok, expected)
+		}
+	}
+
+	// Lazy properties are missing until they are set
+	var empty helpers.PropertiesMap
+	for _, name := range []string{"character_frequency_map", "word_analysis", "longest_word_length"} {
+		if _, ok := empty.Value(name); ok {
+			t.Errorf("Value(%q) reported a property that was never computed", name)
+		}
+	}
+}
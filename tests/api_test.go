@@ -3,10 +3,19 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestHealthEndpoint(t *testing.T) {
@@ -46,7 +55,7 @@ func TestPostStringsEndpoint(t *testing.T) {
 			map[string]interface{}{
 				"value": "hello world",
 			},
-			http.StatusOK,
+			http.StatusCreated,
 			false,
 		},
 		{
@@ -197,6 +206,36 @@ func TestGetStringsEndpoint(t *testing.T) {
 			http.StatusBadRequest,
 			0,
 		},
+		{
+			"negative min_length",
+			"?min_length=-1",
+			http.StatusBadRequest,
+			0,
+		},
+		{
+			"multi-byte contains_character",
+			"?contains_character=%C3%A9",
+			http.StatusOK,
+			0,
+		},
+		{
+			"min_length greater than max_length",
+			"?min_length=10&max_length=5",
+			http.StatusUnprocessableEntity,
+			0,
+		},
+		{
+			"more words than max_length allows",
+			"?word_count=3&max_length=4",
+			http.StatusUnprocessableEntity,
+			0,
+		},
+		{
+			"non-palindrome of one character",
+			"?is_palindrome=false&max_length=1",
+			http.StatusUnprocessableEntity,
+			0,
+		},
 	}
 
 	for _, test := range tests {
@@ -362,6 +401,60 @@ func TestNaturalLanguageFilterEndpoint(t *testing.T) {
 			http.StatusBadRequest,
 			0,
 		},
+		{
+			"length beyond the largest count",
+			"strings longer than 9223372036854775807 characters",
+			http.StatusBadRequest,
+			0,
+		},
+		{
+			"unknown scale word",
+			"palindromes longer than a billion billion characters",
+			http.StatusBadRequest,
+			0,
+		},
+		{
+			"negative max_length",
+			"strings shorter than 0 characters",
+			http.StatusBadRequest,
+			0,
+		},
+		{
+			"word count range",
+			"strings with at least two words",
+			http.StatusOK,
+			2, // hello world, long string here
+		},
+		{
+			"negated character",
+			"palindromes without the letter c",
+			http.StatusOK,
+			2, // a, abba
+		},
+		{
+			"conflicting lengths",
+			"strings longer than 10 and shorter than 5 characters",
+			http.StatusUnprocessableEntity,
+			0,
+		},
+		{
+			"conflicting word counts",
+			"two word strings with one word",
+			http.StatusUnprocessableEntity,
+			0,
+		},
+		{
+			"alternatives",
+			"palindromes longer than 4 characters or strings with three words",
+			http.StatusOK,
+			2, // racecar, long string here
+		},
+		{
+			"one conflicting alternative",
+			"strings longer than 10 and shorter than 5 characters or strings containing the letter w",
+			http.StatusOK,
+			1, // hello world
+		},
 	}
 
 	for _, test := range tests {
@@ -390,6 +483,307 @@ func TestNaturalLanguageFilterEndpoint(t *testing.T) {
 	}
 }
 
+func TestNaturalLanguageInterpretation(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	type interpretation struct {
+		Original      string                 `json:"original"`
+		ParsedFilters map[string]interface{} `json:"parsed_filters"`
+		Spans         []helpers.QuerySpan    `json:"spans"`
+		IgnoredWords  []string               `json:"ignored_words"`
+		Confidence    float64                `json:"confidence"`
+		Suggestions   []string               `json:"suggestions"`
+	}
+	get := func(query string) (int, interpretation) {
+		req, _ := http.NewRequest("GET", "/strings/filter-by-natural-language?query="+url.QueryEscape(query), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response struct {
+			InterpretedQuery interpretation `json:"interpreted_query"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return w.Code, response.InterpretedQuery
+	}
+
+	code, result := get("fluffy palindromes with the letter a")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	expectedSpans := []helpers.QuerySpan{
+		{Start: 7, End: 18, Text: "palindromes", Meaning: "is_palindrome"},
+		{Start: 19, End: 36, Text: "with the letter a", Meaning: "contains('a')"},
+	}
+	if !reflect.DeepEqual(result.Spans, expectedSpans) {
+		t.Errorf("Expected spans %+v, got %+v", expectedSpans, result.Spans)
+	}
+	if !reflect.DeepEqual(result.IgnoredWords, []string{"fluffy"}) || result.Confidence != 0.83 {
+		t.Errorf("Expected fluffy ignored with confidence 0.83, got %q and %v", result.IgnoredWords, result.Confidence)
+	}
+	if len(result.ParsedFilters) != 2 {
+		t.Errorf("Expected two parsed filters, got %v", result.ParsedFilters)
+	}
+
+	code, result = get("palindrmes")
+	if code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", code)
+	}
+	if result.Original != "palindrmes" || result.Confidence != 0 || len(result.Spans) != 0 {
+		t.Errorf("Unexpected interpretation for a failed query: %+v", result)
+	}
+	if !reflect.DeepEqual(result.Suggestions, []string{"palindromes"}) {
+		t.Errorf("Expected suggestion palindromes, got %q", result.Suggestions)
+	}
+}
+
+func TestNaturalLanguageLanguageSelection(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	tests := []struct {
+		name           string
+		query          string
+		params         string
+		acceptLanguage string
+		expectedCode   int
+		language       string
+		source         string
+	}{
+		{"detected French", "chaînes sans la lettre z", "", "", http.StatusOK, "fr", "detected"},
+		{"detected Yoruba", "okùn tí ó ní ọ̀rọ̀ méjì", "", "", http.StatusOK, "yo", "detected"},
+		{"Accept-Language", "cadenas sin la letra z", "", "es-MX,es;q=0.9", http.StatusOK, "es", "header"},
+		{"unsupported Accept-Language falls back to detection", "cadenas sin la letra z", "", "de-DE", http.StatusOK, "es", "detected"},
+		{"lang beats Accept-Language", "chaînes sans la lettre z", "&lang=fr", "en-US", http.StatusOK, "fr", "param"},
+		{"lang=auto ignores Accept-Language", "chaînes sans la lettre z", "&lang=auto", "en-US", http.StatusOK, "fr", "detected"},
+		{"wrong language fails to parse", "chaînes sans la lettre z", "&lang=es", "", http.StatusBadRequest, "es", "param"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", "/strings/filter-by-natural-language?query="+url.QueryEscape(test.query)+test.params, nil)
+		if test.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != test.expectedCode {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.expectedCode, w.Code)
+			continue
+		}
+
+		var response struct {
+			InterpretedQuery struct {
+				Language       string `json:"language"`
+				LanguageSource string `json:"language_source"`
+				CanonicalQuery string `json:"canonical_query"`
+			} `json:"interpreted_query"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: failed to unmarshal response: %v", test.name, err)
+		}
+		if response.InterpretedQuery.Language != test.language || response.InterpretedQuery.LanguageSource != test.source {
+			t.Errorf("%s: expected %s from %s, got %+v", test.name, test.language, test.source, response.InterpretedQuery)
+		}
+		if test.expectedCode == http.StatusOK && response.InterpretedQuery.CanonicalQuery != "strings without the letter z" && test.language != "yo" {
+			t.Errorf("%s: expected the English canonical query, got %q", test.name, response.InterpretedQuery.CanonicalQuery)
+		}
+	}
+
+	req, _ := http.NewRequest("GET", "/strings/filter-by-natural-language?query=palindromes&lang=xx", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "supported_languages") {
+		t.Errorf("Expected 400 with the supported languages for lang=xx, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNaturalLanguageAnalyticsEndpoint(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	for _, query := range []string{
+		"palindromes",
+		"strings with 3 blorps",
+		"strings with twenty blorps",
+		"strings longer than 10 and shorter than 5 characters",
+		"gibberish",
+		"Strings with 7 BLORPS",
+	} {
+		req, _ := http.NewRequest("GET", "/strings/filter-by-natural-language?query="+url.QueryEscape(query), nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/admin/nl-queries?window=1h&bucket=10m&limit=5", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var summary store.QuerySummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if summary.Total != 6 || summary.Parsed != 1 || summary.Unparsed != 4 || summary.Conflicting != 1 {
+		t.Errorf("Expected 1 parsed, 4 unparsed and 1 conflicting query, got %+v", summary.QueryStats)
+	}
+	if len(summary.Buckets) != 6 || summary.Buckets[5].Total != 6 {
+		t.Errorf("Expected six buckets with every query in the last, got %+v", summary.Buckets)
+	}
+	if len(summary.TopUnparsed) != 2 || summary.TopUnparsed[0].Normalized != "strings with # blorps" || summary.TopUnparsed[0].Count != 3 || summary.TopUnparsed[0].Example != "Strings with 7 BLORPS" {
+		t.Errorf("Expected the blorps phrasing 3 times first, got %+v", summary.TopUnparsed)
+	}
+	if len(summary.TopConflicting) != 1 || summary.TopConflicting[0].Count != 1 {
+		t.Errorf("Expected one conflicting phrasing, got %+v", summary.TopConflicting)
+	}
+
+	for _, test := range []struct {
+		path  string
+		token string
+		code  int
+	}{
+		{"/admin/nl-queries", "", http.StatusUnauthorized},
+		{"/admin/nl-queries", "wrong", http.StatusUnauthorized},
+		{"/admin/nl-queries?window=forever", "secret", http.StatusBadRequest},
+		{"/admin/nl-queries?window=1h&bucket=2h", "secret", http.StatusBadRequest},
+		{"/admin/nl-queries?window=720h&bucket=1m", "secret", http.StatusBadRequest},
+		{"/admin/nl-queries?limit=0", "secret", http.StatusBadRequest},
+	} {
+		if w := get(test.path, test.token); w.Code != test.code {
+			t.Errorf("GET %s: expected status %d, got %d", test.path, test.code, w.Code)
+		}
+	}
+
+	// Without a token the admin endpoints are disabled
+	t.Setenv("ADMIN_TOKEN", "")
+	router = SetupTestRouter()
+	if w := get("/admin/nl-queries", "secret"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without ADMIN_TOKEN, got %d", w.Code)
+	}
+}
+
+func TestCanonicalFormsEndpoint(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	for _, value := range []string{"racecar", "hello world", "a", "abba", "long string here", "level up"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	type listing struct {
+		Count          int    `json:"count"`
+		CanonicalQuery string `json:"canonical_query"`
+		CanonicalURL   string `json:"canonical_url"`
+	}
+	get := func(target string, response interface{}) {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected status 200, got %d: %s", target, w.Code, w.Body.String())
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+	}
+
+	// GET /strings describes its filters in English
+	var structured listing
+	get("/strings?is_palindrome=true&word_count=1&min_length=5", &structured)
+	if structured.CanonicalQuery != "single-word palindromic strings longer than 4 characters" {
+		t.Errorf("Unexpected canonical_query %q", structured.CanonicalQuery)
+	}
+	if structured.CanonicalURL != "/strings?is_palindrome=true&min_length=5&word_count=1" {
+		t.Errorf("Unexpected canonical_url %q", structured.CanonicalURL)
+	}
+
+	// Both canonical forms of a natural-language query select the same strings
+	for _, query := range []string{
+		"palindromes longer than 4 characters or strings with three words",
+		"strings with at least two words that don't contain the letter z",
+		"non-palindromic strings containing the letter l",
+	} {
+		var response struct {
+			Count            int `json:"count"`
+			InterpretedQuery struct {
+				CanonicalQuery string `json:"canonical_query"`
+				CanonicalURL   string `json:"canonical_url"`
+			} `json:"interpreted_query"`
+		}
+		get("/strings/filter-by-natural-language?query="+url.QueryEscape(query), &response)
+		if response.Count == 0 {
+			t.Fatalf("%q matched nothing", query)
+		}
+
+		var viaURL listing
+		get(response.InterpretedQuery.CanonicalURL, &viaURL)
+		if viaURL.Count != response.Count {
+			t.Errorf("%q: canonical_url %s matched %d strings, expected %d", query, response.InterpretedQuery.CanonicalURL, viaURL.Count, response.Count)
+		}
+
+		var viaEnglish listing
+		get("/strings/filter-by-natural-language?query="+url.QueryEscape(response.InterpretedQuery.CanonicalQuery), &viaEnglish)
+		if viaEnglish.Count != response.Count {
+			t.Errorf("%q: canonical_query %q matched %d strings, expected %d", query, response.InterpretedQuery.CanonicalQuery, viaEnglish.Count, response.Count)
+		}
+	}
+}
+
+func TestLexiconEndpoint(t *testing.T) {
+	router := SetupTestRouter()
+
+	req, _ := http.NewRequest("GET", "/lexicon", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response helpers.LoadedLexicon
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if response.Source != "built-in" || !reflect.DeepEqual(response.Lexicon, helpers.DefaultLexicon()) {
+		t.Errorf("Expected the built-in lexicon, got %s: %+v", response.Source, response.Lexicon)
+	}
+
+	// Other languages' lexicons include the English one they are layered over
+	req, _ = http.NewRequest("GET", "/lexicon?lang=es", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var spanish helpers.LoadedLexicon
+	json.Unmarshal(w.Body.Bytes(), &spanish)
+	if w.Code != http.StatusOK || spanish.Language != "es" || !slices.Contains(spanish.Lexicon.ExcludesVerbs, "sin") || !slices.Contains(spanish.Lexicon.ExcludesVerbs, "without") {
+		t.Errorf("Expected the Spanish lexicon, got %d: %+v", w.Code, spanish)
+	}
+	req, _ = http.NewRequest("GET", "/lexicon?lang=xx", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown language, got %d", w.Code)
+	}
+
+	// The lexicon is read-only over HTTP
+	req, _ = http.NewRequest("PUT", "/lexicon", strings.NewReader("{}"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		t.Errorf("Expected PUT /lexicon to be rejected, got %d", w.Code)
+	}
+}
+
 func TestAPIDocumentationEndpoint(t *testing.T) {
 	router := SetupTestRouter()
 
@@ -415,3 +809,575 @@ func TestAPIDocumentationEndpoint(t *testing.T) {
 		t.Errorf("Expected endpoints field in response")
 	}
 }
+
+func TestConcurrentPostStrings(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	const workers = 32
+	statuses := make(chan int, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jsonBody, _ := json.Marshal(map[string]string{"value": "same value"})
+			req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			statuses <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Unexpected status %d", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected exactly one successful POST, got %d", created)
+	}
+}
+
+func TestStringByIDEndpoints(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	first, second := idPrefixCollision(4)
+	for _, value := range []string{first, second, "id"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	firstID := helpers.CalculateSHA256(first)
+	secondID := helpers.CalculateSHA256(second)
+	unique := firstID[:commonIDPrefix(firstID, secondID)+1]
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"full id", "GET", "/strings/id/" + firstID, http.StatusOK},
+		{"uppercase id", "GET", "/strings/id/" + strings.ToUpper(firstID), http.StatusOK},
+		{"unique prefix", "GET", "/strings/id/" + unique, http.StatusOK},
+		{"ambiguous prefix", "GET", "/strings/id/" + firstID[:4], http.StatusConflict},
+		{"prefix too short", "GET", "/strings/id/" + firstID[:3], http.StatusBadRequest},
+		{"not hexadecimal", "GET", "/strings/id/xyz123", http.StatusBadRequest},
+		{"unknown id", "GET", "/strings/id/" + strings.Repeat("0", 64), http.StatusNotFound},
+		{"value named id", "GET", "/strings/id", http.StatusOK},
+		{"delete ambiguous prefix", "DELETE", "/strings/id/" + firstID[:4], http.StatusConflict},
+		{"delete unique prefix", "DELETE", "/strings/id/" + unique, http.StatusNoContent},
+		{"deleted id", "GET", "/strings/id/" + firstID, http.StatusNotFound},
+		{"former ambiguous prefix", "GET", "/strings/id/" + firstID[:4], http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, test.path, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusConflict {
+				var response struct {
+					Candidates []map[string]string `json:"candidates"`
+				}
+				json.Unmarshal(w.Body.Bytes(), &response)
+				if len(response.Candidates) != 2 {
+					t.Errorf("Expected 2 candidates, got %v", response.Candidates)
+				}
+			}
+		})
+	}
+}
+
+func TestGetStringsPagination(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	for _, value := range []string{"racecar", "hello world", "a", "abba", "long string here"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	type page struct {
+		Data           []helpers.Response `json:"data"`
+		Count          int                `json:"count"`
+		FiltersApplied map[string]string  `json:"filters_applied"`
+		NextCursor     string             `json:"next_cursor"`
+	}
+	get := func(query string) (int, page) {
+		req, _ := http.NewRequest("GET", "/strings"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response page
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	var values []string
+	query := "?sort=-length,value&limit=2&max_length=11"
+	for {
+		status, response := get(query)
+		if status != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, status)
+		}
+		if response.Count != len(response.Data) || response.FiltersApplied["max_length"] != "11" {
+			t.Errorf("Unexpected page metadata: %+v", response)
+		}
+		for _, item := range response.Data {
+			values = append(values, item.Value)
+		}
+		if response.NextCursor == "" {
+			break
+		}
+		query = "?sort=-length,value&limit=2&max_length=11&cursor=" + response.NextCursor
+	}
+	if fmt.Sprint(values) != "[hello world racecar abba a]" {
+		t.Errorf("Paginated values %v, expected [hello world racecar abba a]", values)
+	}
+
+	for _, query := range []string{"?sort=colour", "?limit=0", "?limit=abc", "?limit=5000", "?cursor=garbage", "?sort=value&locale=!!"} {
+		if status, _ := get(query); status != http.StatusBadRequest {
+			t.Errorf("GET /strings%s returned %d, expected %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetStringsFilterExpression(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	for _, value := range []string{"racecar", "hello world", "a", "abba", "long string here", "zoo"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedCount  int
+		normalized     string
+	}{
+		{
+			"expression",
+			"(is_palindrome or word_count >= 3) and not contains('z') and length between 4 and 20",
+			http.StatusOK,
+			3,
+			"(is_palindrome OR word_count >= 3) AND NOT contains('z') AND length BETWEEN 4 AND 20",
+		},
+		{"combined with structured params", "is_palindrome OR contains('z')&min_length=3", http.StatusOK, 3, "is_palindrome OR contains('z')"},
+		{"syntax error", "length >=", http.StatusBadRequest, 0, ""},
+		{"above the largest count", fmt.Sprintf("length > %d", math.MaxInt), http.StatusUnprocessableEntity, 0, ""},
+		{"above the largest count with a range", fmt.Sprintf("length > %d AND length >= 1", math.MaxInt), http.StatusUnprocessableEntity, 0, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := url.Values{}
+			query, extra, _ := strings.Cut(test.query, "&")
+			params.Set("q", query)
+			req, _ := http.NewRequest("GET", "/strings?"+params.Encode()+"&"+extra, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", test.expectedStatus, w.Code)
+			}
+			var response struct {
+				Count          int               `json:"count"`
+				FiltersApplied map[string]string `json:"filters_applied"`
+				Position       int               `json:"position"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if test.expectedStatus != http.StatusOK {
+				if test.expectedStatus == http.StatusBadRequest && response.Position != 10 {
+					t.Errorf("Expected error position 10, got %d", response.Position)
+				}
+				return
+			}
+			if response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d", test.expectedCount, response.Count)
+			}
+			if response.FiltersApplied["q"] != test.normalized {
+				t.Errorf("Expected normalized q %q, got %q", test.normalized, response.FiltersApplied["q"])
+			}
+		})
+	}
+}
+
+func TestGetStringsNumberClasses(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	// Lengths 7, 11, 1, 4, 16 and 3; "hello world" has 2 words
+	for _, value := range []string{"racecar", "hello world", "a", "abba", "long string here", "zoo"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name           string
+		params         string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"prime length", "length_is=prime", http.StatusOK, 3},
+		{"even word count", "word_count_is=even", http.StatusOK, 1},
+		{"with q", "length_is=odd&q=is_palindrome", http.StatusOK, 2},
+		{"several classes", "length_is=prime,odd&word_count_is=odd", http.StatusOK, 2},
+		// Every one-digit number is an Armstrong number; 11 is not
+		{"unique characters", "unique_characters_is=armstrong", http.StatusOK, 5},
+		{"even and odd", "length_is=even,odd", http.StatusUnprocessableEntity, 0},
+		{"length 4 is not prime", "min_length=4&max_length=4&length_is=prime", http.StatusUnprocessableEntity, 0},
+		{"unknown class", "length_is=square", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/strings?"+test.params, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+			var response struct {
+				Count          int               `json:"count"`
+				FiltersApplied map[string]string `json:"filters_applied"`
+				CanonicalURL   string            `json:"canonical_url"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d", test.expectedCount, response.Count)
+			}
+			if response.FiltersApplied["length_is"] == "" && response.FiltersApplied["word_count_is"] == "" && response.FiltersApplied["unique_characters_is"] == "" {
+				t.Errorf("Expected the number classes in filters_applied, got %v", response.FiltersApplied)
+			}
+			if !strings.Contains(response.CanonicalURL, "+IS+") {
+				t.Errorf("Expected canonical_url to carry the classes in q, got %q", response.CanonicalURL)
+			}
+		})
+	}
+}
+
+func TestGetStringsLengthUnit(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	// 3 graphemes, 5 runes and 7 bytes; 1 grapheme, 5 runes and 18 bytes;
+	// 5 of each
+	for _, value := range []string{"e\u0301te\u0301", "👨‍👩‍👧", "hello"} {
+		jsonBody, _ := json.Marshal(map[string]string{"value": value})
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name           string
+		params         string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"graphemes by default", "min_length=3", http.StatusOK, 2},
+		{"graphemes", "max_length=3&length_unit=graphemes", http.StatusOK, 2},
+		{"runes", "min_length=5&max_length=5&length_unit=runes", http.StatusOK, 3},
+		{"bytes", "min_length=6&length_unit=bytes", http.StatusOK, 2},
+		{"byte_length in q", "q=byte_length+>+5", http.StatusOK, 2},
+		{"rune_count in q", "q=rune_count+=+5+AND+length+<+5", http.StatusOK, 2},
+		{"more graphemes than bytes", "min_length=10&length_unit=graphemes&q=byte_length+<=+5", http.StatusUnprocessableEntity, 0},
+		{"unknown unit", "min_length=3&length_unit=words", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/strings?"+test.params, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			var response struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if test.expectedStatus == http.StatusOK && response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d", test.expectedCount, response.Count)
+			}
+		})
+	}
+}
+
+func TestPalindromeModeEndpoints(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	post := func(body map[string]string) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/strings", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(map[string]string{"value": "A man, a plan, a canal: Panama!", "palindrome_mode": "punctuation,case,whitespace"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var created helpers.Response
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !created.Properties.IsPalindrome || created.Properties.PalindromeMode != "case,whitespace,punctuation" {
+		t.Errorf("Expected a palindrome in mode case,whitespace,punctuation, got %v in %q",
+			created.Properties.IsPalindrome, created.Properties.PalindromeMode)
+	}
+
+	if w := post(map[string]string{"value": "abc", "palindrome_mode": "vowels"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown mode, got %d", http.StatusBadRequest, w.Code)
+	}
+	post(map[string]string{"value": "Was it a car, or a cat I saw?"})
+	post(map[string]string{"value": "Step on no pets"})
+
+	tests := []struct {
+		name           string
+		params         string
+		expectedStatus int
+		expectedCount  int
+	}{
+		{"stored flags", "is_palindrome=true", http.StatusOK, 2},
+		{"recheck with punctuation ignored", "is_palindrome=true&palindrome_mode=case,whitespace,punctuation", http.StatusOK, 3},
+		{"recheck without normalization", "is_palindrome=false&palindrome_mode=none", http.StatusOK, 3},
+		{"mode in q", "q=is_palindrome('none')+OR+word_count+=+4", http.StatusOK, 1},
+		{"both true and false", "q=is_palindrome('case')+AND+NOT+is_palindrome('case')", http.StatusUnprocessableEntity, 0},
+		{"mode without is_palindrome", "palindrome_mode=case", http.StatusBadRequest, 0},
+		{"unknown mode", "is_palindrome=true&palindrome_mode=vowels", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/strings?"+test.params, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			var response struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if test.expectedStatus == http.StatusOK && response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d", test.expectedCount, response.Count)
+			}
+		})
+	}
+}
+
+func TestClassifyNumberEndpoint(t *testing.T) {
+	t.Setenv("FUN_FACT_PROVIDER", "local")
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	tests := []struct {
+		number         string
+		expectedStatus int
+		expected       map[string]interface{}
+	}{
+		{"371", http.StatusOK, map[string]interface{}{
+			"number": 371.0, "is_prime": false, "is_perfect": false,
+			"properties": []interface{}{"armstrong", "odd"}, "digit_sum": 11.0,
+			"fun_fact": "371 is an Armstrong number because 3^3 + 7^3 + 1^3 = 371",
+		}},
+		{"-6", http.StatusOK, map[string]interface{}{
+			"number": -6.0, "is_prime": false, "is_perfect": false,
+			"properties": []interface{}{"even"}, "digit_sum": -6.0,
+			"fun_fact": "-6 is an even number whose digits add up to -6",
+		}},
+		{"", http.StatusBadRequest, nil},
+		{"abc", http.StatusBadRequest, nil},
+		{"3.5", http.StatusBadRequest, nil},
+		{"3.0", http.StatusBadRequest, nil},
+		{"-12345678901234567890", http.StatusOK, map[string]interface{}{
+			"number": -12345678901234567890.0, "is_prime": false, "is_perfect": false,
+			"properties": []interface{}{"even"}, "digit_sum": -90.0,
+			"fun_fact": "-12345678901234567890 is an even number whose digits add up to -90",
+		}},
+		{"1" + strings.Repeat("0", 1000), http.StatusBadRequest, nil},
+		// Rejected by length, before any parsing
+		{strings.Repeat("7", 200000), http.StatusBadRequest, nil},
+		{strings.Repeat("7", 200000) + "x", http.StatusBadRequest, nil},
+	}
+
+	for _, test := range tests {
+		name := test.number
+		if len(name) > 30 {
+			name = fmt.Sprintf("%d digits", len(name))
+		}
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/api/classify-number?number="+url.QueryEscape(test.number), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, w.Code, w.Body.String())
+			}
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if test.expectedStatus != http.StatusOK {
+				if response["error"] == nil || response["number"] != test.number {
+					t.Errorf("Expected an error echoing the number, got %v", response)
+				}
+				return
+			}
+			if !reflect.DeepEqual(response, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, response)
+			}
+		})
+	}
+
+	// The number is kept exact
+	req, _ := http.NewRequest("GET", "/api/classify-number?number=170141183460469231731687303715884105727", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"number":170141183460469231731687303715884105727,"is_prime":true`) {
+		t.Errorf("Expected the Mersenne prime 2^127 - 1 to be exact and prime, got %s", w.Body.String())
+	}
+
+	// A number that takes longer than the budget is refused
+	t.Setenv("NUMBER_TIME_BUDGET", "1ns")
+	router = SetupTestRouter()
+	req, _ = http.NewRequest("GET", "/api/classify-number?number=170141183460469231731687303715884105727", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 past the time budget, got %d: %s", w.Code, w.Body.String())
+	}
+	t.Setenv("NUMBER_TIME_BUDGET", "")
+
+	// Facts come from the Numbers API, or are made up when it fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/8/math" {
+			w.Write([]byte("8 is the first nontrivial cube."))
+			return
+		}
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	t.Setenv("FUN_FACT_PROVIDER", "numbersapi")
+	t.Setenv("NUMBERS_API_URL", server.URL)
+	router = SetupTestRouter()
+	for number, fact := range map[string]string{"8": "8 is the first nontrivial cube.", "9": "9 is an odd number whose digits add up to 9"} {
+		req, _ := http.NewRequest("GET", "/api/classify-number?number="+number, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		if w.Code != http.StatusOK || response["fun_fact"] != fact {
+			t.Errorf("Classifying %s: status %d, fun_fact %v, expected %q", number, w.Code, response["fun_fact"], fact)
+		}
+	}
+
+	// A slow Numbers API only gets what is left of the time budget
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(3 * time.Second):
+			w.Write([]byte("too late"))
+		}
+	}))
+	defer slow.Close()
+	t.Setenv("NUMBERS_API_URL", slow.URL)
+	t.Setenv("NUMBER_TIME_BUDGET", "200ms")
+	router = SetupTestRouter()
+	started := time.Now()
+	req, _ = http.NewRequest("GET", "/api/classify-number?number=9", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if w.Code != http.StatusOK || response["fun_fact"] != "9 is an odd number whose digits add up to 9" {
+		t.Errorf("Classifying 9 with a slow API: status %d, fun_fact %v, expected a local fact", w.Code, response["fun_fact"])
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Classifying 9 with a slow API took %v, past the 200ms budget", elapsed)
+	}
+}
+
+func TestNumberFactorsEndpoint(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	req, _ := http.NewRequest("GET", "/api/numbers/360/factors", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	expected := map[string]interface{}{
+		"number": 360.0,
+		"factors": []interface{}{
+			map[string]interface{}{"prime": 2.0, "exponent": 3.0},
+			map[string]interface{}{"prime": 3.0, "exponent": 2.0},
+			map[string]interface{}{"prime": 5.0, "exponent": 1.0},
+		},
+		"factorization": "2^3 × 3^2 × 5",
+		"divisor_count": 24.0,
+		"divisor_sum":   1170.0,
+		"abundance":     "abundant",
+		"totient":       96.0,
+		"is_squarefree": false,
+	}
+	if !reflect.DeepEqual(response, expected) {
+		t.Errorf("Expected %v, got %v", expected, response)
+	}
+
+	for _, n := range []string{"0", "-12", "abc", "1.5"} {
+		req, _ := http.NewRequest("GET", "/api/numbers/"+n+"/factors", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Factorizing %q: expected status 400, got %d", n, w.Code)
+		}
+	}
+
+	// A product of two 30-digit primes cannot be split within the budget
+	t.Setenv("NUMBER_TIME_BUDGET", "100ms")
+	router = SetupTestRouter()
+	req, _ = http.NewRequest("GET", "/api/numbers/100433627766186892221372630609062766858404681029709092356097/factors", nil)
+	w = httptest.NewRecorder()
+	start := time.Now()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 past the time budget, got %d: %s", w.Code, w.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Factorization ran for %s despite a 100ms budget", elapsed)
+	}
+}
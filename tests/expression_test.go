@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"errors"
+	helpers "hng/step0/helpers"
+	"testing"
+)
+
+func TestParseFilterExpressionNormalizes(t *testing.T) {
+	tests := []struct {
+		input      string
+		normalized string
+	}{
+		{"is_palindrome", "is_palindrome"},
+		{"IS_PALINDROME = false", "NOT is_palindrome"},
+		{"not not is_palindrome", "is_palindrome"},
+		{"length>=3", "length >= 3"},
+		{"word_count == 2", "word_count = 2"},
+		{"length between 5 and 20", "length BETWEEN 5 AND 20"},
+		{`contains("z")`, "contains('z')"},
+		{"length is Prime", "length IS prime"},
+		{"word_count IS NOT even", "NOT word_count IS even"},
+		{`is_palindrome("Punctuation, case")`, "is_palindrome('case,punctuation')"},
+		{"is_palindrome('case:TR') = false", "NOT is_palindrome('case:tr')"},
+		{`contains('it\'s')`, `contains('it\'s')`},
+		{"a_b", ""}, // unknown property, checked below
+		{
+			"(is_palindrome or word_count >= 3) and not contains('z') and length between 5 and 20",
+			"(is_palindrome OR word_count >= 3) AND NOT contains('z') AND length BETWEEN 5 AND 20",
+		},
+		{"is_palindrome AND (length > 3 AND length < 10)", "is_palindrome AND length > 3 AND length < 10"},
+		{"(is_palindrome OR length = 1) OR word_count = 2", "is_palindrome OR length = 1 OR word_count = 2"},
+		{"NOT (is_palindrome AND length = 1)", "NOT (is_palindrome AND length = 1)"},
+		{"is_palindrome OR length = 1 AND word_count = 2", "is_palindrome OR length = 1 AND word_count = 2"},
+	}
+
+	for _, test := range tests {
+		expr, err := helpers.ParseFilterExpression(test.input)
+		if test.normalized == "" {
+			if err == nil {
+				t.Errorf("ParseFilterExpression(%q) expected error, got nil", test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFilterExpression(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if expr.String() != test.normalized {
+			t.Errorf("ParseFilterExpression(%q) = %q, expected %q", test.input, expr.String(), test.normalized)
+		}
+		// The normalized form must parse back to itself
+		if again, err := helpers.ParseFilterExpression(expr.String()); err != nil || again.String() != test.normalized {
+			t.Errorf("Normalized %q did not round-trip: %v, %v", test.normalized, again, err)
+		}
+	}
+}
+
+func TestParseFilterExpressionSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+	}{
+		{"", 1},
+		{"length >", 9},
+		{"length >= x", 11},
+		{"(is_palindrome", 15},
+		{"is_palindrome)", 14},
+		{"colour = 3", 1},
+		{"length BETWEEN 3 4", 18},
+		{"contains(z)", 10},
+		{"contains('')", 10},
+		{"contains('abc", 10},
+		{"is_palindrome AND", 18},
+		{"length @ 3", 8},
+		{"is_palindrome = maybe", 17},
+		{"word_count ! 3", 12},
+		{"length IS square", 11},
+		{"is_palindrome IS odd", 15},
+		{"is_palindrome(case)", 15},
+		{"is_palindrome('vowels')", 15},
+		{"is_palindrome('digits'", 23},
+	}
+
+	for _, test := range tests {
+		_, err := helpers.ParseFilterExpression(test.input)
+		var syntaxErr *helpers.SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("ParseFilterExpression(%q) returned %v, expected a SyntaxError", test.input, err)
+			continue
+		}
+		if syntaxErr.Position != test.position {
+			t.Errorf("ParseFilterExpression(%q) error at position %d, expected %d (%v)", test.input, syntaxErr.Position, test.position, err)
+		}
+	}
+}
+
+func TestFilterExpressionMatch(t *testing.T) {
+	data := responsesFor("racecar", "hello world", "a", "abba", "long string here", "zoo", "never odd or even")
+
+	tests := []struct {
+		expression string
+		expected   int
+	}{
+		{"is_palindrome", 4},
+		{"NOT is_palindrome", 3},
+		{"word_count >= 3", 2},
+		{"is_palindrome OR word_count >= 3", 5},
+		{"(is_palindrome OR word_count >= 3) AND NOT contains('z')", 5},
+		{"length BETWEEN 4 AND 7", 2},
+		{"(is_palindrome OR word_count >= 3) AND NOT contains('z') AND length BETWEEN 5 AND 20", 3},
+		{"unique_characters < 3", 3},
+		{"contains('o')", 4},
+		{"length != 1 AND is_palindrome", 3},
+		{"length IS prime", 4},
+		{"word_count IS odd", 5},
+		{"length IS even AND is_palindrome", 1},
+		{"NOT unique_characters IS perfect", 7},
+	}
+
+	for _, test := range tests {
+		expr, err := helpers.ParseFilterExpression(test.expression)
+		if err != nil {
+			t.Fatalf("ParseFilterExpression(%q) unexpected error: %v", test.expression, err)
+		}
+		matches := 0
+		for _, item := range data {
+			if expr.Match(item) {
+				matches++
+			}
+		}
+		if matches != test.expected {
+			t.Errorf("%q matched %d strings, expected %d", test.expression, matches, test.expected)
+		}
+	}
+}
+
+func TestFilterWithExpression(t *testing.T) {
+	expr, _ := helpers.ParseFilterExpression(
+		"NOT is_palindrome AND length > 3 AND length BETWEEN 2 AND 10 AND length < 8 AND word_count = 2 AND contains('q') AND (contains('z') OR length = 1)")
+	c := helpers.Filter{}.WithExpression(expr)
+
+	if c.IsPalindrome == nil || *c.IsPalindrome {
+		t.Errorf("Expected IsPalindrome=false, got %v", c.IsPalindrome)
+	}
+	if c.MinLength == nil || *c.MinLength != 4 || c.MaxLength == nil || *c.MaxLength != 7 {
+		t.Errorf("Expected length range [4, 7], got %v..%v", c.MinLength, c.MaxLength)
+	}
+	if c.WordCount == nil || *c.WordCount != 2 || c.ContainsCharacter != "q" {
+		t.Errorf("Expected word_count 2 and character q, got %+v", c)
+	}
+
+	// Disjunctions cannot be pushed down
+	or, _ := helpers.ParseFilterExpression("is_palindrome OR length > 3")
+	if !(helpers.Filter{}.WithExpression(or)).IsEmpty() {
+		t.Errorf("Expected an empty filter for a top-level OR")
+	}
+
+	// A bound one past the largest int is left to the expression
+	beyond, _ := helpers.ParseFilterExpression("length > 9223372036854775807")
+	if c := (helpers.Filter{}).WithExpression(beyond); c.MinLength != nil {
+		t.Errorf("Expected no min_length for length > MaxInt, got %d", *c.MinLength)
+	}
+
+	// Existing constraints win over the expression
+	yes := true
+	c = helpers.Filter{IsPalindrome: &yes}.WithExpression(expr)
+	if !*c.IsPalindrome {
+		t.Errorf("Expression overrode an existing is_palindrome constraint")
+	}
+}
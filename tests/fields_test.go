@@ -0,0 +1,344 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"length,is_palindrome", []string{"length", "is_palindrome"}},
+		{" SHA256_HASH , length,sha256_hash", []string{"sha256_hash", "length"}},
+		{"length,vowels", nil},
+		{",", nil},
+	}
+
+	for _, test := range tests {
+		fields, err := helpers.ParseFields(test.input)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("ParseFields(%q) = %v, expected error", test.input, fields)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("ParseFields(%q) = %v, %v, expected %v", test.input, fields, err, test.expected)
+		}
+	}
+}
+
+func TestLazyProperties(t *testing.T) {
+	handler := helpers.StringApiHandler{String: "hello world"}
+	response := handler.GetStringFields([]string{"is_palindrome"})
+	if response.Properties.CharacterFrequencyMap != nil {
+		t.Errorf("GetStringFields computed the character frequency map without being asked")
+	}
+	if response.Properties.Length != 11 || response.Properties.WordCount != 2 || response.ID == "" {
+		t.Errorf("GetStringFields skipped properties stores need: %+v", response.Properties)
+	}
+
+	if response.Complete([]string{"length"}) {
+		t.Errorf("Complete computed properties that were not asked for")
+	}
+	if !response.Complete(nil) || response.Properties.CharacterFrequencyMap["l"] != 3 {
+		t.Errorf("Complete(nil) did not compute the character frequency map: %+v", response.Properties)
+	}
+	if response.Complete(nil) {
+		t.Errorf("Complete recomputed a property it already had")
+	}
+}
+
+func TestFieldsEndpoints(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+
+	request := func(method, target string, body any) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, target, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	propertyKeys := func(t *testing.T, w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var response struct {
+			ID         string         `json:"id"`
+			Properties map[string]any `json:"properties"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ID == "" {
+			t.Fatalf("Unexpected response %s: %v", w.Body.String(), err)
+		}
+		keys := make([]string, 0, len(response.Properties))
+		for key := range response.Properties {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		return keys
+	}
+
+	w := request("POST", "/strings?fields=length,is_palindrome", map[string]string{"value": "level up"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if keys := propertyKeys(t, w); !reflect.DeepEqual(keys, []string{"is_palindrome", "length"}) {
+		t.Errorf("POST with fields returned properties %v", keys)
+	}
+	stored, _ := TestBank.GetByValue("level up")
+	if stored.Properties.CharacterFrequencyMap != nil {
+		t.Errorf("POST with fields stored a character frequency map it was not asked for")
+	}
+
+	t.Run("read selected fields", func(t *testing.T) {
+		w := request("GET", "/strings/level%20up?fields=word_count,sha256_hash", nil)
+		if keys := propertyKeys(t, w); !reflect.DeepEqual(keys, []string{"sha256_hash", "word_Count"}) {
+			t.Errorf("GET with fields returned properties %v", keys)
+		}
+		if stored, _ := TestBank.GetByValue("level up"); stored.Properties.CharacterFrequencyMap != nil {
+			t.Errorf("GET without character_frequency_map computed it")
+		}
+	})
+
+	t.Run("lazy property is memoized on first read", func(t *testing.T) {
+		w := request("GET", "/strings/id/"+helpers.CalculateSHA256("level up")[:8]+"?fields=character_frequency_map", nil)
+		if keys := propertyKeys(t, w); !reflect.DeepEqual(keys, []string{"character_frequency_map"}) {
+			t.Errorf("GET by ID with fields returned properties %v", keys)
+		}
+		if stored, _ := TestBank.GetByValue("level up"); stored.Properties.CharacterFrequencyMap["l"] != 2 {
+			t.Errorf("The character frequency map was not kept on the stored string: %+v", stored.Properties)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		request("POST", "/strings?fields=sha256_hash", map[string]string{"value": "abc"})
+		for _, target := range []string{"/strings?fields=length", "/strings/filter-by-natural-language?query=strings+longer+than+2+characters&fields=length"} {
+			var response struct {
+				Data []struct {
+					Properties map[string]any `json:"properties"`
+				} `json:"data"`
+			}
+			json.Unmarshal(request("GET", target, nil).Body.Bytes(), &response)
+			if len(response.Data) != 2 || len(response.Data[0].Properties) != 1 || response.Data[1].Properties["length"] != float64(3) {
+				t.Errorf("GET %s returned %+v, expected only the length of 2 strings", target, response.Data)
+			}
+		}
+
+		var full struct {
+			Data []helpers.Response `json:"data"`
+		}
+		json.Unmarshal(request("GET", "/strings", nil).Body.Bytes(), &full)
+		if len(full.Data) != 2 || full.Data[1].Properties.CharacterFrequencyMap["a"] != 1 {
+			t.Errorf("GET /strings without fields returned %+v, expected every property", full.Data)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{
+			request("POST", "/strings?fields=length,vowels", map[string]string{"value": "xyz"}),
+			request("GET", "/strings?fields=vowels", nil),
+			request("GET", "/strings/abc?fields=vowels", nil),
+			request("GET", "/strings/filter-by-natural-language?query=palindromes&fields=,", nil),
+		} {
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		}
+		if _, err := TestBank.GetByValue("xyz"); err == nil {
+			t.Errorf("POST with an unknown field stored the string")
+		}
+	})
+}
+
+func TestStoresMemoizeLazyProperties(t *testing.T) {
+	dir := t.TempDir()
+	file := openTestFileStore(t, dir)
+	sqlite := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer sqlite.Close()
+
+	for name, s := range map[string]store.StringStore{"file": file, "sqlite": sqlite} {
+		handler := helpers.StringApiHandler{String: "hello"}
+		if err := s.Create(handler.GetStringFields([]string{"length"})); err != nil {
+			t.Fatalf("%s: Create unexpected error: %v", name, err)
+		}
+		items, _ := s.List()
+		if items[0].Properties.CharacterFrequencyMap != nil {
+			t.Errorf("%s: stored a character frequency map that was not asked for", name)
+		}
+		store.Complete(s, items, []string{"character_frequency_map"})
+		if stored, _ := s.GetByValue("hello"); stored.Properties.CharacterFrequencyMap["l"] != 2 {
+			t.Errorf("%s: the character frequency map was not memoized: %+v", name, stored.Properties)
+		}
+	}
+
+	// The memoized map is in the log and survives a restart
+	file.Close()
+	reopened := openTestFileStore(t, dir)
+	defer reopened.Close()
+	if stored, _ := reopened.GetByValue("hello"); stored.Properties.CharacterFrequencyMap["l"] != 2 {
+		t.Errorf("The memoized character frequency map was lost on restart: %+v", stored.Properties)
+	}
+}
+
+func TestMemoizeMergesLazyProperties(t *testing.T) {
+	dir := t.TempDir()
+	file := openTestFileStore(t, dir)
+	sqlite := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer sqlite.Close()
+
+	for name, s := range map[string]store.StringStore{"memory": store.NewMemoryStore(), "file": file, "sqlite": sqlite} {
+		handler := helpers.StringApiHandler{String: "hello there"}
+		if err := s.Create(handler.GetStringFields([]string{"length"})); err != nil {
+			t.Fatalf("%s: Create unexpected error: %v", name, err)
+		}
+
+		// Two reads of the same string complete different properties, and
+		// the second to be kept does not undo the first
+		first, _ := s.GetByValue("hello there")
+		second, _ := s.GetByValue("hello there")
+		first.Complete([]string{"character_frequency_map"})
+		second.Complete([]string{"word_analysis"})
+		for _, r := range []helpers.Response{first, second} {
+			if err := s.(store.Memoizer).Memoize(r); err != nil {
+				t.Fatalf("%s: Memoize unexpected error: %v", name, err)
+			}
+		}
+
+		stored, _ := s.GetByValue("hello there")
+		if stored.Properties.CharacterFrequencyMap["e"] != 3 || stored.Properties.WordAnalysis == nil || stored.Properties.WordAnalysis.DistinctWords != 2 {
+			t.Errorf("%s: the memoized properties were not merged: %+v", name, stored.Properties)
+		}
+	}
+
+	file.Close()
+	reopened := openTestFileStore(t, dir)
+	defer reopened.Close()
+	if stored, _ := reopened.GetByValue("hello there"); stored.Properties.CharacterFrequencyMap == nil || stored.Properties.WordAnalysis == nil {
+		t.Errorf("Replaying the log did not merge the memoized properties: %+v", stored.Properties)
+	}
+}
+
+// deletingStore deletes victim right after every listing, as a concurrent
+// DELETE landing between reading a page and completing it would
+type deletingStore struct {
+	*store.MemoryStore
+	victim string
+}
+
+func (s deletingStore) List() ([]helpers.Response, error) {
+	items, err := s.MemoryStore.List()
+	s.MemoryStore.Delete(s.victim)
+	return items, err
+}
+
+func (s deletingStore) Query(f helpers.Filter) ([]helpers.Response, error) {
+	items, err := s.MemoryStore.Query(f)
+	s.MemoryStore.Delete(s.victim)
+	return items, err
+}
+
+func TestListingSurvivesConcurrentDelete(t *testing.T) {
+	defer ResetTestBank()
+
+	for _, target := range []string{
+		"/strings?fields=character_frequency_map",
+		"/strings?limit=5&fields=character_frequency_map",
+		"/strings/filter-by-natural-language?query=strings+longer+than+2+characters&fields=character_frequency_map",
+	} {
+		memory := store.NewMemoryStore()
+		for _, value := range []string{"abc", "doomed"} {
+			handler := helpers.StringApiHandler{String: value}
+			memory.Create(handler.GetStringFields([]string{"length"}))
+		}
+		TestBank = deletingStore{MemoryStore: memory, victim: "doomed"}
+
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		SetupTestRouter().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s with a concurrent delete returned %d: %s", target, w.Code, w.Body.String())
+			continue
+		}
+		if stored, err := memory.GetByValue("abc"); err != nil || stored.Properties.CharacterFrequencyMap["a"] != 1 {
+			t.Errorf("GET %s did not keep the lazy properties of the surviving string: %+v", target, stored.Properties)
+		}
+		if _, err := memory.GetByValue("doomed"); err == nil {
+			t.Errorf("GET %s brought the deleted string back", target)
+		}
+	}
+}
+
+func TestCompleteSkipsDeletedStrings(t *testing.T) {
+	file := openTestFileStore(t, t.TempDir())
+	defer file.Close()
+	sqlite := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer sqlite.Close()
+
+	for name, s := range map[string]store.StringStore{"memory": store.NewMemoryStore(), "file": file, "sqlite": sqlite} {
+		for _, value := range []string{"abc", "doomed"} {
+			handler := helpers.StringApiHandler{String: value}
+			if err := s.Create(handler.GetStringFields([]string{"length"})); err != nil {
+				t.Fatalf("%s: Create unexpected error: %v", name, err)
+			}
+		}
+		items, _ := s.List()
+		if err := s.Delete("doomed"); err != nil {
+			t.Fatalf("%s: Delete unexpected error: %v", name, err)
+		}
+
+		store.Complete(s, items, []string{"character_frequency_map"})
+		if len(items) != 2 || items[1].Properties.CharacterFrequencyMap["o"] != 2 {
+			t.Errorf("%s: Complete did not compute the properties of the deleted string: %+v", name, items)
+		}
+		if stored, _ := s.GetByValue("abc"); stored.Properties.CharacterFrequencyMap["a"] != 1 {
+			t.Errorf("%s: Complete did not keep the properties of the surviving string: %+v", name, stored.Properties)
+		}
+		if _, err := s.GetByValue("doomed"); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s: Complete brought the deleted string back: %v", name, err)
+		}
+	}
+}
+
+func TestFieldsDeferRegisteredAnalyzers(t *testing.T) {
+	useTestAnalyzers(t)
+
+	handler := helpers.StringApiHandler{String: quickFox}
+	response := handler.GetStringFields([]string{"length"})
+	if _, ok := response.Properties.Value("capital_count"); ok {
+		t.Errorf("GetStringFields ran a registered analyzer that was not asked for: %+v", response.Properties)
+	}
+	if response.Properties.Sha256Hash == "" || response.Properties.UniqueCharacters == 0 {
+		t.Errorf("GetStringFields skipped properties stores index: %+v", response.Properties)
+	}
+	if selected := handler.GetStringFields([]string{"capital_count"}); selected.Properties.Number("capital_count") != 2 {
+		t.Errorf("GetStringFields did not run a registered analyzer that was asked for: %+v", selected.Properties)
+	}
+
+	// Filters compute what the string lacks
+	expression, _ := helpers.ParseFilterExpression("capital_count = 2 AND is_pangram")
+	if matches := (helpers.FilterTree{Expression: expression}).Apply([]helpers.Response{response}); len(matches) != 1 {
+		t.Errorf("Filtering on registered properties the string lacks matched %d strings, expected 1", len(matches))
+	}
+
+	s := store.NewMemoryStore()
+	if err := s.Create(response); err != nil {
+		t.Fatalf("Create unexpected error: %v", err)
+	}
+	items, _ := s.List()
+	store.Complete(s, items, []string{"is_pangram"})
+	stored, _ := s.GetByValue(quickFox)
+	if _, ok := stored.Properties.Value("capital_count"); ok || !stored.Properties.Flag("is_pangram") {
+		t.Errorf("Complete(is_pangram) kept %+v, expected is_pangram alone", stored.Properties.Extra)
+	}
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"errors"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestFileStore(t *testing.T, dir string) *store.FileStore {
+	t.Helper()
+	s, err := store.OpenFileStore(store.FileOptions{Dir: dir, Fsync: store.FsyncAlways})
+	if err != nil {
+		t.Fatalf("OpenFileStore unexpected error: %v", err)
+	}
+	return s
+}
+
+func createValues(t *testing.T, s store.StringStore, values ...string) {
+	t.Helper()
+	for _, value := range values {
+		handler := helpers.StringApiHandler{String: value}
+		if err := s.Create(handler.GetString()); err != nil {
+			t.Fatalf("Create(%q) unexpected error: %v", value, err)
+		}
+	}
+}
+
+func listValues(t *testing.T, s store.StringStore) []string {
+	t.Helper()
+	items, err := s.List()
+	if err != nil {
+		t.Fatalf("List unexpected error: %v", err)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values
+}
+
+func expectValues(t *testing.T, s store.StringStore, expected ...string) {
+	t.Helper()
+	values := listValues(t, s)
+	if len(values) != len(expected) {
+		t.Fatalf("Store holds %v, expected %v", values, expected)
+	}
+	for i := range expected {
+		if values[i] != expected[i] {
+			t.Fatalf("Store holds %v, expected %v", values, expected)
+		}
+	}
+}
+
+func TestFileStoreReplaysLog(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestFileStore(t, dir)
+	createValues(t, s, "racecar", "hello world", "abba")
+	if err := s.Delete("hello world"); err != nil {
+		t.Fatalf("Delete unexpected error: %v", err)
+	}
+	s.Close()
+
+	reopened := openTestFileStore(t, dir)
+	defer reopened.Close()
+	expectValues(t, reopened, "racecar", "abba")
+
+	duplicate := helpers.StringApiHandler{String: "abba"}
+	if err := reopened.Create(duplicate.GetString()); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("Create duplicate after replay returned %v, expected ErrAlreadyExists", err)
+	}
+}
+
+func TestFileStoreSkipsTruncatedLastRecord(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestFileStore(t, dir)
+	createValues(t, s, "racecar", "abba")
+	s.Close()
+
+	// Simulate a crash in the middle of writing the next record
+	wal := filepath.Join(dir, "strings.wal.jsonl")
+	file, err := os.OpenFile(wal, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":3,"op":"create","record":{"id":"ab`)
+	file.Close()
+
+	reopened := openTestFileStore(t, dir)
+	expectValues(t, reopened, "racecar", "abba")
+
+	// New writes must land on a clean line after the dropped record
+	createValues(t, reopened, "level")
+	reopened.Close()
+
+	again := openTestFileStore(t, dir)
+	defer again.Close()
+	expectValues(t, again, "racecar", "abba", "level")
+}
+
+func TestFileStoreRejectsCorruptRecordMidLog(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestFileStore(t, dir)
+	createValues(t, s, "racecar")
+	s.Close()
+
+	wal := filepath.Join(dir, "strings.wal.jsonl")
+	data, _ := os.ReadFile(wal)
+	corrupted := append([]byte("not json\n"), data...)
+	if err := os.WriteFile(wal, corrupted, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.OpenFileStore(store.FileOptions{Dir: dir}); err == nil {
+		t.Errorf("OpenFileStore with a corrupt record mid-log expected error, got nil")
+	}
+}
+
+func TestFileStoreUpgradesByteLengthRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	// A log written when length counted bytes and there was no rune_count
+	record := `{"seq":1,"op":"create","record":{"id":"x","value":"été","properties":{"length":5,"is_palindrome":false,"unique_characters":2,"word_Count":1}}}`
+	if err := os.WriteFile(filepath.Join(dir, "strings.wal.jsonl"), []byte(record+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTestFileStore(t, dir)
+	defer s.Close()
+	r, err := s.GetByValue("été")
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if r.ID != "x" || r.Properties.Length != 3 || r.Properties.ByteLength != 5 || r.Properties.RuneCount != 3 || !r.Properties.IsPalindrome {
+		t.Errorf("Upgraded record = %+v, expected id x, 3 graphemes, 5 bytes, 3 runes and a palindrome", r)
+	}
+}
+
+func TestFileStoreUpgradesRecordsWithoutPalindromeMode(t *testing.T) {
+	dir := t.TempDir()
+
+	// Only spaces were ignored before palindrome modes, so the tab mattered
+	record := `{"seq":1,"op":"create","record":{"id":"y","value":"ab\tba","properties":{"length":5,"byte_length":5,"rune_count":5,"is_palindrome":false}}}`
+	if err := os.WriteFile(filepath.Join(dir, "strings.wal.jsonl"), []byte(record+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := openTestFileStore(t, dir)
+	defer s.Close()
+	r, err := s.GetByValue("ab\tba")
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if !r.Properties.IsPalindrome || r.Properties.PalindromeMode != "case,whitespace" {
+		t.Errorf("Upgraded record = %+v, expected a palindrome in mode case,whitespace", r.Properties)
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	s := openTestFileStore(t, dir)
+	createValues(t, s, "racecar", "hello world")
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact unexpected error: %v", err)
+	}
+
+	wal := filepath.Join(dir, "strings.wal.jsonl")
+	if info, err := os.Stat(wal); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty log after compaction, got %v (%v)", info.Size(), err)
+	}
+
+	createValues(t, s, "abba")
+	if err := s.Delete("racecar"); err != nil {
+		t.Fatalf("Delete unexpected error: %v", err)
+	}
+	s.Close()
+
+	reopened := openTestFileStore(t, dir)
+	defer reopened.Close()
+	expectValues(t, reopened, "hello world", "abba")
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected store.FsyncPolicy
+		hasError bool
+	}{
+		{"", store.FsyncAlways, false},
+		{"always", store.FsyncAlways, false},
+		{"interval", store.FsyncInterval, false},
+		{"never", store.FsyncNever, false},
+		{"sometimes", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := store.ParseFsyncPolicy(test.input)
+		if (err != nil) != test.hasError || result != test.expected {
+			t.Errorf("ParseFsyncPolicy(%q) = %q, %v", test.input, result, err)
+		}
+	}
+}
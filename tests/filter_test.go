@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilterFromMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters map[string]interface{}
+		valid   bool
+	}{
+		{"parser output", map[string]interface{}{"is_palindrome": true, "word_count": 1, "contains_character": "a"}, true},
+		{"decoded JSON numbers", map[string]interface{}{"min_length": float64(3), "max_length": json.Number("9")}, true},
+		{"fractional length", map[string]interface{}{"min_length": 2.5}, false},
+		{"string palindrome flag", map[string]interface{}{"is_palindrome": "yes"}, false},
+		{"numeric character", map[string]interface{}{"contains_character": 7}, false},
+		{"two characters", map[string]interface{}{"contains_character": "ab"}, false},
+		{"negative word count", map[string]interface{}{"word_count": -2}, false},
+		{"unknown key", map[string]interface{}{"color": "red"}, false},
+	}
+
+	for _, test := range tests {
+		_, err := helpers.FilterFromMap(test.filters)
+		if (err == nil) != test.valid {
+			t.Errorf("FilterFromMap(%s) error = %v, expected valid %v", test.name, err, test.valid)
+		}
+	}
+
+	f, _ := helpers.FilterFromMap(map[string]interface{}{"min_length": float64(3), "contains_character": "é"})
+	if *f.MinLength != 3 || f.ContainsCharacter != "é" {
+		t.Errorf("FilterFromMap converted to %+v", f)
+	}
+	if m := f.ToMap(); len(m) != 2 || m["min_length"] != 3 {
+		t.Errorf("ToMap() = %v, expected min_length 3 and contains_character", m)
+	}
+}
+
+func TestFilterConflicts(t *testing.T) {
+	yes, no := true, false
+	zero, one, two, three, four, five, ten := 0, 1, 2, 3, 4, 5, 10
+
+	tests := []struct {
+		name      string
+		filter    helpers.Filter
+		conflicts int
+	}{
+		{"empty", helpers.Filter{}, 0},
+		{"min above max", helpers.Filter{MinLength: &ten, MaxLength: &five}, 1},
+		{"min equals max", helpers.Filter{MinLength: &five, MaxLength: &five}, 0},
+		{"max length zero", helpers.Filter{MaxLength: &zero}, 1},
+		{"three words in five characters", helpers.Filter{WordCount: &three, MaxLength: &five}, 0},
+		{"three words in four characters", helpers.Filter{WordCount: &three, MaxLength: &four}, 1},
+		{"no words containing a letter", helpers.Filter{WordCount: &zero, ContainsCharacter: "a"}, 1},
+		{"no words containing a space", helpers.Filter{WordCount: &zero, ContainsCharacter: " "}, 0},
+		{"non-palindrome of one character", helpers.Filter{IsPalindrome: &no, MaxLength: &one}, 1},
+		{"non-palindrome of two characters", helpers.Filter{IsPalindrome: &no, MaxLength: &two}, 0},
+		{"palindrome of one character", helpers.Filter{IsPalindrome: &yes, MaxLength: &one}, 0},
+		{"several contradictions", helpers.Filter{IsPalindrome: &no, WordCount: &two, MinLength: &five, MaxLength: &one}, 3},
+	}
+
+	for _, test := range tests {
+		conflicts := test.filter.Conflicts()
+		if len(conflicts) != test.conflicts {
+			t.Errorf("Conflicts(%s) = %q, expected %d conflicts", test.name, conflicts, test.conflicts)
+		}
+	}
+}
+
+func TestConflictsWorkBudget(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("the class members below are past a 32-bit int")
+	}
+	// 1294268491 is followed by 287 composites: one alternative walking
+	// them is decided, 256 of them share one budget instead of walking 287
+	// values apiece
+	gap := "(%s BETWEEN 1294268492 AND 1294268778 AND %s IS prime)"
+	single, _ := helpers.ParseFilterExpression(fmt.Sprintf(gap, "length", "length"))
+	if conflicts := (helpers.FilterTree{Expression: single}).Conflicts(); len(conflicts) != 1 {
+		t.Errorf("Conflicts() = %v, expected the prime gap to be walked", conflicts)
+	}
+
+	fields := []string{"length", "byte_length", "rune_count", "word_count", "unique_characters"}
+	branches := make([]string, 256)
+	for i := range branches {
+		field := fields[i%len(fields)]
+		branches[i] = fmt.Sprintf(gap, field, field)
+	}
+	expression, err := helpers.ParseFilterExpression(strings.Join(branches, " OR "))
+	if err != nil {
+		t.Fatalf("ParseFilterExpression unexpected error: %v", err)
+	}
+
+	started := time.Now()
+	conflicts := (helpers.FilterTree{Expression: expression}).Conflicts()
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("Conflicts took %v, expected the shared budget to bound it", elapsed)
+	}
+	if conflicts != nil {
+		t.Errorf("Conflicts() = %v, expected nil once the budget ran out", conflicts)
+	}
+
+	// Classes with few members are decided without walking the range,
+	// however wide
+	for _, test := range []struct {
+		expression string
+		conflicts  bool
+	}{
+		{"length BETWEEN 1000 AND 28116440335966 AND length IS prime AND length IS armstrong", true},
+		{"length >= 1000 AND length IS prime AND length IS armstrong", false},
+		{"length >= 10 AND length IS armstrong AND length IS odd", false},
+		{"length >= 10 AND length IS armstrong AND NOT length IS odd AND NOT length IS even", true},
+		{"length > 8589869056 AND length IS perfect AND NOT length IS armstrong", false},
+		{"length > 2305843008139952128 AND length IS perfect", true},
+		{"length >= 3 AND length IS prime AND NOT length IS odd", true},
+		{"length IS prime AND NOT length IS odd", false},
+	} {
+		expression, err := helpers.ParseFilterExpression(test.expression)
+		if err != nil {
+			t.Fatalf("ParseFilterExpression(%q) unexpected error: %v", test.expression, err)
+		}
+		started := time.Now()
+		conflicts := (helpers.FilterTree{Expression: expression}).Conflicts()
+		if (conflicts != nil) != test.conflicts {
+			t.Errorf("Conflicts(%q) = %v, expected conflicts: %v", test.expression, conflicts, test.conflicts)
+		}
+		if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+			t.Errorf("Conflicts(%q) took %v", test.expression, elapsed)
+		}
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	tests := []struct {
+		filters  m
+		expected string
+	}{
+		{m{}, "/strings"},
+		{m{"is_palindrome": true, "min_length": 5, "word_count": 1}, "/strings?is_palindrome=true&min_length=5&word_count=1"},
+		{m{"contains_character": "&"}, "/strings?contains_character=%26"},
+		{m{"word_count": 0, "min_length": 0}, "/strings?q=word_count+%3D+0"},
+		{m{"q": "word_count >= 2", "is_palindrome": false}, "/strings?is_palindrome=false&q=word_count+%3E%3D+2"},
+		{m{"or": l{m{"is_palindrome": true}, m{"min_length": 3, "max_length": 3}}}, "/strings?q=is_palindrome+OR+length+%3D+3"},
+		{m{"contains_character": "z", "not": m{"contains_character": "y"}, "and": l{m{"word_count": 2}}}, "/strings?contains_character=z&q=NOT+contains%28%27y%27%29+AND+word_count+%3D+2"},
+	}
+
+	for _, test := range tests {
+		tree, err := helpers.FilterTreeFromMap(test.filters)
+		if err != nil {
+			t.Fatalf("FilterTreeFromMap(%v) unexpected error: %v", test.filters, err)
+		}
+		if result := tree.CanonicalURL(); result != test.expected {
+			t.Errorf("CanonicalURL(%v) = %q, expected %q", test.filters, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"fmt"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// syntheticResponse builds a stored string without the costly hash and
+// frequency map, so large stores fit in memory
+func syntheticResponse(rng *rand.Rand, i int) helpers.Response {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	words := make([]string, 1+rng.Intn(4))
+	for w := range words {
+		word := make([]byte, 1+rng.Intn(8))
+		for j := range word {
+			word[j] = letters[rng.Intn(len(letters))]
+		}
+		if rng.Intn(20) == 0 {
+			// Mirror the first half to make a palindrome
+			for j := 0; j < len(word)/2; j++ {
+				word[len(word)-1-j] = word[j]
+			}
+		}
+		words[w] = string(word)
+	}
+	value := strings.Join(words, " ") + " " + strconv.Itoa(i)
+	if i%2 == 0 {
+		value = strconv.Itoa(i) + words[0]
+	}
+	return helpers.Response{
+		ID:    strconv.Itoa(i),
+		Value: value,
+		Properties: helpers.PropertiesMap{
+			Length:       len(value),
+			IsPalindrome: helpers.IsPalindrome(value),
+			WordCount:    helpers.CountWords(value),
+		},
+	}
+}
+
+func scanSelect(items []helpers.Response, c helpers.Filter) []helpers.Response {
+	matches := make([]helpers.Response, 0)
+	for _, item := range items {
+		if c.Match(item) {
+			matches = append(matches, item)
+		}
+	}
+	return matches
+}
+
+func randomCriteria(rng *rand.Rand) helpers.Filter {
+	var c helpers.Filter
+	if rng.Intn(3) == 0 {
+		value := rng.Intn(2) == 0
+		c.IsPalindrome = &value
+	}
+	if rng.Intn(2) == 0 {
+		value := rng.Intn(20)
+		c.MinLength = &value
+	}
+	if rng.Intn(2) == 0 {
+		value := rng.Intn(40)
+		c.MaxLength = &value
+	}
+	if rng.Intn(3) == 0 {
+		value := 1 + rng.Intn(5)
+		c.WordCount = &value
+	}
+	if rng.Intn(3) == 0 {
+		c.ContainsCharacter = string(rune('a' + rng.Intn(26)))
+	}
+	return c
+}
+
+func TestMemoryStoreIndexesMatchScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	s := store.NewMemoryStore()
+
+	const total = 5000
+	for i := 0; i < total; i++ {
+		if err := s.Create(syntheticResponse(rng, i)); err != nil {
+			t.Fatalf("Create unexpected error: %v", err)
+		}
+	}
+
+	check := func(stage string) {
+		items, _ := s.List()
+		for q := 0; q < 200; q++ {
+			c := randomCriteria(rng)
+			indexed, err := s.Query(c)
+			if err != nil {
+				t.Fatalf("Query unexpected error: %v", err)
+			}
+			scanned := scanSelect(items, c)
+			if len(indexed) != len(scanned) {
+				t.Fatalf("%s: Query(%+v) returned %d results, scan returned %d", stage, c, len(indexed), len(scanned))
+			}
+			for i := range indexed {
+				if indexed[i].Value != scanned[i].Value {
+					t.Fatalf("%s: Query(%+v) result %d is %q, scan has %q", stage, c, i, indexed[i].Value, scanned[i].Value)
+				}
+			}
+		}
+	}
+	check("after inserts")
+
+	// Delete most strings to exercise both index removal and slot renumbering
+	items, _ := s.List()
+	for i, item := range items {
+		if i%4 != 0 {
+			if err := s.Delete(item.Value); err != nil {
+				t.Fatalf("Delete unexpected error: %v", err)
+			}
+		}
+	}
+	check("after deletes")
+
+	for i := total; i < total+500; i++ {
+		s.Create(syntheticResponse(rng, i))
+	}
+	check("after reinserting")
+}
+
+// BenchmarkMemoryStoreQuery compares indexed lookups with a full scan as the
+// store grows. Indexed selective queries should stay roughly flat while the
+// scan grows linearly. Run with: go test -bench MemoryStoreQuery ./tests/
+func BenchmarkMemoryStoreQuery(b *testing.B) {
+	three, five, eight := 3, 5, 8
+	yes := true
+	queries := []struct {
+		name     string
+		criteria helpers.Filter
+	}{
+		{"palindrome_length", helpers.Filter{IsPalindrome: &yes, MinLength: &five, MaxLength: &eight}},
+		{"word_count_length", helpers.Filter{WordCount: &three, MaxLength: &eight}},
+		{"exact_length", helpers.Filter{MinLength: &five, MaxLength: &five, ContainsCharacter: "q"}},
+	}
+
+	for _, size := range []int{10_000, 100_000, 1_000_000} {
+		rng := rand.New(rand.NewSource(1))
+		s := store.NewMemoryStore()
+		for i := 0; i < size; i++ {
+			s.Create(syntheticResponse(rng, i))
+		}
+		items, _ := s.List()
+
+		for _, query := range queries {
+			b.Run(fmt.Sprintf("indexed/%s/%d", query.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					s.Query(query.criteria)
+				}
+			})
+			b.Run(fmt.Sprintf("scan/%s/%d", query.name, size), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					scanSelect(items, query.criteria)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"errors"
+	helpers "hng/step0/helpers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	tests := []struct {
+		query    string
+		language string
+		expected m
+	}{
+		{"palindromes longer than 5 characters", "en", m{"is_palindrome": true, "min_length": 6}},
+		{"no palindromes", "en", m{"is_palindrome": false}},
+		{"chaînes palindromiques de plus de 5 caractères", "fr", m{"is_palindrome": true, "min_length": 6}},
+		{"chaînes qui ne contiennent pas la lettre z", "fr", m{"not": m{"contains_character": "z"}}},
+		{"chaînes d'au moins deux mots", "fr", m{"q": "word_count >= 2"}},
+		{"chaines plus longues que 5 caracteres", "fr", m{"min_length": 6}},
+		{"palindromes ou chaînes sans la lettre e", "fr", m{"or": l{m{"is_palindrome": true}, m{"not": m{"contains_character": "e"}}}}},
+		{"cadenas palíndromas con más de 5 caracteres", "es", m{"is_palindrome": true, "min_length": 6}},
+		{"cadenas de una sola palabra que no son palíndromos", "es", m{"word_count": 1, "is_palindrome": false}},
+		{"cadenas entre 3 y 10 caracteres", "es", m{"min_length": 3, "max_length": 10}},
+		{"cadenas que contienen la letra o", "es", m{"contains_character": "o"}},
+		{"chaînes avec un nombre premier de caractères", "fr", m{"q": "length IS prime"}},
+		{"cadenas con un número par de palabras", "es", m{"q": "word_count IS even"}},
+		{"àwọn palindrome tí ó ní lẹ́tà z", "yo", m{"is_palindrome": true, "contains_character": "z"}},
+		{"okùn tí ó ní ọ̀rọ̀ méjì", "yo", m{"word_count": 2}},
+		{"okùn tí ó gùn ju lẹ́tà márùn-ún lọ", "yo", m{"min_length": 6}},
+		{"okun ti ko ni leta e", "yo", m{"not": m{"contains_character": "e"}}},
+	}
+
+	for _, test := range tests {
+		interpretation, err := helpers.InterpretNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if interpretation.Language != test.language || helpers.DetectLanguage(test.query) != test.language {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) language = %q, expected %q", test.query, interpretation.Language, test.language)
+		}
+		if actual := interpretation.Filter.ToMap(); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) = %v, expected %v", test.query, actual, test.expected)
+		}
+		if interpretation.Confidence != 1 {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) confidence = %v, ignored %q", test.query, interpretation.Confidence, interpretation.IgnoredWords)
+		}
+	}
+}
+
+func TestLanguageGrammars(t *testing.T) {
+	for _, language := range []string{"en", "fr", "es", "yo"} {
+		if _, ok := helpers.LookupGrammar(language); !ok {
+			t.Errorf("LookupGrammar(%q) found no grammar", language)
+		}
+	}
+	if g, ok := helpers.LookupGrammar("fr-CA"); !ok || g.Language() != "fr" {
+		t.Errorf("LookupGrammar(fr-CA) did not fall back to fr")
+	}
+	if _, ok := helpers.LookupGrammar("xx-invalid-tag!"); ok {
+		t.Errorf("LookupGrammar() accepted an invalid tag")
+	}
+
+	// A grammar only reads its own language
+	spanish, _ := helpers.LookupGrammar("es")
+	if _, err := spanish.Interpret("chaînes sans la lettre e"); err == nil {
+		t.Errorf("Spanish grammar read a French query")
+	}
+	// Canonical queries are English and read the same in every language
+	french, _ := helpers.LookupGrammar("fr")
+	interpretation, err := french.Interpret("palindromic strings with at least 2 words")
+	if err != nil || interpretation.Language != "fr" || interpretation.Confidence != 1 {
+		t.Errorf("French grammar on a canonical query: %+v, %v", interpretation, err)
+	}
+
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"fr-FR,fr;q=0.9,en;q=0.8", "fr"},
+		{"de-DE,es;q=0.5", "es"},
+		{"en-US", "en"},
+		{"yo", "yo"},
+	}
+	for _, test := range tests {
+		if g, ok := helpers.MatchGrammar(test.header); !ok || g.Language() != test.expected {
+			t.Errorf("MatchGrammar(%q) did not pick %s", test.header, test.expected)
+		}
+	}
+	for _, header := range []string{"", "de-DE", "*", "not a header;;"} {
+		if g, ok := helpers.MatchGrammar(header); ok {
+			t.Errorf("MatchGrammar(%q) = %s, expected no match", header, g.Language())
+		}
+	}
+}
+
+// echoGrammar is a Grammar that is not lexicon-based
+type echoGrammar struct{}
+
+func (echoGrammar) Language() string { return "la" }
+
+func (echoGrammar) Interpret(query string) (helpers.Interpretation, error) {
+	if query != "palindromi" {
+		return helpers.Interpretation{Language: "la"}, errors.New("unable to parse query")
+	}
+	return helpers.Interpretation{Language: "la", Filter: helpers.FilterTree{Filter: helpers.Filter{IsPalindrome: boolPtr(true)}}, Confidence: 1}, nil
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestRegisterGrammar(t *testing.T) {
+	if err := helpers.RegisterGrammar(echoGrammar{}); err != nil {
+		t.Fatalf("RegisterGrammar() unexpected error: %v", err)
+	}
+	if g, ok := helpers.LookupGrammar("la"); !ok || g.Language() != "la" {
+		t.Fatalf("registered grammar not found")
+	}
+	if language := helpers.DetectLanguage("palindromi"); language != "la" {
+		t.Errorf("DetectLanguage(palindromi) = %q, expected la", language)
+	}
+
+	// A lexicon grammar has to combine with the English lexicon
+	overlay := helpers.Lexicon{Negations: []string{"nicht"}, Boundaries: []string{"nicht"}}
+	if err := helpers.RegisterGrammar(helpers.NewLexiconGrammar("de", overlay)); err == nil {
+		t.Errorf("RegisterGrammar() accepted a word that is both a negation and a boundary")
+	}
+	if err := helpers.RegisterGrammar(helpers.NewLexiconGrammar("de-DE", helpers.Lexicon{})); err == nil {
+		t.Errorf("RegisterGrammar() accepted a regional tag")
+	}
+
+	// The active lexicon must keep combining with every language
+	lexicon := helpers.DefaultLexicon()
+	lexicon.Negations = append(lexicon.Negations, "sans")
+	if err := helpers.SetLexicon(lexicon, "test"); err == nil || !strings.Contains(err.Error(), "fr lexicon") {
+		restoreDefaultLexicon()
+		t.Errorf("SetLexicon() = %v, expected it to clash with the French lexicon", err)
+	}
+	if fr, ok := helpers.LanguageLexicon("fr"); !ok || fr.Language != "fr" || !strings.HasPrefix(fr.Source, "built-in") {
+		t.Errorf("LanguageLexicon(fr) = %+v", fr)
+	}
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"encoding/json"
+	helpers "hng/step0/helpers"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useLexicon makes lexicon active for the rest of the test
+func useLexicon(t *testing.T, lexicon helpers.Lexicon) {
+	t.Helper()
+	if err := helpers.SetLexicon(lexicon, "test"); err != nil {
+		t.Fatalf("SetLexicon() unexpected error: %v", err)
+	}
+	t.Cleanup(restoreDefaultLexicon)
+}
+
+func restoreDefaultLexicon() {
+	if err := helpers.SetLexicon(helpers.DefaultLexicon(), "built-in"); err != nil {
+		panic(err)
+	}
+}
+
+func TestDefaultLexicon(t *testing.T) {
+	if err := helpers.DefaultLexicon().Validate(); err != nil {
+		t.Fatalf("built-in lexicon is invalid: %v", err)
+	}
+	if active := helpers.ActiveLexicon(); active.Source != "built-in" || active.LoadedAt == "" {
+		t.Errorf("ActiveLexicon() = %q loaded at %q, expected the built-in lexicon", active.Source, active.LoadedAt)
+	}
+}
+
+func TestLexiconValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(l *helpers.Lexicon)
+		expected string
+	}{
+		{"two words in one entry", func(l *helpers.Lexicon) { l.Fillers = append(l.Fillers, "show me") }, `fillers: "show me" is not a single lowercase word`},
+		{"uppercase word", func(l *helpers.Lexicon) { l.PalindromeWords = append(l.PalindromeWords, "Mirror") }, `palindrome_words: "Mirror" is not a single lowercase word`},
+		{"number word", func(l *helpers.Lexicon) { l.Articles = append(l.Articles, "one") }, `articles: "one" is not a single lowercase word`},
+		{"conflicting roles", func(l *helpers.Lexicon) { l.Negations = append(l.Negations, "but") }, `"but" is in both negations and boundaries`},
+		{"unknown unit property", func(l *helpers.Lexicon) { l.Units["syllables"] = "syllable_count" }, `units: "syllables" measures unknown property "syllable_count"`},
+		{"word superlative of a string property", func(l *helpers.Lexicon) { l.WordSuperlatives["widest"] = "length" }, `word_superlatives: "widest" names unknown property "length"`},
+		{"unknown operator", func(l *helpers.Lexicon) { l.Comparators["beyond"] = helpers.LexiconComparator{Op: ">>"} }, `comparators: "beyond" has operator ">>"`},
+		{"strict or-suffix", func(l *helpers.Lexicon) { l.OrSuffixes["beyond"] = ">" }, `or_suffixes: "beyond" has operator ">"`},
+		{"missing required word", func(l *helpers.Lexicon) { l.ContainsVerbs = []string{"contains"} }, `contains_verbs must include "containing"`},
+		{"redefined required word", func(l *helpers.Lexicon) { l.Synonyms["longer"] = "shorter" }, `synonyms: "longer" is a word queries are written with`},
+		{"empty replacement", func(l *helpers.Lexicon) { l.Synonyms["whatever"] = " " }, `synonyms: "whatever" has an empty replacement`},
+	}
+
+	for _, test := range tests {
+		lexicon := helpers.DefaultLexicon()
+		test.change(&lexicon)
+		err := lexicon.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%s: Validate() = %v, expected an error containing %s", test.name, err, test.expected)
+		}
+		if err := helpers.SetLexicon(lexicon, "test"); err == nil {
+			restoreDefaultLexicon()
+			t.Errorf("%s: SetLexicon() accepted an invalid lexicon", test.name)
+		}
+	}
+}
+
+func TestLexiconSynonyms(t *testing.T) {
+	type m = map[string]interface{}
+	tests := []struct {
+		query    string
+		expected m
+	}{
+		{"mirror words longer than 3 characters", m{"is_palindrome": true, "min_length": 4}},
+		{"lengthy strings containing the letter z", m{"min_length": 11, "contains_character": "z"}},
+		{"monosyllabic palindromes", m{"word_count": 1, "is_palindrome": true}},
+		{"strings that are not mirror words", m{"is_palindrome": false}},
+	}
+	for _, test := range tests {
+		result, err := helpers.ParseNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ParseNaturalLanguageQuery(%q) = %v, expected %v", test.query, result, test.expected)
+		}
+	}
+
+	// Spans point at the phrase that was written, not its replacement
+	interpretation, err := helpers.InterpretNaturalLanguageQuery("lengthy mirror words")
+	if err != nil {
+		t.Fatalf("InterpretNaturalLanguageQuery() unexpected error: %v", err)
+	}
+	expected := []helpers.QuerySpan{
+		{Start: 0, End: 7, Text: "lengthy", Meaning: "length > 10"},
+		{Start: 8, End: 20, Text: "mirror words", Meaning: "is_palindrome"},
+	}
+	if !reflect.DeepEqual(interpretation.Spans, expected) || interpretation.Confidence != 1 {
+		t.Errorf("InterpretNaturalLanguageQuery() spans = %+v, confidence %v, expected %+v", interpretation.Spans, interpretation.Confidence, expected)
+	}
+}
+
+func TestCustomLexicon(t *testing.T) {
+	lexicon := helpers.DefaultLexicon()
+	lexicon.ExcludesVerbs = append(lexicon.ExcludesVerbs, "excluding")
+	lexicon.Comparators["beyond"] = helpers.LexiconComparator{Op: ">", Field: "length"}
+	lexicon.Synonyms["one-liners"] = "single-word strings"
+	useLexicon(t, lexicon)
+
+	result, err := helpers.ParseNaturalLanguageQuery("one-liners beyond 5 excluding the letter q")
+	if err != nil {
+		t.Fatalf("ParseNaturalLanguageQuery() unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{"word_count": 1, "min_length": 6, "not": map[string]interface{}{"contains_character": "q"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseNaturalLanguageQuery() = %v, expected %v", result, expected)
+	}
+	if active := helpers.ActiveLexicon(); active.Source != "test" || active.Lexicon.Synonyms["one-liners"] == "" {
+		t.Errorf("ActiveLexicon() = %+v, expected the test lexicon", active)
+	}
+}
+
+func TestLoadLexiconFile(t *testing.T) {
+	dir := t.TempDir()
+	lexicon := helpers.DefaultLexicon()
+	lexicon.Synonyms["twins"] = "2-word strings"
+
+	data, _ := json.Marshal(lexicon)
+	jsonPath := filepath.Join(dir, "lexicon.json")
+	os.WriteFile(jsonPath, data, 0o644)
+	loaded, err := helpers.LoadLexiconFile(jsonPath)
+	if err != nil {
+		t.Fatalf("LoadLexiconFile(json) unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, lexicon) {
+		t.Errorf("LoadLexiconFile(json) did not read back the lexicon it was given")
+	}
+
+	for name, content := range map[string]string{
+		"unknown.yaml":  "negatoins: [not]\n",
+		"unknown.json":  `{"negatoins": ["not"]}`,
+		"invalid.yaml":  "negations: [not, Never]\n",
+		"lexicon.toml":  "negations = []\n",
+		"truncated.yml": "negations: [not\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := helpers.LoadLexiconFile(path); err == nil {
+			t.Errorf("LoadLexiconFile(%s) expected error, got nil", name)
+		}
+	}
+}
+
+func TestLexiconWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lexicon.yaml")
+	write := func(synonyms string, age time.Duration) {
+		t.Helper()
+		base, err := os.ReadFile("../helpers/lexicon.yaml")
+		if err != nil {
+			t.Fatalf("reading the built-in lexicon: %v", err)
+		}
+		content := strings.Replace(string(base), "synonyms:\n", "synonyms:\n"+synonyms, 1)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Give every version its own modification time
+		stamp := time.Now().Add(-age)
+		os.Chtimes(path, stamp, stamp)
+	}
+	parses := func(query string) bool {
+		_, err := helpers.ParseNaturalLanguageQuery(query)
+		return err == nil
+	}
+	waitFor := func(query string) {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); !parses(query); {
+			if time.Now().After(deadline) {
+				t.Fatalf("lexicon was not reloaded: %q does not parse", query)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	write("  twins: 2-word strings\n", time.Hour)
+	watcher, err := helpers.WatchLexicon(path, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchLexicon() unexpected error: %v", err)
+	}
+	t.Cleanup(restoreDefaultLexicon)
+	defer watcher.Close()
+
+	if !parses("twins") || helpers.ActiveLexicon().Source != path {
+		t.Fatalf("WatchLexicon() did not activate %s", path)
+	}
+
+	// A changed file is picked up by polling
+	write("  triplets: 3-word strings\n", time.Minute)
+	waitFor("triplets")
+	if parses("twins") {
+		t.Errorf("synonym removed from the file is still active")
+	}
+
+	// A broken file keeps the previous lexicon
+	os.WriteFile(path, []byte("negations: [not\n"), 0o644)
+	if err := watcher.Reload(); err == nil {
+		t.Errorf("Reload() of a broken file expected error, got nil")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !parses("triplets") {
+		t.Errorf("broken file replaced the active lexicon")
+	}
+
+	// Reload picks up the fixed file, as on SIGHUP
+	write("  quads: 4-word strings\n", 0)
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if !parses("quads") {
+		t.Errorf("Reload() did not activate the fixed file")
+	}
+}
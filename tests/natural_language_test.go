@@ -2,6 +2,7 @@ package tests
 
 import (
 	helpers "hng/step0/helpers"
+	"reflect"
 	"testing"
 )
 
@@ -108,6 +109,393 @@ func TestParseNaturalLanguageQuery(t *testing.T) {
 	}
 }
 
+func TestParseNaturalLanguagePhrasings(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	tests := []struct {
+		query    string
+		expected m
+	}{
+		// Numbers as digits and as words
+		{"strings of exactly twenty-three characters", m{"min_length": 23, "max_length": 23}},
+		{"strings longer than ten characters", m{"min_length": 11}},
+		{"strings shorter than Twelve letters", m{"max_length": 11}},
+		{"strings no more than one hundred and twenty characters long", m{"max_length": 120}},
+		{"strings of ninety-nine characters or fewer", m{"max_length": 99}},
+		{"a thousand characters or more", m{"min_length": 1000}},
+		{"strings longer than 3 million characters", m{"min_length": 3000001}},
+		{"strings longer than two billion characters", m{"min_length": 2000000001}},
+		{"strings with forty two characters", m{"min_length": 42, "max_length": 42}},
+		{"strings with 4 words", m{"word_count": 4}},
+		{"three-word strings", m{"word_count": 3}},
+		{"single-word strings", m{"word_count": 1}},
+		{"strings with a single word", m{"word_count": 1}},
+		// Classes of numbers
+		{"strings with a prime number of characters", m{"q": "length IS prime"}},
+		{"palindromes whose word count is not even", m{"is_palindrome": true, "q": "word_count IS odd"}},
+		{"strings whose length isn't prime", m{"q": "NOT length IS prime"}},
+		{"strings with an odd number of unique characters", m{"q": "unique_characters IS odd"}},
+		{"even length strings where the number of words is perfect", m{"q": "length IS even AND word_count IS perfect"}},
+		{"one word palindromes", m{"word_count": 1, "is_palindrome": true}},
+		// Bytes and runes
+		{"strings longer than 10 bytes", m{"q": "byte_length > 10"}},
+		{"strings with at most 4 runes", m{"q": "rune_count <= 4"}},
+		{"strings between 5 and 20 bytes", m{"q": "byte_length BETWEEN 5 AND 20"}},
+		{"palindromes with an even number of bytes", m{"is_palindrome": true, "q": "byte_length IS even"}},
+
+		// Ranges and bounds
+		{"strings between three and ten characters long", m{"min_length": 3, "max_length": 10}},
+		{"strings between 10 and 3 characters", m{"min_length": 3, "max_length": 10}},
+		{"palindromes from 3 to 5 characters", m{"is_palindrome": true, "min_length": 3, "max_length": 5}},
+		{"strings with length between 2 and 4", m{"min_length": 2, "max_length": 4}},
+		{"strings of length 7", m{"min_length": 7, "max_length": 7}},
+		{"strings that are 5 characters in length", m{"min_length": 5, "max_length": 5}},
+		{"5-letter palindromes", m{"is_palindrome": true, "min_length": 5, "max_length": 5}},
+		{"strings at least 8 characters long", m{"min_length": 8}},
+		{"palindromes with at most five letters", m{"is_palindrome": true, "max_length": 5}},
+		{"strings of up to 6 chars", m{"max_length": 6}},
+		{"strings with a minimum of 4 characters", m{"min_length": 4}},
+		{"strings less than or equal to 8 characters", m{"max_length": 8}},
+		{"strings greater than or equal to 3 characters", m{"min_length": 3}},
+		{"strings over 10 chars", m{"min_length": 11}},
+		{"strings under twelve letters", m{"max_length": 11}},
+		{"strings with 10+ characters", m{"min_length": 10}},
+		{"strings with at least 5 characters and at most 9 characters", m{"min_length": 5, "max_length": 9}},
+		{"strings longer than 3 characters and longer than 6 characters", m{"min_length": 7}},
+		{"strings with at least 2 words", m{"q": "word_count >= 2"}},
+		{"strings with more than two words", m{"q": "word_count > 2"}},
+		{"strings with 3 or more words", m{"q": "word_count >= 3"}},
+		{"strings with three words or more", m{"q": "word_count >= 3"}},
+		{"strings with fewer than 3 words", m{"q": "word_count < 3"}},
+		{"strings between 2 and 4 words", m{"q": "word_count BETWEEN 2 AND 4"}},
+		{"multi-word strings", m{"q": "word_count >= 2"}},
+
+		// Characters
+		{"strings containing the digit 7", m{"contains_character": "7"}},
+		{"strings containing 'Q'", m{"contains_character": "Q"}},
+		{"strings that include the character \"x\"", m{"contains_character": "x"}},
+		{"strings with the letter k", m{"contains_character": "k"}},
+		{"strings containing a", m{"contains_character": "a"}},
+		{"strings containing a z", m{"contains_character": "z"}},
+		{"strings containing the letter é", m{"contains_character": "é"}},
+		{"strings that contain the last vowel", m{"contains_character": "u"}},
+		{"strings containing the third vowel", m{"contains_character": "i"}},
+		{"strings containing the second letter of the alphabet", m{"contains_character": "b"}},
+		{"palindromes containing a and b", m{"is_palindrome": true, "contains_character": "a", "and": l{m{"contains_character": "b"}}}},
+		{"strings containing the letters x, y and z", m{"contains_character": "x", "and": l{m{"contains_character": "y"}, m{"contains_character": "z"}}}},
+		{"strings containing z and a single word", m{"contains_character": "z", "word_count": 1}},
+		{"strings containing z and 5 letters", m{"contains_character": "z", "min_length": 5, "max_length": 5}},
+
+		// Negation is scoped to its clause
+		{"strings that are not palindromes but contain the letter z", m{"is_palindrome": false, "contains_character": "z"}},
+		{"strings that aren't palindromes and have at least 5 characters", m{"is_palindrome": false, "min_length": 5}},
+		{"strings that contain z but not the letter y", m{"contains_character": "z", "not": m{"contains_character": "y"}}},
+		{"palindromes that don't contain the letter e", m{"is_palindrome": true, "not": m{"contains_character": "e"}}},
+		{"strings that do not contain the letter e and are palindromes", m{"is_palindrome": true, "not": m{"contains_character": "e"}}},
+		{"strings without the letter q", m{"not": m{"contains_character": "q"}}},
+		{"strings without a and b", m{"not": m{"contains_character": "a"}, "and": l{m{"not": m{"contains_character": "b"}}}}},
+		{"strings with no z", m{"not": m{"contains_character": "z"}}},
+		{"strings not longer than 5 characters", m{"max_length": 5}},
+		{"strings that are not shorter than 4 characters", m{"min_length": 4}},
+		{"strings not exactly 5 characters", m{"not": m{"min_length": 5, "max_length": 5}}},
+		{"strings not between 3 and 5 characters long", m{"not": m{"min_length": 3, "max_length": 5}}},
+		{"not not palindromes", m{"is_palindrome": true}},
+		{"nonpalindromic strings", m{"is_palindrome": false}},
+		{"strings that never are palindromes", m{"is_palindrome": false}},
+		{"not palindromes, longer than 3 characters", m{"is_palindrome": false, "min_length": 4}},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.ParseNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ParseNaturalLanguageQuery(%q) = %v, expected %v", test.query, result, test.expected)
+		}
+	}
+}
+
+func TestParseNaturalLanguageAlternatives(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	tests := []struct {
+		query    string
+		expected m
+	}{
+		{"palindromes or strings containing the letter q", m{"or": l{m{"is_palindrome": true}, m{"contains_character": "q"}}}},
+		{"single word palindromes or strings longer than 10 characters", m{"or": l{m{"word_count": 1, "is_palindrome": true}, m{"min_length": 11}}}},
+		{"palindromes containing a or b", m{"is_palindrome": true, "or": l{m{"contains_character": "a"}, m{"contains_character": "b"}}}},
+		{"strings with 2 or 3 words", m{"or": l{m{"word_count": 2}, m{"word_count": 3}}}},
+		{"strings with 2 or more words", m{"q": "word_count >= 2"}},
+		{"either palindromes or two word strings or strings with the letter z", m{"or": l{m{"is_palindrome": true}, m{"word_count": 2}, m{"contains_character": "z"}}}},
+		{"strings that are not palindromes or contain z", m{"or": l{m{"is_palindrome": false}, m{"contains_character": "z"}}}},
+		{"neither palindromes nor strings with the letter z", m{"is_palindrome": false, "not": m{"contains_character": "z"}}},
+		{"strings without a or b", m{"or": l{m{"not": m{"contains_character": "a"}}, m{"not": m{"contains_character": "b"}}}}},
+		{"strings with 1 or 2 words that contain x or y", m{"or": l{m{"word_count": 1}, m{"word_count": 2}}, "and": l{m{"or": l{m{"contains_character": "x"}, m{"contains_character": "y"}}}}}},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.ParseNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ParseNaturalLanguageQuery(%q) = %v, expected %v", test.query, result, test.expected)
+		}
+	}
+}
+
+func TestParseNaturalLanguageErrors(t *testing.T) {
+	for _, query := range []string{
+		"show me everything",
+		"strings with some numbers",
+		"palindromes or long words",
+		"or palindromes",
+		"strings longer than 9223372036854775807 characters",
+		"strings longer than 99999999999999999999 characters",
+		"palindromes longer than a billion billion characters",
+		"strings longer than ten quintillion characters",
+		"strings longer than nine sextillion characters",
+		"strings with 2 zillion words",
+	} {
+		if _, err := helpers.ParseNaturalLanguageQuery(query); err == nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) expected error, got nil", query)
+		}
+	}
+
+	// Contradictions parse, and are left to HasConflictingFilters
+	for _, query := range []string{
+		"two word strings with one word",
+		"palindromic non-palindromes",
+		"strings containing z without the letter z",
+		"strings longer than 10 and shorter than 5 characters or palindromes that are not palindromes",
+	} {
+		result, err := helpers.ParseNaturalLanguageQuery(query)
+		if err != nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) unexpected error: %v", query, err)
+			continue
+		}
+		if !helpers.HasConflictingFilters(result) {
+			t.Errorf("HasConflictingFilters(%v) = false for %q, expected true", result, query)
+		}
+	}
+}
+
+func TestInterpretNaturalLanguageQuery(t *testing.T) {
+	tests := []struct {
+		query       string
+		spans       []helpers.QuerySpan
+		ignored     []string
+		confidence  float64
+		suggestions []string
+	}{
+		{
+			"show me palindromes containing the letter z",
+			[]helpers.QuerySpan{
+				{Start: 8, End: 19, Text: "palindromes", Meaning: "is_palindrome"},
+				{Start: 20, End: 43, Text: "containing the letter z", Meaning: "contains('z')"},
+			},
+			[]string{}, 1, nil,
+		},
+		{
+			"strings that don't contain z or single words",
+			[]helpers.QuerySpan{
+				{Start: 13, End: 28, Text: "don't contain z", Meaning: "NOT contains('z')"},
+				{Start: 29, End: 31, Text: "or", Meaning: "OR"},
+				{Start: 32, End: 44, Text: "single words", Meaning: "word_count = 1"},
+			},
+			[]string{}, 1, nil,
+		},
+		{
+			"fluffy palindromes",
+			[]helpers.QuerySpan{{Start: 7, End: 18, Text: "palindromes", Meaning: "is_palindrome"}},
+			[]string{"fluffy"}, 0.5, nil,
+		},
+		{
+			"palindromes lnger than 5 characters",
+			[]helpers.QuerySpan{
+				{Start: 0, End: 11, Text: "palindromes", Meaning: "is_palindrome"},
+				{Start: 23, End: 35, Text: "5 characters", Meaning: "length = 5"},
+			},
+			[]string{"lnger"}, 0.75, []string{"palindromes longer than 5 characters"},
+		},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.InterpretNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Spans, test.spans) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) spans = %+v, expected %+v", test.query, result.Spans, test.spans)
+		}
+		if !reflect.DeepEqual(result.IgnoredWords, test.ignored) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) ignored = %q, expected %q", test.query, result.IgnoredWords, test.ignored)
+		}
+		if result.Confidence != test.confidence {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) confidence = %v, expected %v", test.query, result.Confidence, test.confidence)
+		}
+		if !reflect.DeepEqual(result.Suggestions, test.suggestions) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) suggestions = %q, expected %q", test.query, result.Suggestions, test.suggestions)
+		}
+	}
+}
+
+func TestInterpretNaturalLanguageQueryFailures(t *testing.T) {
+	tests := []struct {
+		query       string
+		ignored     []string
+		suggestions []string
+	}{
+		{"palindrmes", []string{"palindrmes"}, []string{"palindromes"}},
+		{"strngs contians the letter q", []string{"strngs", "contians", "letter", "q"}, []string{"strings contains the letter q"}},
+		{"blah blah", []string{"blah", "blah"}, nil},
+		{"not strings", []string{"not"}, nil},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.InterpretNaturalLanguageQuery(test.query)
+		if err == nil {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) expected error, got nil", test.query)
+			continue
+		}
+		if result.Confidence != 0 || len(result.Spans) != 0 {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) = %+v, expected no spans and confidence 0", test.query, result)
+		}
+		if !reflect.DeepEqual(result.IgnoredWords, test.ignored) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) ignored = %q, expected %q", test.query, result.IgnoredWords, test.ignored)
+		}
+		if !reflect.DeepEqual(result.Suggestions, test.suggestions) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) suggestions = %q, expected %q", test.query, result.Suggestions, test.suggestions)
+		}
+	}
+}
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"Strings with Twenty  blorps!", "strings with # blorps"},
+		{"strings with 3 blorps", "strings with # blorps"},
+		{"Chaînes SANS la lettre 'Z'", "chaines sans la lettre 'Z'"},
+		{"strings that don't glitter", "strings that do not glitter"},
+		{"   ", ""},
+	}
+	for _, test := range tests {
+		if normalized := helpers.NormalizeQuery(test.query); normalized != test.expected {
+			t.Errorf("NormalizeQuery(%q) = %q, expected %q", test.query, normalized, test.expected)
+		}
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	type m = map[string]interface{}
+	type l = []interface{}
+	tests := []struct {
+		filters  m
+		expected string
+	}{
+		{m{"is_palindrome": true, "word_count": 1, "min_length": 5}, "single-word palindromic strings longer than 4 characters"},
+		{m{"is_palindrome": false, "contains_character": "Q"}, "non-palindromic strings containing 'Q'"},
+		{m{"word_count": 3, "min_length": 3, "max_length": 9, "contains_character": "7"}, "3-word strings between 3 and 9 characters long and containing the digit 7"},
+		{m{"max_length": 0}, "strings shorter than 1 character"},
+		{m{"min_length": 10, "max_length": 3}, "strings longer than 9 characters and shorter than 4 characters"},
+		{m{"q": "word_count != 2"}, "strings that do not have exactly 2 words"},
+		{m{"is_palindrome": true, "or": l{m{"contains_character": "a"}, m{"contains_character": "b"}}}, "palindromic strings containing the letter a or b"},
+		{m{"or": l{m{"word_count": 2}, m{"min_length": 11}}}, "2-word strings or strings longer than 10 characters"},
+		{m{"not": m{"min_length": 3, "max_length": 5}}, "strings not between 3 and 5 characters long"},
+		// Shapes the parser does not build come back as equivalent alternatives
+		{m{"q": "NOT (is_palindrome AND length > 3)"}, "strings that are not palindromes or strings at most 3 characters long"},
+	}
+
+	for _, test := range tests {
+		tree, err := helpers.FilterTreeFromMap(test.filters)
+		if err != nil {
+			t.Fatalf("FilterTreeFromMap(%v) unexpected error: %v", test.filters, err)
+		}
+		result, ok := tree.CanonicalQuery()
+		if !ok || result != test.expected {
+			t.Errorf("CanonicalQuery(%v) = %q, %v, expected %q", test.filters, result, ok, test.expected)
+		}
+	}
+
+	// English has no words for these
+	for _, filters := range []m{{}, {"contains_character": " "}, {"q": "contains('ab')"}, {"q": "unique_characters > 3"}} {
+		tree, err := helpers.FilterTreeFromMap(filters)
+		if err != nil {
+			t.Fatalf("FilterTreeFromMap(%v) unexpected error: %v", filters, err)
+		}
+		if result, ok := tree.CanonicalQuery(); ok {
+			t.Errorf("CanonicalQuery(%v) = %q, expected no rendering", filters, result)
+		}
+	}
+}
+
+// Parsing the canonical English of a parsed query gives back the same
+// filters
+func TestCanonicalQueryRoundTrip(t *testing.T) {
+	for _, query := range []string{
+		"all single word palindromic strings",
+		"strings of exactly twenty-three characters",
+		"strings no more than one hundred and twenty characters long",
+		"5-letter palindromes",
+		"palindromes with at most five letters",
+		"strings with 3 or more words",
+		"strings between 2 and 4 words",
+		"multi-word strings",
+		"strings containing 'Q'",
+		"strings containing the letter é",
+		"strings that contain the last vowel",
+		"palindromes containing a and b",
+		"strings containing the letters x, y and z",
+		"strings that aren't palindromes and have at least 5 characters",
+		"strings that contain z but not the letter y",
+		"strings without a and b",
+		"strings not exactly 5 characters",
+		"strings not between 3 and 5 characters long",
+		"two word strings with one word",
+		"palindromic non-palindromes",
+		"palindromes or strings containing the letter q",
+		"single word palindromes or strings longer than 10 characters",
+		"palindromes containing a or b",
+		"strings with 2 or 3 words",
+		"neither palindromes nor strings with the letter z",
+		"strings without a or b",
+		"strings with 1 or 2 words that contain x or y",
+		"palindromes with 3 or 5 letters",
+		"strings with a prime number of characters",
+		"palindromes whose length is not a perfect number",
+		"strings with an even number of words",
+		"strings with an armstrong number of unique characters",
+		"strings longer than 10 bytes",
+		"strings with between 5 and 20 bytes",
+		"palindromes with at most 4 runes or a prime number of bytes",
+	} {
+		tree, err := helpers.ParseNaturalLanguageFilter(query)
+		if err != nil {
+			t.Fatalf("ParseNaturalLanguageFilter(%q) unexpected error: %v", query, err)
+		}
+		canonical, ok := tree.CanonicalQuery()
+		if !ok {
+			t.Errorf("CanonicalQuery() for %q has no rendering", query)
+			continue
+		}
+		result, err := helpers.ParseNaturalLanguageQuery(canonical)
+		if err != nil {
+			t.Errorf("ParseNaturalLanguageQuery(%q) from %q unexpected error: %v", canonical, query, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tree.ToMap()) {
+			t.Errorf("%q rendered as %q, which parses to %v, expected %v", query, canonical, result, tree.ToMap())
+		}
+	}
+}
+
 func TestHasConflictingFilters(t *testing.T) {
 	tests := []struct {
 		filters  map[string]interface{}
@@ -147,6 +535,70 @@ func TestHasConflictingFilters(t *testing.T) {
 			},
 			false,
 		},
+		{
+			// Only one alternative is contradictory
+			map[string]interface{}{
+				"or": []interface{}{
+					map[string]interface{}{"min_length": 10, "max_length": 5},
+					map[string]interface{}{"is_palindrome": true},
+				},
+			},
+			false,
+		},
+		{
+			// Every alternative is contradictory
+			map[string]interface{}{
+				"word_count": 3,
+				"or": []interface{}{
+					map[string]interface{}{"max_length": 4},
+					map[string]interface{}{"and": []interface{}{map[string]interface{}{"word_count": 2}}},
+				},
+			},
+			true,
+		},
+		{
+			map[string]interface{}{
+				"contains_character": "z",
+				"not":                map[string]interface{}{"contains_character": "z"},
+			},
+			true,
+		},
+		{
+			// NOT (length < 3 OR length > 5) leaves lengths 3 to 5
+			map[string]interface{}{
+				"max_length": 5,
+				"not": map[string]interface{}{
+					"or": []interface{}{
+						map[string]interface{}{"max_length": 2},
+						map[string]interface{}{"min_length": 6},
+					},
+				},
+			},
+			false,
+		},
+		{
+			map[string]interface{}{
+				"is_palindrome": false,
+				"q":             "length BETWEEN 0 AND 1",
+			},
+			true,
+		},
+		{map[string]interface{}{"q": "length IS even AND length IS odd"}, true},
+		{map[string]interface{}{"min_length": 4, "max_length": 4, "q": "length IS prime"}, true},
+		{map[string]interface{}{"q": "word_count BETWEEN 4 AND 6 AND word_count IS perfect"}, false},
+		{map[string]interface{}{"q": "word_count BETWEEN 7 AND 27 AND word_count IS perfect"}, true},
+		// 2 is the only even prime
+		{map[string]interface{}{"q": "length IS prime AND length IS even"}, false},
+		{map[string]interface{}{"min_length": 3, "max_length": 100, "q": "length IS prime AND length IS even"}, true},
+		{map[string]interface{}{"min_length": 3, "q": "length IS prime AND length IS even"}, true},
+		// Perfect numbers are decided from the few an int holds, all even
+		{map[string]interface{}{"q": "length IS perfect AND length IS odd"}, true},
+		{map[string]interface{}{"q": "word_count IS perfect AND word_count IS prime"}, true},
+		{map[string]interface{}{"min_length": 100000, "q": "length IS perfect"}, false},
+		// A grapheme holds at least one rune and a rune at least one byte
+		{map[string]interface{}{"min_length": 6, "q": "byte_length <= 5"}, true},
+		{map[string]interface{}{"q": "rune_count > 8 AND byte_length < 8"}, true},
+		{map[string]interface{}{"min_length": 3, "q": "byte_length <= 12 AND rune_count >= 3"}, false},
 	}
 
 	for _, test := range tests {
@@ -160,11 +612,11 @@ func TestHasConflictingFilters(t *testing.T) {
 func TestApplyFilters(t *testing.T) {
 	// Create test data
 	data := []helpers.Response{
-		{Value: "racecar", Properties: helpers.PropertiesMap{IsPalindrome: true, WordCount: 1}},
-		{Value: "hello world", Properties: helpers.PropertiesMap{IsPalindrome: false, WordCount: 2}},
-		{Value: "a", Properties: helpers.PropertiesMap{IsPalindrome: true, WordCount: 1}},
-		{Value: "abba", Properties: helpers.PropertiesMap{IsPalindrome: true, WordCount: 1}},
-		{Value: "long string here", Properties: helpers.PropertiesMap{IsPalindrome: false, WordCount: 3}},
+		{Value: "racecar", Properties: helpers.PropertiesMap{Length: 7, IsPalindrome: true, WordCount: 1}},
+		{Value: "hello world", Properties: helpers.PropertiesMap{Length: 11, IsPalindrome: false, WordCount: 2}},
+		{Value: "a", Properties: helpers.PropertiesMap{Length: 1, IsPalindrome: true, WordCount: 1}},
+		{Value: "abba", Properties: helpers.PropertiesMap{Length: 4, IsPalindrome: true, WordCount: 1}},
+		{Value: "long string here", Properties: helpers.PropertiesMap{Length: 16, IsPalindrome: false, WordCount: 3}},
 	}
 
 	tests := []struct {
@@ -222,6 +674,50 @@ func TestApplyFilters(t *testing.T) {
 			},
 			0,
 		},
+		{
+			"filter expression",
+			map[string]interface{}{
+				"is_palindrome": false,
+				"q":             "word_count >= 2 AND NOT contains('h')",
+			},
+			0,
+		},
+		{
+			"filter expression only",
+			map[string]interface{}{
+				"q": "word_count >= 2",
+			},
+			2, // hello world, long string here
+		},
+		{
+			"alternatives",
+			map[string]interface{}{
+				"or": []interface{}{
+					map[string]interface{}{"word_count": 3},
+					map[string]interface{}{"is_palindrome": true, "max_length": 1},
+				},
+			},
+			2, // a, long string here
+		},
+		{
+			"negated alternatives",
+			map[string]interface{}{
+				"not": map[string]interface{}{
+					"or": []interface{}{
+						map[string]interface{}{"contains_character": "a"},
+						map[string]interface{}{"contains_character": "o"},
+					},
+				},
+			},
+			0,
+		},
+		{
+			"malformed tree",
+			map[string]interface{}{
+				"or": "palindromes",
+			},
+			0,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	utils "hng/step0/util"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		n          int
+		prime      bool
+		perfect    bool
+		properties []string
+		digitSum   int
+	}{
+		{371, false, false, []string{"armstrong", "odd"}, 11},
+		{28, false, true, []string{"even"}, 10},
+		{7, true, false, []string{"armstrong", "odd"}, 7},
+		{0, false, false, []string{"armstrong", "even"}, 0},
+		{1, false, false, []string{"armstrong", "odd"}, 1},
+		{100, false, false, []string{"even"}, 1},
+		// Negative numbers keep the sign of their digit sum
+		{-371, false, false, []string{"odd"}, -11},
+		{-7, false, false, []string{"odd"}, -7},
+	}
+
+	for _, test := range tests {
+		c, err := utils.Classify(context.Background(), big.NewInt(int64(test.n)))
+		if err != nil || c.Number.Int64() != int64(test.n) || c.IsPrime != test.prime || c.IsPerfect != test.perfect || c.DigitSum != test.digitSum {
+			t.Errorf("Classify(%d) = %+v", test.n, c)
+		}
+		if !reflect.DeepEqual(c.Properties, test.properties) {
+			t.Errorf("Classify(%d).Properties = %v, expected %v", test.n, c.Properties, test.properties)
+		}
+	}
+}
+
+func TestFactProviders(t *testing.T) {
+	tests := []struct {
+		n        int
+		expected string
+	}{
+		{371, "371 is an Armstrong number because 3^3 + 7^3 + 1^3 = 371"},
+		{28, "28 is a perfect number: it is the sum of its proper divisors"},
+		{13, "13 is a prime number: it has no divisors but 1 and itself"},
+		{42, "42 is an even number whose digits add up to 6"},
+	}
+	for _, test := range tests {
+		c, _ := utils.Classify(context.Background(), big.NewInt(int64(test.n)))
+		if fact, err := (utils.LocalFacts{}).Fact(context.Background(), c); err != nil || fact != test.expected {
+			t.Errorf("LocalFacts.Fact(%d) = %q, %v, expected %q", test.n, fact, err, test.expected)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/42/math" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("42 is the answer.\n"))
+	}))
+	defer server.Close()
+
+	api := utils.NumbersAPI{BaseURL: server.URL}
+	if fact, err := api.Fact(context.Background(), utils.Classification{Number: big.NewInt(42)}); err != nil || fact != "42 is the answer." {
+		t.Errorf("NumbersAPI.Fact(42) = %q, %v", fact, err)
+	}
+	if fact, err := api.Fact(context.Background(), utils.Classification{Number: big.NewInt(7)}); err == nil {
+		t.Errorf("NumbersAPI.Fact(7) = %q, expected an error for a 404", fact)
+	}
+}
+
+func TestClassifyBig(t *testing.T) {
+	mersenne := func(p uint) *big.Int {
+		m := new(big.Int).Lsh(big.NewInt(1), p)
+		return m.Sub(m, big.NewInt(1))
+	}
+	perfect := func(p uint) *big.Int { return new(big.Int).Lsh(mersenne(p), p-1) }
+	parse := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 10)
+		return n
+	}
+
+	tests := []struct {
+		name      string
+		n         *big.Int
+		prime     bool
+		perfect   bool
+		armstrong bool
+	}{
+		{"Carmichael number", big.NewInt(561), false, false, false},
+		{"strong pseudoprime to base 2", big.NewInt(2047), false, false, false},
+		{"strong pseudoprime to bases 2 to 7", big.NewInt(3215031751), false, false, false},
+		{"largest int64 prime", big.NewInt(9223372036854775783), true, false, false},
+		{"M521, 157 digits", mersenne(521), true, false, false},
+		{"M521 + 2", new(big.Int).Add(mersenne(521), big.NewInt(2)), false, false, false},
+		{"M607 squared", new(big.Int).Mul(mersenne(607), mersenne(607)), false, false, false},
+		{"perfect number from M127", perfect(127), false, true, false},
+		{"perfect number from M607, 366 digits", perfect(607), false, true, false},
+		{"2^10 * (2^11 - 1), 2047 is not prime", perfect(11), false, false, false},
+		{"largest Armstrong number, 39 digits", parse("115132219018763992565095597973971522401"), false, false, true},
+		{"Armstrong number plus one", parse("115132219018763992565095597973971522402"), false, false, false},
+		{"20-digit number", parse("12345678901234567890"), false, false, false},
+	}
+
+	for _, test := range tests {
+		c, err := utils.Classify(context.Background(), test.n)
+		if err != nil {
+			t.Fatalf("Classify(%s) unexpected error: %v", test.name, err)
+		}
+		armstrong := len(c.Properties) == 2 && c.Properties[0] == "armstrong"
+		if c.IsPrime != test.prime || c.IsPerfect != test.perfect || armstrong != test.armstrong {
+			t.Errorf("Classify(%s) = prime %v, perfect %v, properties %v", test.name, c.IsPrime, c.IsPerfect, c.Properties)
+		}
+	}
+
+	// The int helpers are exact up to the top of a 64-bit int
+	topArmstrong, topPrime, topPerfect := int64(4679307774), int64(9223372036854775783), int64(2305843008139952128)
+	if strconv.IntSize == 64 {
+		if !utils.IsArmstrong(int(topArmstrong)) || utils.IsArmstrong(int(topArmstrong+1)) {
+			t.Errorf("IsArmstrong is wrong around %d", topArmstrong)
+		}
+		if !utils.IsPrime(int(topPrime)) || !utils.IsPerfect(int(topPerfect)) {
+			t.Errorf("IsPrime or IsPerfect is wrong near the top of the int range")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := utils.Classify(ctx, mersenne(607)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Classify with a done context returned %v, expected context.Canceled", err)
+	}
+}
+
+func TestAnalyzeDivisors(t *testing.T) {
+	parse := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString(s, 10)
+		return n
+	}
+	tests := []struct {
+		n             *big.Int
+		factorization string
+		divisorCount  string
+		divisorSum    string
+		abundance     string
+		totient       string
+		squarefree    bool
+	}{
+		{big.NewInt(1), "1", "1", "1", "deficient", "1", true},
+		{big.NewInt(2), "2", "2", "3", "deficient", "1", true},
+		{big.NewInt(12), "2^2 × 3", "6", "28", "abundant", "4", false},
+		{big.NewInt(28), "2^2 × 7", "6", "56", "perfect", "12", false},
+		{big.NewInt(30), "2 × 3 × 5", "8", "72", "abundant", "8", true},
+		{big.NewInt(360), "2^3 × 3^2 × 5", "24", "1170", "abundant", "96", false},
+		{big.NewInt(8128), "2^6 × 127", "14", "16256", "perfect", "4032", false},
+		{big.NewInt(600851475143), "71 × 839 × 1471 × 6857", "16", "610544148480", "deficient", "591194251200", true},
+		// Primes above the trial division limit need Pollard's rho
+		{big.NewInt(10007 * 10009), "10007 × 10009", "4", "100180080", "deficient", "100140048", true},
+		{big.NewInt(10007 * 10007 * 10007), "10007^3", "4", "1002201620400", "deficient", "1002001330294", false},
+		// 2^67 - 1, Cole's factorization
+		{parse("147573952589676412927"), "193707721 × 761838257287", "4", "147573953351708377936", "deficient", "147573951827644447920", true},
+		// (2^31 - 1)(2^61 - 1)
+		{parse("4951760154835678088235319297"), "2147483647 × 2305843009213693951", "4", "4951760157141521099596496896", "deficient", "4951760152529835076874141700", true},
+	}
+
+	for _, test := range tests {
+		a, err := utils.AnalyzeDivisors(context.Background(), test.n)
+		if err != nil {
+			t.Fatalf("AnalyzeDivisors(%s) unexpected error: %v", test.n, err)
+		}
+		if a.Factorization != test.factorization || a.DivisorCount.String() != test.divisorCount || a.DivisorSum.String() != test.divisorSum ||
+			a.Abundance != test.abundance || a.Totient.String() != test.totient || a.IsSquarefree != test.squarefree {
+			t.Errorf("AnalyzeDivisors(%s) = %s, %s divisors summing to %s, %s, totient %s, squarefree %v",
+				test.n, a.Factorization, a.DivisorCount, a.DivisorSum, a.Abundance, a.Totient, a.IsSquarefree)
+		}
+	}
+
+	if _, err := utils.Factorize(context.Background(), big.NewInt(0)); err == nil {
+		t.Errorf("Factorize(0) expected an error")
+	}
+	// A product of two 30-digit primes is out of reach; the deadline stops it
+	semiprime := new(big.Int).Mul(parse("618970019642690137449562111"), parse("162259276829213363391578010288127"))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := utils.Factorize(ctx, semiprime); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Factorize of a large semiprime returned %v, expected context.DeadlineExceeded", err)
+	}
+}
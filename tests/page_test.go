@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+func responsesFor(values ...string) []helpers.Response {
+	items := make([]helpers.Response, len(values))
+	for i, value := range values {
+		handler := helpers.StringApiHandler{String: value}
+		items[i] = handler.GetString()
+	}
+	return items
+}
+
+func pageValues(items []helpers.Response) []string {
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = item.Value
+	}
+	return values
+}
+
+func TestParseSort(t *testing.T) {
+	keys, err := store.ParseSort("length,-created_at,word_count")
+	if err != nil {
+		t.Fatalf("ParseSort unexpected error: %v", err)
+	}
+	expected := []store.SortKey{{Field: "length"}, {Field: "created_at", Desc: true}, {Field: "word_count"}}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("ParseSort = %v, expected %v", keys, expected)
+	}
+
+	for _, spec := range []string{"colour", "length,,value", "-character_frequency_map", "word_analysis"} {
+		if _, err := store.ParseSort(spec); err == nil {
+			t.Errorf("ParseSort(%q) expected error, got nil", spec)
+		}
+	}
+}
+
+func TestPaginateMultiKeySort(t *testing.T) {
+	items := responsesFor("bb", "a", "ccc", "aa", "b", "abc", "hello world")
+	keys, _ := store.ParseSort("length,-value")
+
+	var all []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, next, err := store.Paginate(items, store.PageRequest{Sort: keys, Limit: 3, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("Paginate unexpected error: %v", err)
+		}
+		if len(page) > 3 {
+			t.Fatalf("Page has %d items, expected at most 3", len(page))
+		}
+		all = append(all, pageValues(page)...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	expected := []string{"b", "a", "bb", "aa", "ccc", "abc", "hello world"}
+	if fmt.Sprint(all) != fmt.Sprint(expected) {
+		t.Errorf("Paginated order %v, expected %v", all, expected)
+	}
+}
+
+func TestPaginateByAnyProperty(t *testing.T) {
+	useTestAnalyzers(t)
+	items := make([]helpers.Response, 0, 4)
+	for _, value := range []string{"Big Red Dog", "tiny cat", "A Quick Pangram", "supercalifragilistic word"} {
+		handler := helpers.StringApiHandler{String: value}
+		items = append(items, handler.GetStringFields([]string{"length"}))
+	}
+
+	tests := []struct {
+		spec     string
+		expected string
+	}{
+		// A registered analyzer left out of the stored properties
+		{"-capital_count,value", "[A Quick Pangram Big Red Dog supercalifragilistic word tiny cat]"},
+		// Word analysis fields, computed for the sort only
+		{"longest_word_length", "[Big Red Dog tiny cat A Quick Pangram supercalifragilistic word]"},
+		{"-distinct_words,value", "[A Quick Pangram Big Red Dog supercalifragilistic word tiny cat]"},
+		{"is_palindrome,palindrome_mode,value", "[A Quick Pangram Big Red Dog supercalifragilistic word tiny cat]"},
+	}
+	for _, test := range tests {
+		keys, err := store.ParseSort(test.spec)
+		if err != nil {
+			t.Fatalf("ParseSort(%q) unexpected error: %v", test.spec, err)
+		}
+		var all []string
+		cursor := ""
+		for {
+			page, next, err := store.Paginate(items, store.PageRequest{Sort: keys, Limit: 1, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("Paginate(%q) unexpected error: %v", test.spec, err)
+			}
+			all = append(all, pageValues(page)...)
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		if fmt.Sprint(all) != test.expected {
+			t.Errorf("Sort %q paginated %v, expected %s", test.spec, all, test.expected)
+		}
+	}
+	for _, item := range items {
+		if item.Properties.WordAnalysis != nil || item.Properties.Extra != nil {
+			t.Errorf("Sorting kept the properties it computed: %+v", item.Properties)
+		}
+	}
+}
+
+func TestPaginateIsStableAcrossWrites(t *testing.T) {
+	items := responsesFor("a", "bb", "ccc", "dddd", "eeeee", "ffffff")
+	keys, _ := store.ParseSort("length")
+
+	first, next, _ := store.Paginate(items, store.PageRequest{Sort: keys, Limit: 3})
+	if fmt.Sprint(pageValues(first)) != "[a bb ccc]" {
+		t.Fatalf("First page %v", pageValues(first))
+	}
+
+	// Delete an item from the first page and insert one before the boundary
+	changed := append(responsesFor("xy", "zzzzzzz"), items[1:]...)
+	second, _, err := store.Paginate(changed, store.PageRequest{Sort: keys, Limit: 3, Cursor: next})
+	if err != nil {
+		t.Fatalf("Paginate unexpected error: %v", err)
+	}
+	if fmt.Sprint(pageValues(second)) != "[dddd eeeee ffffff]" {
+		t.Errorf("Second page after writes %v, expected [dddd eeeee ffffff]", pageValues(second))
+	}
+}
+
+func TestPaginateLocaleCollation(t *testing.T) {
+	items := responsesFor("zebra", "äpple", "apple", "Zulu")
+	keys, _ := store.ParseSort("value")
+
+	tests := []struct {
+		locale   language.Tag
+		expected string
+	}{
+		{language.English, "[apple äpple zebra Zulu]"},
+		{language.Swedish, "[apple zebra Zulu äpple]"},
+	}
+	for _, test := range tests {
+		page, _, _ := store.Paginate(items, store.PageRequest{Sort: keys, Locale: test.locale})
+		if fmt.Sprint(pageValues(page)) != test.expected {
+			t.Errorf("Locale %s order %v, expected %s", test.locale, pageValues(page), test.expected)
+		}
+	}
+}
+
+func TestPaginateRejectsForeignCursor(t *testing.T) {
+	items := responsesFor("a", "bb", "ccc")
+	byLength, _ := store.ParseSort("length")
+	byValue, _ := store.ParseSort("value")
+
+	_, next, _ := store.Paginate(items, store.PageRequest{Sort: byLength, Limit: 1})
+	if _, _, err := store.Paginate(items, store.PageRequest{Sort: byValue, Cursor: next}); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("Cursor reused with another sort returned %v, expected ErrInvalidCursor", err)
+	}
+	if _, _, err := store.Paginate(items, store.PageRequest{Sort: byLength, Cursor: "not-a-cursor"}); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("Garbage cursor returned %v, expected ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	helpers "hng/step0/helpers"
+	"testing"
+)
+
+func TestParsePalindromeMode(t *testing.T) {
+	tests := []struct {
+		input     string
+		canonical string
+	}{
+		{"default", "case,whitespace"},
+		{"none", "none"},
+		{"punctuation, CASE", "case,punctuation"},
+		{"digits,whitespace,diacritics", "diacritics,whitespace,digits"},
+		{"case:tr", "case:tr"},
+		{"case:tr-TR", "case:tr"},
+		{"vowels", ""},
+		{"case:zz-!", ""},
+		{"digits:tr", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		mode, err := helpers.ParsePalindromeMode(test.input)
+		if test.canonical == "" {
+			if err == nil {
+				t.Errorf("ParsePalindromeMode(%q) = %v, expected error", test.input, mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePalindromeMode(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if mode.String() != test.canonical {
+			t.Errorf("ParsePalindromeMode(%q) = %q, expected %q", test.input, mode.String(), test.canonical)
+		}
+		if again, err := helpers.ParsePalindromeMode(mode.String()); err != nil || again != mode {
+			t.Errorf("Canonical mode %q did not round-trip: %v, %v", mode.String(), again, err)
+		}
+	}
+}
+
+func TestPalindromeModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		input    string
+		expected bool
+	}{
+		{"default", "A man, a plan, a canal: Panama!", false},
+		{"case,whitespace,punctuation", "A man, a plan, a canal: Panama!", true},
+		{"default", "never\todd\nor even", true},
+		{"none", "Racecar", false},
+		{"none", "race car", false},
+		{"case", "Racecar", true},
+		{"case", "Straßssarts", true}, // ß folds to ss
+		// Turkish: I lowercases to dotless ı, and İ to i
+		{"case", "Iı", false},
+		{"case:tr", "Iı", true},
+		{"case", "İi", false},
+		{"case:tr", "İi", true},
+		{"case", "Ésè", false},
+		{"case,diacritics", "Ésè", true},
+		{"diacritics", "été", true},
+		{"diacritics", "مَم", true}, // Arabic fatha is a diacritic
+		{"none", "مَم", false},
+		{"digits", "Call 12-21 now", true},
+		{"digits", "1a2b3", false},
+		{"digits", "٣x٣", true}, // Arabic-Indic digits
+		{"digits", "no digits", true},
+		{"whitespace,punctuation", "👍, 👍!", true},
+	}
+
+	for _, test := range tests {
+		mode, err := helpers.ParsePalindromeMode(test.mode)
+		if err != nil {
+			t.Fatalf("ParsePalindromeMode(%q) unexpected error: %v", test.mode, err)
+		}
+		if result := mode.IsPalindrome(test.input); result != test.expected {
+			t.Errorf("IsPalindrome(%q) in mode %s = %v, expected %v", test.input, mode, result, test.expected)
+		}
+	}
+}
+
+func TestAnalyzeRecordsPalindromeMode(t *testing.T) {
+	handler := helpers.StringApiHandler{String: "Was it a car, or a cat I saw?"}
+	if p := handler.Analyze(); p.IsPalindrome || p.PalindromeMode != "case,whitespace" {
+		t.Errorf("Analyze() = %v in mode %q, expected false in mode case,whitespace", p.IsPalindrome, p.PalindromeMode)
+	}
+
+	mode, _ := helpers.ParsePalindromeMode("case,whitespace,punctuation")
+	handler.PalindromeMode = &mode
+	if p := handler.Analyze(); !p.IsPalindrome || p.PalindromeMode != "case,whitespace,punctuation" {
+		t.Errorf("Analyze() = %v in mode %q, expected true in mode case,whitespace,punctuation", p.IsPalindrome, p.PalindromeMode)
+	}
+}
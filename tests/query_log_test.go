@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"hng/step0/store"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryLog(t *testing.T) {
+	log := store.NewQueryLog(4)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, outcome := range []string{store.QueryParsed, store.QueryUnparsed, store.QueryParsed, store.QueryUnparsed, store.QueryConflicting, store.QueryUnparsed} {
+		log.Record(store.QueryEvent{
+			Time:       start.Add(time.Duration(i) * time.Minute),
+			Query:      "query " + outcome,
+			Normalized: outcome,
+			Outcome:    outcome,
+			ParseTime:  time.Duration(i+1) * time.Millisecond,
+		})
+	}
+
+	// The oldest two events were evicted
+	events := log.Since(time.Time{})
+	if len(events) != 4 || !events[0].Time.Equal(start.Add(2*time.Minute)) || !events[3].Time.Equal(start.Add(5*time.Minute)) {
+		t.Fatalf("Since() = %+v, expected the last 4 events oldest first", events)
+	}
+	if events := log.Since(start.Add(4 * time.Minute)); len(events) != 2 {
+		t.Errorf("Since() returned %d events, expected 2", len(events))
+	}
+
+	summary := log.Summarize(start, start.Add(6*time.Minute), 3*time.Minute, 10)
+	if summary.Total != 4 || summary.Parsed != 1 || summary.Unparsed != 2 || summary.Conflicting != 1 || summary.SuccessRate != 0.25 {
+		t.Errorf("Summarize() stats = %+v", summary.QueryStats)
+	}
+	if summary.AvgParseTime != 4.5 || summary.MaxParseTime != 6 {
+		t.Errorf("Summarize() parse times = %v avg, %v max, expected 4.5 and 6", summary.AvgParseTime, summary.MaxParseTime)
+	}
+	if len(summary.Buckets) != 2 || summary.Buckets[0].Total != 1 || summary.Buckets[1].Total != 3 || !summary.Buckets[1].Start.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Summarize() buckets = %+v", summary.Buckets)
+	}
+	expected := []store.QueryPhrasing{{Normalized: store.QueryUnparsed, Count: 2, Example: "query unparsed", LastSeen: start.Add(5 * time.Minute)}}
+	if !reflect.DeepEqual(summary.TopUnparsed, expected) {
+		t.Errorf("Summarize() top unparsed = %+v, expected %+v", summary.TopUnparsed, expected)
+	}
+	if len(summary.TopConflicting) != 1 {
+		t.Errorf("Summarize() top conflicting = %+v", summary.TopConflicting)
+	}
+
+	// An empty window still has its buckets
+	empty := log.Summarize(start.Add(time.Hour), start.Add(2*time.Hour), 30*time.Minute, 10)
+	if empty.Total != 0 || empty.SuccessRate != 0 || len(empty.Buckets) != 2 || empty.TopUnparsed == nil {
+		t.Errorf("Summarize() of an empty window = %+v", empty)
+	}
+}
+
+func TestQueryLogConcurrentAccess(t *testing.T) {
+	log := store.NewQueryLog(100)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				log.Record(store.QueryEvent{Time: time.Now(), Outcome: store.QueryParsed})
+				log.Summarize(time.Now().Add(-time.Minute), time.Now(), time.Second, 5)
+			}
+		}()
+	}
+	wg.Wait()
+	if events := log.Since(time.Time{}); len(events) != 100 {
+		t.Errorf("log holds %d events, expected its capacity of 100", len(events))
+	}
+}
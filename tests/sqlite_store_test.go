@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"errors"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"path/filepath"
+	"testing"
+)
+
+func openTestSQLiteStore(t *testing.T, path string) *store.SQLiteStore {
+	t.Helper()
+	s, err := store.OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "strings.db")
+	s := openTestSQLiteStore(t, path)
+
+	createValues(t, s, "racecar", "hello world", "abba")
+
+	duplicate := helpers.StringApiHandler{String: "abba"}
+	if err := s.Create(duplicate.GetString()); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("Create duplicate returned %v, expected ErrAlreadyExists", err)
+	}
+
+	response, err := s.GetByValue("hello world")
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	expected := helpers.StringApiHandler{String: "hello world"}
+	if response.Properties.WordCount != 2 || response.Properties.CharacterFrequencyMap["l"] != 3 ||
+		response.ID != expected.Analyze().Sha256Hash {
+		t.Errorf("GetByValue returned unexpected properties: %+v", response)
+	}
+	if _, err := s.GetByID(response.ID); err != nil {
+		t.Errorf("GetByID unexpected error: %v", err)
+	}
+
+	if err := s.Delete("racecar"); err != nil {
+		t.Errorf("Delete unexpected error: %v", err)
+	}
+	if err := s.Delete("racecar"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete returned %v, expected ErrNotFound", err)
+	}
+	s.Close()
+
+	// Reopening runs no migrations twice and keeps the data
+	reopened := openTestSQLiteStore(t, path)
+	defer reopened.Close()
+	expectValues(t, reopened, "hello world", "abba")
+	if version, err := reopened.SchemaVersion(); err != nil || version != 6 {
+		t.Errorf("SchemaVersion() = %d, %v, expected 6", version, err)
+	}
+}
+
+func TestSQLiteStoreQueryMatchesMemoryStore(t *testing.T) {
+	sqlite := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer sqlite.Close()
+	memory := store.NewMemoryStore()
+
+	values := []string{"racecar", "hello world", "a", "abba", "long string here", "zebra crossing"}
+	createValues(t, sqlite, values...)
+	createValues(t, memory, values...)
+
+	yes, no := true, false
+	one, four, five := 1, 4, 5
+	tests := []struct {
+		name     string
+		criteria helpers.Filter
+		expected int
+	}{
+		{"no filters", helpers.Filter{}, 6},
+		{"palindromes", helpers.Filter{IsPalindrome: &yes}, 3},
+		{"non-palindromes", helpers.Filter{IsPalindrome: &no}, 3},
+		{"min length", helpers.Filter{MinLength: &five}, 4},
+		{"max length", helpers.Filter{MaxLength: &four}, 2},
+		{"word count", helpers.Filter{WordCount: &one}, 3},
+		{"contains character", helpers.Filter{ContainsCharacter: "z"}, 1},
+		{"combined", helpers.Filter{IsPalindrome: &yes, MinLength: &four, ContainsCharacter: "a"}, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fromSQL, err := store.Select(sqlite, test.criteria)
+			if err != nil {
+				t.Fatalf("Select unexpected error: %v", err)
+			}
+			fromMemory, _ := store.Select(memory, test.criteria)
+			if len(fromSQL) != test.expected || len(fromMemory) != test.expected {
+				t.Errorf("Select returned %d (sqlite) and %d (memory) results, expected %d",
+					len(fromSQL), len(fromMemory), test.expected)
+			}
+		})
+	}
+}
+
+func TestSQLiteStoreUnicode(t *testing.T) {
+	sqlite := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer sqlite.Close()
+	memory := store.NewMemoryStore()
+
+	values := []string{"e\u0301te\u0301", family}
+	createValues(t, sqlite, values...)
+	createValues(t, memory, values...)
+
+	response, err := sqlite.GetByValue(values[0])
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if p := response.Properties; p.Length != 3 || p.ByteLength != 7 || p.RuneCount != 5 || p.CharacterFrequencyMap["\u00E9"] != 2 {
+		t.Errorf("GetByValue returned unexpected properties: %+v", p)
+	}
+
+	two := 2
+	for _, criteria := range []helpers.Filter{{MinLength: &two}, {ContainsCharacter: "\u0301"}} {
+		fromSQL, err := store.Select(sqlite, criteria)
+		if err != nil {
+			t.Fatalf("Select unexpected error: %v", err)
+		}
+		fromMemory, _ := store.Select(memory, criteria)
+		if len(fromSQL) != 1 || len(fromMemory) != 1 {
+			t.Errorf("Select(%+v) returned %d (sqlite) and %d (memory) results, expected 1", criteria, len(fromSQL), len(fromMemory))
+		}
+	}
+}
+
+func TestSQLiteStoreResolveID(t *testing.T) {
+	s := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer s.Close()
+
+	first, second := idPrefixCollision(4)
+	createValues(t, s, first, second, "racecar")
+
+	var ambiguous *store.AmbiguousIDError
+	if _, err := store.ResolveID(s, helpers.CalculateSHA256(first)[:4]); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("ResolveID(shared prefix) returned %v, expected an ambiguity with 2 candidates", err)
+	}
+	if r, err := store.ResolveID(s, helpers.CalculateSHA256("racecar")[:8]); err != nil || r.Value != "racecar" {
+		t.Errorf("ResolveID(unique prefix) = %q, %v", r.Value, err)
+	}
+	if matches, _ := s.MatchIDPrefix("", 0); len(matches) != 3 {
+		t.Errorf("MatchIDPrefix(\"\") returned %d matches, expected 3", len(matches))
+	}
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	values := []string{"racecar", "hello world", "abba"}
+	for _, value := range values {
+		handler := helpers.StringApiHandler{String: value}
+		if err := s.Create(handler.GetString()); err != nil {
+			t.Fatalf("Create(%q) unexpected error: %v", value, err)
+		}
+	}
+
+	duplicate := helpers.StringApiHandler{String: "racecar"}
+	if err := s.Create(duplicate.GetString()); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("Create duplicate returned %v, expected ErrAlreadyExists", err)
+	}
+
+	if count, _ := s.Count(); count != len(values) {
+		t.Errorf("Count() = %d, expected %d", count, len(values))
+	}
+
+	response, err := s.GetByValue("hello world")
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if byID, err := s.GetByID(response.ID); err != nil || byID.Value != "hello world" {
+		t.Errorf("GetByID(%s) = %v, %v", response.ID, byID.Value, err)
+	}
+
+	if _, err := s.GetByValue("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetByValue(missing) returned %v, expected ErrNotFound", err)
+	}
+
+	if err := s.Delete("racecar"); err != nil {
+		t.Errorf("Delete unexpected error: %v", err)
+	}
+	if err := s.Delete("racecar"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete returned %v, expected ErrNotFound", err)
+	}
+
+	list, _ := s.List()
+	if len(list) != 2 || list[0].Value != "hello world" || list[1].Value != "abba" {
+		t.Errorf("List() returned unexpected items: %v", list)
+	}
+}
+
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	s := store.NewMemoryStore()
+
+	const workers = 64
+	const values = 50
+
+	var created atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < values; i++ {
+				handler := helpers.StringApiHandler{String: fmt.Sprintf("value %d", i)}
+				if err := s.Create(handler.GetString()); err == nil {
+					created.Add(1)
+				} else if !errors.Is(err, store.ErrAlreadyExists) {
+					t.Errorf("Create unexpected error: %v", err)
+				}
+				s.GetByValue(fmt.Sprintf("value %d", (i+w)%values))
+				s.List()
+				s.Count()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if created.Load() != values {
+		t.Errorf("Created %d strings, expected exactly %d", created.Load(), values)
+	}
+	if count, _ := s.Count(); count != values {
+		t.Errorf("Count() = %d, expected %d", count, values)
+	}
+
+	// Delete everything concurrently; each value must be removed exactly once
+	var deleted atomic.Int64
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < values; i++ {
+				if s.Delete(fmt.Sprintf("value %d", i)) == nil {
+					deleted.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if deleted.Load() != values {
+		t.Errorf("Deleted %d strings, expected exactly %d", deleted.Load(), values)
+	}
+	if count, _ := s.Count(); count != 0 {
+		t.Errorf("Count() = %d after deleting everything, expected 0", count)
+	}
+}
+
+func TestMemoryStoreMatchIDPrefix(t *testing.T) {
+	s := store.NewMemoryStore()
+	rng := rand.New(rand.NewSource(2))
+
+	ids := make(map[string]bool)
+	for i := 0; i < 2000; i++ {
+		response := helpers.StringApiHandler{String: fmt.Sprintf("value %d", rng.Int())}
+		r := response.GetString()
+		if s.Create(r) == nil {
+			ids[r.ID] = true
+		}
+	}
+
+	check := func(stage string) {
+		for _, prefix := range []string{"", "0", "a", "ab", "abc", "f00", "1234", "ffff"} {
+			expected := 0
+			for id := range ids {
+				if strings.HasPrefix(id, prefix) {
+					expected++
+				}
+			}
+			matches, err := s.MatchIDPrefix(prefix, 0)
+			if err != nil {
+				t.Fatalf("MatchIDPrefix unexpected error: %v", err)
+			}
+			if len(matches) != expected {
+				t.Errorf("%s: MatchIDPrefix(%q) returned %d matches, expected %d", stage, prefix, len(matches), expected)
+			}
+			for i, match := range matches {
+				if !strings.HasPrefix(match.ID, prefix) || (i > 0 && matches[i-1].ID >= match.ID) {
+					t.Errorf("%s: MatchIDPrefix(%q) returned %q out of order or not matching", stage, prefix, match.ID)
+				}
+			}
+			if limited, _ := s.MatchIDPrefix(prefix, 3); len(limited) != min(3, expected) {
+				t.Errorf("%s: MatchIDPrefix(%q, 3) returned %d matches", stage, prefix, len(limited))
+			}
+		}
+	}
+	check("after inserts")
+
+	items, _ := s.List()
+	for i, item := range items {
+		if i%3 != 0 {
+			s.Delete(item.Value)
+			delete(ids, item.ID)
+		}
+	}
+	check("after deletes")
+}
+
+func TestResolveID(t *testing.T) {
+	s := store.NewMemoryStore()
+	first, second := idPrefixCollision(4)
+	createValues(t, s, first, second)
+
+	a, _ := s.GetByValue(first)
+	b, _ := s.GetByValue(second)
+
+	if r, err := store.ResolveID(s, a.ID); err != nil || r.Value != first {
+		t.Errorf("ResolveID(full id) = %q, %v", r.Value, err)
+	}
+
+	var ambiguous *store.AmbiguousIDError
+	if _, err := store.ResolveID(s, a.ID[:4]); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("ResolveID(shared prefix) returned %v, expected an ambiguity with 2 candidates", err)
+	}
+
+	unique := a.ID[:commonIDPrefix(a.ID, b.ID)+1]
+	if r, err := store.ResolveID(s, unique); err != nil || r.Value != first {
+		t.Errorf("ResolveID(%q) = %q, %v", unique, r.Value, err)
+	}
+
+	if _, err := store.ResolveID(s, "zzzz"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ResolveID(unknown) returned %v, expected ErrNotFound", err)
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	helpers "hng/step0/helpers"
+	"reflect"
+	"testing"
+)
+
+const family = "👨‍👩‍👧" // man, ZWJ, woman, ZWJ, girl
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"ascii", "abc", []string{"a", "b", "c"}},
+		{"combining accents", "e\u0301te\u0301", []string{"e\u0301", "t", "e\u0301"}},
+		{"stacked marks", "a\u0301\u0323b", []string{"a\u0301\u0323", "b"}},
+		{"zwj family", family + "!", []string{family, "!"}},
+		{"skin tone modifier", "👍🏽👍", []string{"👍🏽", "👍"}},
+		{"flags pair up", "🇳🇬🇬🇳", []string{"🇳🇬", "🇬🇳"}},
+		{"odd regional indicator", "🇳🇬🇬", []string{"🇳🇬", "🇬"}},
+		{"keycap", "1\uFE0F\u20E3", []string{"1\uFE0F\u20E3"}},
+		{"crlf", "a\r\nb", []string{"a", "\r\n", "b"}},
+		{"hangul jamo", "한ᄀ", []string{"한", "ᄀ"}},
+		{"hebrew points", "שָׁלוֹם", []string{"שָׁ", "ל", "וֹ", "ם"}},
+		{"arabic harakat", "مَرْحَبًا", []string{"مَ", "رْ", "حَ", "بً", "ا"}},
+		{"arabic prepend", "\u0600\u0661", []string{"\u0600\u0661"}},
+		{"leading mark", "\u0301a", []string{"\u0301", "a"}},
+		{"devanagari conjuncts", "क्षत्रिय", []string{"क्ष", "त्रि", "य"}},
+		{"conjunct with zwj", "क्\u200Dष", []string{"क्\u200Dष"}},
+		{"emoji zwj sequence", "🐦\u200D⬛", []string{"🐦\u200D⬛"}},
+		{"empty", "", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := helpers.Graphemes(test.input)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("Graphemes(%q) = %q, expected %q", test.input, result, test.expected)
+			}
+		})
+	}
+}
+
+func TestUnicodeAnalyze(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		bytes        int
+		runes        int
+		graphemes    int
+		unique       int
+		isPalindrome bool
+	}{
+		{"precomposed", "été", 5, 3, 3, 2, true},
+		{"decomposed", "e\u0301te\u0301", 7, 5, 3, 2, true},
+		{"zwj emoji", family + "x" + family, 37, 11, 3, 2, true},
+		{"flags are not reversed runes", "🇳🇬🇬🇳", 16, 4, 2, 2, false},
+		{"hebrew", "אבא", 6, 3, 3, 2, true},
+		{"arabic", "ليل", 6, 3, 3, 2, true},
+		{"arabic with harakat", "مَرْحَبًا", 18, 9, 5, 5, false},
+		{"case folded but counted apart", "Ésé", 5, 3, 3, 3, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := helpers.StringApiHandler{String: test.input}
+			p := handler.Analyze()
+			if p.ByteLength != test.bytes || p.RuneCount != test.runes || p.Length != test.graphemes {
+				t.Errorf("Analyze(%q) counts %d bytes, %d runes and %d graphemes, expected %d, %d and %d",
+					test.input, p.ByteLength, p.RuneCount, p.Length, test.bytes, test.runes, test.graphemes)
+			}
+			if p.UniqueCharacters != test.unique {
+				t.Errorf("Analyze(%q).UniqueCharacters = %d, expected %d", test.input, p.UniqueCharacters, test.unique)
+			}
+			if p.IsPalindrome != test.isPalindrome {
+				t.Errorf("Analyze(%q).IsPalindrome = %v, expected %v", test.input, p.IsPalindrome, test.isPalindrome)
+			}
+		})
+	}
+}
+
+func TestCharacterFrequencyGraphemes(t *testing.T) {
+	// Precomposed and decomposed é count as the same character
+	result := helpers.CalculateCharacterFrequency("\u00E9te\u0301 " + family)
+	expected := map[string]int{"\u00E9": 2, "t": 1, " ": 1, family: 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("CalculateCharacterFrequency = %q, expected %q", result, expected)
+	}
+}
@@ -0,0 +1,279 @@
+package tests
+
+import (
+	"encoding/json"
+	helpers "hng/step0/helpers"
+	"hng/step0/store"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnicodeTokenizer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"hello world", []string{"hello", "world"}},
+		{"Don't stop, it's fine!", []string{"Don't", "stop", "it's", "fine"}},
+		{"pi is 3.14, not 1,000.", []string{"pi", "is", "3.14", "not", "1,000"}},
+		{"well-known 'quotes'", []string{"well", "known", "quotes"}},
+		{"café naïve", []string{"café", "naïve"}},
+		{"Привет, мир", []string{"Привет", "мир"}},
+		{"日本語です", []string{"日", "本", "語", "で", "す"}},
+		{"hi 👋 there", []string{"hi", "there"}},
+		{" ... ", nil},
+	}
+
+	for _, test := range tests {
+		if got := helpers.UnicodeTokenizer.Tokenize(test.input); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Tokenize(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestAnalyzeWords(t *testing.T) {
+	analysis := helpers.AnalyzeWords("The quick brown fox and the lazy dog")
+	expected := &helpers.WordAnalysis{
+		Tokenizer:          "unicode",
+		WordFrequencyMap:   map[string]int{"the": 2, "quick": 1, "brown": 1, "fox": 1, "and": 1, "lazy": 1, "dog": 1},
+		LongestWord:        "quick",
+		LongestWordLength:  5,
+		ShortestWord:       "The",
+		ShortestWordLength: 3,
+		AverageWordLength:  3.63,
+		DistinctWords:      7,
+		LexicalDiversity:   0.88,
+	}
+	if !reflect.DeepEqual(analysis, expected) {
+		t.Errorf("AnalyzeWords() = %+v, expected %+v", analysis, expected)
+	}
+
+	// Word lengths count grapheme clusters
+	if analysis := helpers.AnalyzeWords("été ok"); analysis.LongestWordLength != 3 || analysis.ShortestWord != "ok" {
+		t.Errorf("AnalyzeWords() with combining marks = %+v", analysis)
+	}
+	if analysis := helpers.AnalyzeWords("?!"); analysis.DistinctWords != 0 || analysis.WordFrequencyMap == nil || analysis.LexicalDiversity != 0 {
+		t.Errorf("AnalyzeWords() without words = %+v", analysis)
+	}
+}
+
+// useFieldsTokenizer makes the word analysis split words on whitespace
+func useFieldsTokenizer(t *testing.T) {
+	t.Helper()
+	fields, err := helpers.NewTokenizer("fields", strings.Fields)
+	if err != nil {
+		t.Fatalf("NewTokenizer unexpected error: %v", err)
+	}
+	if err := helpers.SetTokenizer(fields); err != nil {
+		t.Fatalf("SetTokenizer unexpected error: %v", err)
+	}
+}
+
+func TestTokenizerNames(t *testing.T) {
+	// Analyses are told apart by tokenizer name, so a name must say which
+	// tokenizer found the words
+	for _, name := range []string{"", helpers.UnicodeTokenizer.Name()} {
+		if _, err := helpers.NewTokenizer(name, strings.Fields); err == nil {
+			t.Errorf("NewTokenizer(%q) expected error, got nil", name)
+		}
+	}
+	if err := helpers.SetTokenizer(impostor{}); err == nil {
+		t.Errorf("SetTokenizer accepted a tokenizer named like UnicodeTokenizer")
+	}
+	if err := helpers.SetTokenizer(helpers.UnicodeTokenizer); err != nil || helpers.ActiveTokenizer() != helpers.UnicodeTokenizer {
+		t.Errorf("SetTokenizer(UnicodeTokenizer) = %v", err)
+	}
+}
+
+// impostor is a tokenizer that claims the name of UnicodeTokenizer
+type impostor struct{}
+
+func (impostor) Name() string               { return "unicode" }
+func (impostor) Tokenize(s string) []string { return strings.Fields(s) }
+
+func TestSetTokenizer(t *testing.T) {
+	handler := helpers.StringApiHandler{String: "a well-known fact"}
+	record := handler.GetString()
+
+	useFieldsTokenizer(t)
+	defer helpers.SetTokenizer(nil)
+
+	analysis := helpers.AnalyzeWords("a well-known fact")
+	if analysis.LongestWord != "well-known" || analysis.Tokenizer != "fields" {
+		t.Errorf("AnalyzeWords() with a whitespace tokenizer = %+v, expected the longest word well-known", analysis)
+	}
+
+	// An analysis by the previous tokenizer is out of date: filters
+	// compute a new one, and Complete replaces it
+	expression, _ := helpers.ParseFilterExpression("longest_word_length = 10")
+	if !expression.Match(record) {
+		t.Errorf("%q did not match with the words of the active tokenizer", expression)
+	}
+	if !record.Complete([]string{"longest_word_length"}) || record.Properties.WordAnalysis.Tokenizer != "fields" {
+		t.Errorf("Complete kept the analysis of the previous tokenizer: %+v", record.Properties.WordAnalysis)
+	}
+
+	// word_count keeps counting runs of non-space characters
+	if record.Properties.WordCount != 3 || record.Properties.WordAnalysis.DistinctWords != 3 {
+		t.Errorf("Expected 3 words to both word_count and the word analysis, got %+v", record.Properties)
+	}
+	helpers.SetTokenizer(nil)
+	if helpers.AnalyzeWords("a well-known fact").DistinctWords != 4 || helpers.CountWords("a well-known fact") != 3 {
+		t.Errorf("Expected the Unicode tokenizer to split well-known and word_count not to")
+	}
+}
+
+func TestWordAnalysisFilters(t *testing.T) {
+	handler := helpers.StringApiHandler{String: "extraordinary claims"}
+	// Filters compute the word analysis of strings stored without it
+	record := handler.GetStringFields([]string{"length"})
+	if record.Properties.WordAnalysis != nil {
+		t.Fatalf("GetStringFields computed the word analysis without being asked")
+	}
+
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"longest_word_length > 8", true},
+		{"shortest_word_length BETWEEN 1 AND 5", false},
+		{"distinct_words = 2 AND longest_word_length IS prime", true},
+	}
+	for _, test := range tests {
+		expression, err := helpers.ParseFilterExpression(test.input)
+		if err != nil {
+			t.Errorf("ParseFilterExpression(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if got := expression.Match(record); got != test.expected {
+			t.Errorf("%q matched %v, expected %v", test.input, got, test.expected)
+		}
+	}
+
+	for _, q := range []string{"longest_word_length > 10 AND length <= 5", "shortest_word_length >= 6 AND longest_word_length < 4"} {
+		expression, _ := helpers.ParseFilterExpression(q)
+		if conflicts := (helpers.FilterTree{Expression: expression}).Conflicts(); len(conflicts) == 0 {
+			t.Errorf("%q reported no conflicts", q)
+		}
+	}
+}
+
+func TestWordAnalysisNaturalLanguage(t *testing.T) {
+	long := helpers.StringApiHandler{String: "an extraordinary claim"}
+	short := helpers.StringApiHandler{String: "racecar"}
+	records := []helpers.Response{long.GetString(), short.GetString()}
+
+	tests := []struct {
+		query     string
+		canonical string
+		expected  []string
+	}{
+		{"strings whose longest word exceeds 8 letters", "strings whose longest word has more than 8 letters", []string{"an extraordinary claim"}},
+		{"palindromes whose shortest word has at most 7 letters", "palindromic strings whose shortest word has at most 7 letters", []string{"racecar"}},
+		{"strings whose longest word has between 3 and 7 letters", "strings whose longest word has between 3 and 7 letters", []string{"racecar"}},
+	}
+
+	for _, test := range tests {
+		result, err := helpers.InterpretNaturalLanguageQuery(test.query)
+		if err != nil {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) unexpected error: %v", test.query, err)
+			continue
+		}
+		if canonical, _ := result.Filter.CanonicalQuery(); canonical != test.canonical {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) canonical query = %q, expected %q", test.query, canonical, test.canonical)
+		}
+		var matched []string
+		for _, record := range result.Filter.Apply(records) {
+			matched = append(matched, record.Value)
+		}
+		if !reflect.DeepEqual(matched, test.expected) {
+			t.Errorf("InterpretNaturalLanguageQuery(%q) matched %q, expected %q", test.query, matched, test.expected)
+		}
+	}
+}
+
+func TestWordAnalysisEndpoints(t *testing.T) {
+	ResetTestBank()
+	router := SetupTestRouter()
+	createValues(t, TestBank, "extraordinary claims", "a b c", "racecar")
+
+	get := func(target string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name          string
+		target        string
+		expectedCount int
+	}{
+		{"min parameter", "/strings?min_longest_word_length=7", 2},
+		{"exact parameter", "/strings?distinct_words=3", 1},
+		{"q", "/strings?q=" + url.QueryEscape("shortest_word_length = 1"), 1},
+		{"natural language", "/strings/filter-by-natural-language?query=" + url.QueryEscape("strings whose longest word exceeds 8 letters"), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := get(test.target)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			var response struct {
+				Count int `json:"count"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if response.Count != test.expectedCount {
+				t.Errorf("Expected %d results, got %d: %s", test.expectedCount, response.Count, w.Body.String())
+			}
+		})
+	}
+
+	var record helpers.Response
+	json.Unmarshal(get("/strings/a%20b%20c").Body.Bytes(), &record)
+	if words := record.Properties.WordAnalysis; words == nil || words.DistinctWords != 3 || words.LexicalDiversity != 1 {
+		t.Errorf("GET /strings/:value returned word analysis %+v", words)
+	}
+
+	var selected struct {
+		Properties map[string]any `json:"properties"`
+	}
+	json.Unmarshal(get("/strings/racecar?fields=longest_word_length").Body.Bytes(), &selected)
+	if len(selected.Properties) != 1 || selected.Properties["longest_word_length"] != float64(7) {
+		t.Errorf("GET with fields=longest_word_length returned %v", selected.Properties)
+	}
+}
+
+func TestSQLiteStoreWordAnalysis(t *testing.T) {
+	s := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "strings.db"))
+	defer s.Close()
+
+	handler := helpers.StringApiHandler{String: "to be or not to be"}
+	if err := s.Create(handler.GetStringFields([]string{"longest_word_length"})); err != nil {
+		t.Fatalf("Create unexpected error: %v", err)
+	}
+	response, err := s.GetByValue("to be or not to be")
+	if err != nil {
+		t.Fatalf("GetByValue unexpected error: %v", err)
+	}
+	if words := response.Properties.WordAnalysis; words == nil || words.WordFrequencyMap["to"] != 2 || words.LongestWord != "not" {
+		t.Errorf("GetByValue returned word analysis %+v", words)
+	}
+	if response.Properties.CharacterFrequencyMap != nil {
+		t.Errorf("Asking for longest_word_length computed the character frequency map")
+	}
+
+	// An analysis by a replaced tokenizer is computed and stored again
+	useFieldsTokenizer(t)
+	defer helpers.SetTokenizer(nil)
+	store.Complete(s, []helpers.Response{response}, []string{"word_analysis"})
+	if stored, _ := s.GetByValue("to be or not to be"); stored.Properties.WordAnalysis == nil || stored.Properties.WordAnalysis.Tokenizer != "fields" {
+		t.Errorf("The analysis of the previous tokenizer was kept: %+v", stored.Properties.WordAnalysis)
+	}
+}
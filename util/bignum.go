@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+)
+
+// The *Big functions classify integers of any size. They check ctx between
+// steps and return its error once it is done, so callers can bound the time
+// spent on one number. No step is longer than a modular exponentiation or a
+// squaring of the number.
+
+var (
+	bigOne = big.NewInt(1)
+	bigTwo = big.NewInt(2)
+)
+
+// millerRabinBases make Miller–Rabin deterministic below 3.3 * 10^24; larger
+// numbers also go through Baillie–PSW
+var millerRabinBases = []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41}
+
+// IsPrimeBig tests primality with Miller–Rabin rounds over fixed bases,
+// then Baillie–PSW, which has no known counterexample
+func IsPrimeBig(ctx context.Context, n *big.Int) (bool, error) {
+	if n.Cmp(bigTwo) < 0 {
+		return false, nil
+	}
+	if n.IsInt64() && n.Int64() <= millerRabinBases[len(millerRabinBases)-1] {
+		for _, base := range millerRabinBases {
+			if n.Int64() == base {
+				return true, nil
+			}
+		}
+	}
+	if n.Bit(0) == 0 {
+		return false, nil
+	}
+
+	// n - 1 = d * 2^s with d odd
+	nMinusOne := new(big.Int).Sub(n, bigOne)
+	s := nMinusOne.TrailingZeroBits()
+	d := new(big.Int).Rsh(nMinusOne, s)
+	x := new(big.Int)
+	for _, base := range millerRabinBases {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		x.Exp(big.NewInt(base), d, n)
+		if x.Cmp(bigOne) == 0 || x.Cmp(nMinusOne) == 0 {
+			continue
+		}
+		composite := true
+		for i := uint(1); i < s && composite; i++ {
+			x.Mul(x, x).Mod(x, n)
+			composite = x.Cmp(nMinusOne) != 0
+		}
+		if composite {
+			return false, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return n.ProbablyPrime(0), nil
+}
+
+// IsArmstrongBig reports whether n equals the sum of its digits each raised
+// to the number of digits, computed exactly
+func IsArmstrongBig(ctx context.Context, n *big.Int) (bool, error) {
+	if n.Sign() < 0 {
+		return false, nil
+	}
+	digits := n.String()
+	k := big.NewInt(int64(len(digits)))
+	var powers [10]*big.Int
+	for d := range powers {
+		powers[d] = new(big.Int).Exp(big.NewInt(int64(d)), k, nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	sum := new(big.Int)
+	for _, digit := range digits {
+		sum.Add(sum, powers[digit-'0'])
+	}
+	return sum.Cmp(n) == 0, nil
+}
+
+// IsPerfectBig reports whether n is a perfect number. Every even perfect
+// number is 2^(p-1) * (2^p - 1) with 2^p - 1 a Mersenne prime
+// (Euclid–Euler), which Lucas–Lehmer checks exactly. No odd perfect number
+// exists below 10^1500, so odd numbers shorter than that are not perfect;
+// longer ones are reported as not perfect too, though that is unproven.
+func IsPerfectBig(ctx context.Context, n *big.Int) (bool, error) {
+	if n.Sign() <= 0 || n.Bit(0) == 1 {
+		return false, nil
+	}
+	p := n.TrailingZeroBits() + 1
+	mersenne := new(big.Int).Lsh(bigOne, p)
+	mersenne.Sub(mersenne, bigOne)
+	if new(big.Int).Rsh(n, p-1).Cmp(mersenne) != 0 {
+		return false, nil
+	}
+	return isMersennePrime(ctx, p, mersenne)
+}
+
+// isMersennePrime runs Lucas–Lehmer on m = 2^p - 1
+func isMersennePrime(ctx context.Context, p uint, m *big.Int) (bool, error) {
+	if p == 2 {
+		return true, nil
+	}
+	if !big.NewInt(int64(p)).ProbablyPrime(0) {
+		return false, nil
+	}
+	s := big.NewInt(4)
+	for i := uint(0); i < p-2; i++ {
+		if i%64 == 0 {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+		}
+		s.Mul(s, s).Sub(s, bigTwo).Mod(s, m)
+	}
+	return s.Sign() == 0, nil
+}
+
+// DigitSumBig adds up the decimal digits of n, keeping its sign like
+// DigitalSum
+func DigitSumBig(n *big.Int) int {
+	sum := 0
+	for _, digit := range new(big.Int).Abs(n).String() {
+		sum += int(digit - '0')
+	}
+	if n.Sign() < 0 {
+		return -sum
+	}
+	return sum
+}
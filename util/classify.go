@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+)
+
+// Classification is what GET /api/classify-number reports about a number.
+// Properties lists "armstrong" when it applies, then "odd" or "even".
+type Classification struct {
+	Number     *big.Int `json:"number"`
+	IsPrime    bool     `json:"is_prime"`
+	IsPerfect  bool     `json:"is_perfect"`
+	Properties []string `json:"properties"`
+	DigitSum   int      `json:"digit_sum"`
+	FunFact    string   `json:"fun_fact"`
+}
+
+// Classify works out everything but the fun fact, stopping with ctx's
+// error once it is done. Negative numbers are never prime, perfect or
+// Armstrong numbers; their digit sum keeps the sign, as in DigitalSum.
+func Classify(ctx context.Context, n *big.Int) (Classification, error) {
+	c := Classification{Number: n, Properties: []string{}, DigitSum: DigitSumBig(n)}
+	var err error
+	if c.IsPrime, err = IsPrimeBig(ctx, n); err != nil {
+		return c, err
+	}
+	if c.IsPerfect, err = IsPerfectBig(ctx, n); err != nil {
+		return c, err
+	}
+	armstrong, err := IsArmstrongBig(ctx, n)
+	if err != nil {
+		return c, err
+	}
+	if armstrong {
+		c.Properties = append(c.Properties, "armstrong")
+	}
+	if n.Bit(0) == 0 {
+		c.Properties = append(c.Properties, "even")
+	} else {
+		c.Properties = append(c.Properties, "odd")
+	}
+	return c, nil
+}
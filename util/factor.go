@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Factor is one prime power of a factorization
+type Factor struct {
+	Prime    *big.Int `json:"prime"`
+	Exponent int      `json:"exponent"`
+}
+
+// trialDivisionLimit is the largest prime trial division tries before
+// Pollard's rho takes over
+const trialDivisionLimit = 10000
+
+var smallPrimes = sync.OnceValue(func() []int64 {
+	composite := make([]bool, trialDivisionLimit+1)
+	var primes []int64
+	for i := 2; i <= trialDivisionLimit; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, int64(i))
+		for j := i * i; j <= trialDivisionLimit; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+})
+
+// Factorize returns the prime factorization of a positive integer, smallest
+// prime first; 1 has none. Primes up to 10000 are found by trial division,
+// larger ones by Pollard's rho. It stops with ctx's error once ctx is done.
+func Factorize(ctx context.Context, n *big.Int) ([]Factor, error) {
+	if n.Sign() <= 0 {
+		return nil, fmt.Errorf("cannot factorize %s: not a positive integer", n)
+	}
+	exponents := make(map[string]*Factor)
+	add := func(p *big.Int, e int) {
+		if f, ok := exponents[p.String()]; ok {
+			f.Exponent += e
+			return
+		}
+		exponents[p.String()] = &Factor{Prime: new(big.Int).Set(p), Exponent: e}
+	}
+
+	m := new(big.Int).Set(n)
+	quotient, remainder := new(big.Int), new(big.Int)
+	for _, small := range smallPrimes() {
+		p := big.NewInt(small)
+		if new(big.Int).Mul(p, p).Cmp(m) > 0 {
+			break
+		}
+		for {
+			quotient.QuoRem(m, p, remainder)
+			if remainder.Sign() != 0 {
+				break
+			}
+			add(p, 1)
+			m.Set(quotient)
+		}
+	}
+
+	// What is left has only large prime factors
+	pending := []*big.Int{m}
+	for len(pending) > 0 {
+		m := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if m.Cmp(bigOne) == 0 {
+			continue
+		}
+		prime, err := IsPrimeBig(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		if prime {
+			add(m, 1)
+			continue
+		}
+		d, err := pollardRho(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, d, new(big.Int).Quo(m, d))
+	}
+
+	factors := make([]Factor, 0, len(exponents))
+	for _, f := range exponents {
+		factors = append(factors, *f)
+	}
+	slices.SortFunc(factors, func(a, b Factor) int { return a.Prime.Cmp(b.Prime) })
+	return factors, nil
+}
+
+// pollardRho finds a nontrivial divisor of an odd composite n with Brent's
+// variant of Pollard's rho, trying x² + c for c = 1, 2, ... until one splits n
+func pollardRho(ctx context.Context, n *big.Int) (*big.Int, error) {
+	// Products of this many differences share one gcd
+	const batch = 128
+	for c := int64(1); ; c++ {
+		step := func(x *big.Int) { x.Mul(x, x).Add(x, big.NewInt(c)).Mod(x, n) }
+		x, y, ys := new(big.Int), big.NewInt(2), new(big.Int)
+		q, g, diff := big.NewInt(1), big.NewInt(1), new(big.Int)
+		for r := 1; g.Cmp(bigOne) == 0; r *= 2 {
+			x.Set(y)
+			for i := 0; i < r; i++ {
+				step(y)
+			}
+			for k := 0; k < r && g.Cmp(bigOne) == 0; k += batch {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				ys.Set(y)
+				for i := 0; i < min(batch, r-k); i++ {
+					step(y)
+					q.Mul(q, diff.Sub(x, y).Abs(diff)).Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+			}
+		}
+		if g.Cmp(n) == 0 {
+			// The batch overshot; retrace it one step at a time
+			for g.Cmp(bigOne) == 0 || g.Cmp(n) == 0 {
+				step(ys)
+				g.GCD(nil, nil, diff.Sub(x, ys).Abs(diff), n)
+				if g.Cmp(n) == 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(n) != 0 {
+			return g, nil
+		}
+	}
+}
+
+// DivisorAnalysis is what GET /api/numbers/:n/factors reports
+type DivisorAnalysis struct {
+	Number        *big.Int `json:"number"`
+	Factors       []Factor `json:"factors"`
+	Factorization string   `json:"factorization"`
+	DivisorCount  *big.Int `json:"divisor_count"`
+	DivisorSum    *big.Int `json:"divisor_sum"`
+	// Abundance is "perfect" when the proper divisors add up to the number,
+	// "abundant" when they exceed it and "deficient" otherwise, which
+	// generalizes IsPerfect
+	Abundance    string   `json:"abundance"`
+	Totient      *big.Int `json:"totient"`
+	IsSquarefree bool     `json:"is_squarefree"`
+}
+
+// AnalyzeDivisors factorizes a positive integer and derives its divisor
+// count and sum, abundance, Euler's totient and whether it is squarefree
+func AnalyzeDivisors(ctx context.Context, n *big.Int) (DivisorAnalysis, error) {
+	factors, err := Factorize(ctx, n)
+	if err != nil {
+		return DivisorAnalysis{}, err
+	}
+	a := DivisorAnalysis{
+		Number:       n,
+		Factors:      factors,
+		DivisorCount: big.NewInt(1),
+		DivisorSum:   big.NewInt(1),
+		Totient:      big.NewInt(1),
+		IsSquarefree: true,
+	}
+	terms := make([]string, len(factors))
+	for i, f := range factors {
+		e := big.NewInt(int64(f.Exponent))
+		a.DivisorCount.Mul(a.DivisorCount, e.Add(e, bigOne))
+
+		// σ(p^e) = (p^(e+1) - 1) / (p - 1) and φ(p^e) = p^(e-1) (p - 1)
+		pMinusOne := new(big.Int).Sub(f.Prime, bigOne)
+		power := new(big.Int).Exp(f.Prime, big.NewInt(int64(f.Exponent)), nil)
+		sigma := new(big.Int).Mul(power, f.Prime)
+		sigma.Sub(sigma, bigOne).Quo(sigma, pMinusOne)
+		a.DivisorSum.Mul(a.DivisorSum, sigma)
+		a.Totient.Mul(a.Totient, power.Quo(power, f.Prime).Mul(power, pMinusOne))
+
+		terms[i] = f.Prime.String()
+		if f.Exponent > 1 {
+			terms[i] += fmt.Sprintf("^%d", f.Exponent)
+			a.IsSquarefree = false
+		}
+	}
+	a.Factorization = strings.Join(terms, " × ")
+	if len(factors) == 0 {
+		a.Factorization = "1"
+	}
+
+	switch new(big.Int).Sub(a.DivisorSum, n).Cmp(n) {
+	case 0:
+		a.Abundance = "perfect"
+	case 1:
+		a.Abundance = "abundant"
+	default:
+		a.Abundance = "deficient"
+	}
+	return a, nil
+}
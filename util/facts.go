@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// FactProvider finds a fun fact about a classified number, giving up
+// when ctx is done
+type FactProvider interface {
+	Fact(ctx context.Context, c Classification) (string, error)
+}
+
+// DefaultNumbersAPIURL is where NumbersAPI looks up facts without a BaseURL
+const DefaultNumbersAPIURL = "http://numbersapi.com"
+
+// NumbersAPI fetches math facts from the Numbers API with FetchAPI
+type NumbersAPI struct {
+	BaseURL string
+}
+
+func (a NumbersAPI) Fact(ctx context.Context, c Classification) (string, error) {
+	base := a.BaseURL
+	if base == "" {
+		base = DefaultNumbersAPIURL
+	}
+	fact, err := FetchAPIContext(ctx, fmt.Sprintf("%s/%s/math", strings.TrimSuffix(base, "/"), c.Number))
+	if err != nil {
+		return "", err
+	}
+	if fact = strings.TrimSpace(fact); fact == "" {
+		return "", fmt.Errorf("no fact for %s", c.Number)
+	}
+	return fact, nil
+}
+
+// LocalFacts makes up facts from a number's own properties, without the
+// network. It stands in for NumbersAPI in tests and when the API is down.
+type LocalFacts struct{}
+
+func (LocalFacts) Fact(_ context.Context, c Classification) (string, error) {
+	switch digits := c.Number.String(); {
+	case len(digits) > 1 && slices.Contains(c.Properties, "armstrong"):
+		terms := make([]string, len(digits))
+		for i, digit := range digits {
+			terms[i] = fmt.Sprintf("%c^%d", digit, len(digits))
+		}
+		return fmt.Sprintf("%s is an Armstrong number because %s = %s", digits, strings.Join(terms, " + "), digits), nil
+	case c.IsPerfect:
+		return fmt.Sprintf("%s is a perfect number: it is the sum of its proper divisors", digits), nil
+	case c.IsPrime:
+		return fmt.Sprintf("%s is a prime number: it has no divisors but 1 and itself", digits), nil
+	}
+	parity := "odd"
+	if c.Number.Bit(0) == 0 {
+		parity = "even"
+	}
+	return fmt.Sprintf("%s is an %s number whose digits add up to %d", c.Number, parity, c.DigitSum), nil
+}
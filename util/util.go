@@ -1,68 +1,29 @@
 package utils
 
 import (
+	"context"
+	"fmt"
 	"io"
-	"math"
+	"math/big"
 	"net/http"
+	"time"
 )
 
+// IsPrime, IsPerfect and IsArmstrong use the exact big.Int tests, which
+// neither overflow nor lose precision near the top of the int range
 func IsPrime(n int) bool {
-	if n < 2 {
-		return false
-	}
-	if n == 2 || n == 3 {
-		return true
-	}
-	if n%2 == 0 || n%3 == 0 {
-		return false
-	}
-
-	// Check only up to sqrt(n)
-	for i := 5; i <= int(math.Sqrt(float64(n))); i += 6 {
-		if n%i == 0 || n%(i+2) == 0 {
-			return false
-		}
-	}
-	return true
+	prime, _ := IsPrimeBig(context.Background(), big.NewInt(int64(n)))
+	return prime
 }
 
 func IsPerfect(n int) bool {
-	if n < 2 {
-		return false
-	}
-
-	sum := 1 // 1 is always a divisor
-	for i := 2; i*i <= n; i++ {
-		if n%i == 0 {
-			sum += i
-			if i != n/i {
-				sum += n / i
-			}
-		}
-	}
-
-	return sum == n
+	perfect, _ := IsPerfectBig(context.Background(), big.NewInt(int64(n)))
+	return perfect
 }
 
 func IsArmstrong(n int) bool {
-	temp, sum, digits := n, 0, 0
-
-	// Count number of digits
-	for temp > 0 {
-		digits++
-		temp /= 10
-	}
-
-	temp = n // Reset temp
-
-	// Compute sum of each digit raised to the power of digits
-	for temp > 0 {
-		digit := temp % 10
-		sum += int(math.Pow(float64(digit), float64(digits)))
-		temp /= 10
-	}
-
-	return sum == n
+	armstrong, _ := IsArmstrongBig(context.Background(), big.NewInt(int64(n)))
+	return armstrong
 }
 
 func IsEven(n int) bool {
@@ -92,13 +53,29 @@ func Abs(n int) int {
 	return n
 }
 
+// fetchClient gives up on slow APIs, so a request never hangs on one
+var fetchClient = &http.Client{Timeout: 5 * time.Second}
+
 func FetchAPI(url string) (string, error) {
-	resp, err := http.Get(url)
+	return FetchAPIContext(context.Background(), url)
+}
+
+// FetchAPIContext is FetchAPI giving up when ctx is done
+func FetchAPIContext(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := fetchClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close() // Ensure response body is closed
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {